@@ -2,27 +2,338 @@ package config
 
 import (
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	Port        string
-	FirebaseKey string
-	Collection  string
-	JWTSecret   string
+	Port              string
+	FirebaseKey       string
+	Collection        string
+	JWTSecret         string
+	SchedulerInterval time.Duration
+	ReaperInterval    time.Duration
+
+	UsernameChangeCooldown time.Duration
+	ReservedUsernames      []string
+	UserSearchRateLimit    int
+
+	BlockedWords       []string
+	BlockLinks         bool
+	AllowedLinkDomains []string
+	MaxMessageLength   int
+	MaxRepeatedChars   int
+
+	RateLimitBackend           string
+	LoginRateLimit             RateLimitPolicy
+	RegisterRateLimit          RateLimitPolicy
+	PasswordResetRateLimit     RateLimitPolicy
+	MessageRateLimit           RateLimitPolicy
+	TypingRateLimit            RateLimitPolicy
+	IncomingWebhookRateLimit   RateLimitPolicy
+	IncomingWebhookIPRateLimit RateLimitPolicy
+	WSRateLimitDisconnectAfter int
+
+	LoginMaxFailures     int
+	LoginIPMaxFailures   int
+	LoginLockoutDuration time.Duration
+
+	PasswordMinLength        int
+	PasswordRequireMixedCase bool
+	PasswordRequireDigit     bool
+	PasswordRequireSymbol    bool
+	PasswordResetTTL         time.Duration
+	PasswordResetURL         string
+
+	Notifier     string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	OIDCProviders []OIDCProvider
+
+	FirebaseAuthEnabled      bool
+	FirebaseAuthCheckRevoked bool
+	FirebaseAuthJWKSURL      string
+	FirebaseProjectID        string
+
+	Environment           string
+	JWTSigningAlgorithm   string
+	JWTKeyEncryptionKey   string
+	JWTKeyRotation        time.Duration
+	JWTKeyGracePeriod     time.Duration
+	JWTKeyRefreshInterval time.Duration
+	JWTIssuer             string
+	JWTAudience           string
+	JWTTTL                time.Duration
+
+	TokenDenylistSyncInterval  time.Duration
+	TokenDenylistMaxStaleness  time.Duration
+	TokenDenylistPurgeInterval time.Duration
+
+	BotMaxPerOwner              int
+	BotUpdateRetention          time.Duration
+	BotDeliveryInterval         time.Duration
+	WebhookTimeout              time.Duration
+	WebhookAllowPrivateNetworks bool
+
+	WebhookMaxPerChat       int
+	WebhookMaxAttempts      int
+	WebhookRetryBase        time.Duration
+	WebhookRetryMax         time.Duration
+	WebhookDisableAfter     int
+	WebhookLogRetention     time.Duration
+	WebhookDeliveryInterval time.Duration
+	// PublicURL is the externally visible server address used in incoming
+	// webhook URLs, e.g. "https://chat.example.com".
+	PublicURL string
+
+	// PushProvider is "none", which drops notifications, "fcm", or "fake", which
+	// records them in memory for development.
+	PushProvider       string
+	PushCollapseWindow time.Duration
+	PushTimeout        time.Duration
+
+	DigestInterval time.Duration
+	// DigestHour is the hour in the user's timezone at which digests are sent.
+	DigestHour     int
+	DigestWeekday  time.Weekday
+	DigestMaxChats int
+	// DigestSigningKey signs unsubscribe links. Outside production JWT_SECRET is
+	// used when it is unset.
+	DigestSigningKey string
 }
 
+// OIDCProvider is configured from OIDC_<NAME>_* variables for every name listed in
+// OIDC_PROVIDERS.
+type OIDCProvider struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// RateLimitPolicy is written as "<requests>/<duration>", e.g. "30/1m". "off"
+// disables the limit.
+type RateLimitPolicy struct {
+	Requests int
+	Per      time.Duration
+}
+
+// DefaultJWTSecret is only meant for development; the server refuses to start
+// with it when APP_ENV=production.
+const DefaultJWTSecret = "your-secret-key-change-in-production"
+
 func Load() *Config {
 	return &Config{
-		Port:        getEnv("PORT", "3000"),
-		FirebaseKey: getEnv("FIREBASE_SERVICE_ACCOUNT", "serviceAccountKey.json"),
-		Collection:  getEnv("FIRESTORE_COLLECTION", "messages"),
-		JWTSecret:   getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
+		Port:              getEnv("PORT", "3000"),
+		FirebaseKey:       getEnv("FIREBASE_SERVICE_ACCOUNT", "serviceAccountKey.json"),
+		Collection:        getEnv("FIRESTORE_COLLECTION", "messages"),
+		JWTSecret:         getEnv("JWT_SECRET", DefaultJWTSecret),
+		SchedulerInterval: getDurationEnv("SCHEDULER_INTERVAL", 5*time.Second),
+		ReaperInterval:    getDurationEnv("MESSAGE_REAPER_INTERVAL", 30*time.Second),
+
+		UsernameChangeCooldown: getDurationEnv("USERNAME_CHANGE_COOLDOWN", 30*24*time.Hour),
+		ReservedUsernames:      getListEnv("RESERVED_USERNAMES"),
+		UserSearchRateLimit:    getIntEnv("USER_SEARCH_RATE_LIMIT", 30),
+
+		BlockedWords:       getListEnv("BLOCKED_WORDS"),
+		BlockLinks:         getBoolEnv("BLOCK_LINKS", false),
+		AllowedLinkDomains: getListEnv("ALLOWED_LINK_DOMAINS"),
+		MaxMessageLength:   getIntEnv("MAX_MESSAGE_LENGTH", 4000),
+		MaxRepeatedChars:   getIntEnv("MAX_REPEATED_CHARS", 20),
+
+		RateLimitBackend:           getEnv("RATE_LIMIT_BACKEND", "memory"),
+		LoginRateLimit:             getRateLimitEnv("RATE_LIMIT_LOGIN", RateLimitPolicy{10, time.Minute}),
+		RegisterRateLimit:          getRateLimitEnv("RATE_LIMIT_REGISTER", RateLimitPolicy{5, time.Hour}),
+		PasswordResetRateLimit:     getRateLimitEnv("RATE_LIMIT_PASSWORD_RESET", RateLimitPolicy{5, time.Hour}),
+		MessageRateLimit:           getRateLimitEnv("RATE_LIMIT_MESSAGES", RateLimitPolicy{30, time.Minute}),
+		TypingRateLimit:            getRateLimitEnv("RATE_LIMIT_TYPING", RateLimitPolicy{20, time.Minute}),
+		IncomingWebhookRateLimit:   getRateLimitEnv("RATE_LIMIT_INCOMING_WEBHOOKS", RateLimitPolicy{30, time.Minute}),
+		IncomingWebhookIPRateLimit: getRateLimitEnv("RATE_LIMIT_INCOMING_WEBHOOKS_IP", RateLimitPolicy{60, time.Minute}),
+		WSRateLimitDisconnectAfter: getIntEnv("WS_RATE_LIMIT_DISCONNECT_AFTER", 10),
+
+		LoginMaxFailures:     getIntEnv("LOGIN_MAX_FAILURES", 5),
+		LoginIPMaxFailures:   getIntEnv("LOGIN_IP_MAX_FAILURES", 50),
+		LoginLockoutDuration: getDurationEnv("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+
+		PasswordMinLength:        getIntEnv("PASSWORD_MIN_LENGTH", 8),
+		PasswordRequireMixedCase: getBoolEnv("PASSWORD_REQUIRE_MIXED_CASE", false),
+		PasswordRequireDigit:     getBoolEnv("PASSWORD_REQUIRE_DIGIT", true),
+		PasswordRequireSymbol:    getBoolEnv("PASSWORD_REQUIRE_SYMBOL", false),
+		PasswordResetTTL:         getDurationEnv("PASSWORD_RESET_TTL", time.Hour),
+		PasswordResetURL:         getEnv("PASSWORD_RESET_URL", ""),
+
+		Notifier:     getEnv("NOTIFIER", "log"),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getIntEnv("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", "no-reply@flare.local"),
+
+		OIDCProviders: getOIDCProviders(),
+
+		FirebaseAuthEnabled:      getBoolEnv("FIREBASE_AUTH_ENABLED", false),
+		FirebaseAuthCheckRevoked: getBoolEnv("FIREBASE_AUTH_CHECK_REVOKED", true),
+		FirebaseAuthJWKSURL:      getEnv("FIREBASE_AUTH_JWKS_URL", ""),
+		FirebaseProjectID:        getEnv("FIREBASE_PROJECT_ID", ""),
+
+		Environment:           getEnv("APP_ENV", "development"),
+		JWTSigningAlgorithm:   getEnv("JWT_SIGNING_ALG", defaultSigningAlgorithm()),
+		JWTKeyEncryptionKey:   getEnv("JWT_KEY_ENCRYPTION_KEY", ""),
+		JWTKeyRotation:        getDurationEnv("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour),
+		JWTKeyGracePeriod:     getDurationEnv("JWT_KEY_GRACE_PERIOD", 48*time.Hour),
+		JWTKeyRefreshInterval: getDurationEnv("JWT_KEY_REFRESH_INTERVAL", 5*time.Minute),
+		JWTIssuer:             getEnv("JWT_ISSUER", "flare"),
+		JWTAudience:           getEnv("JWT_AUDIENCE", "flare-api"),
+		JWTTTL:                getDurationEnv("JWT_TTL", 24*time.Hour),
+
+		TokenDenylistSyncInterval:  getDurationEnv("TOKEN_DENYLIST_SYNC_INTERVAL", 10*time.Second),
+		TokenDenylistMaxStaleness:  getDurationEnv("TOKEN_DENYLIST_MAX_STALENESS", time.Minute),
+		TokenDenylistPurgeInterval: getDurationEnv("TOKEN_DENYLIST_PURGE_INTERVAL", time.Hour),
+
+		BotMaxPerOwner:              getIntEnv("BOT_MAX_PER_OWNER", 20),
+		BotUpdateRetention:          getDurationEnv("BOT_UPDATE_RETENTION", 24*time.Hour),
+		BotDeliveryInterval:         getDurationEnv("BOT_DELIVERY_INTERVAL", 30*time.Second),
+		WebhookTimeout:              getDurationEnv("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookAllowPrivateNetworks: getBoolEnv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false),
+
+		WebhookMaxPerChat:       getIntEnv("WEBHOOK_MAX_PER_CHAT", 10),
+		WebhookMaxAttempts:      getIntEnv("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookRetryBase:        getDurationEnv("WEBHOOK_RETRY_BASE", 30*time.Second),
+		WebhookRetryMax:         getDurationEnv("WEBHOOK_RETRY_MAX", time.Hour),
+		WebhookDisableAfter:     getIntEnv("WEBHOOK_DISABLE_AFTER", 20),
+		WebhookLogRetention:     getDurationEnv("WEBHOOK_LOG_RETENTION", 7*24*time.Hour),
+		WebhookDeliveryInterval: getDurationEnv("WEBHOOK_DELIVERY_INTERVAL", 10*time.Second),
+		PublicURL:               getEnv("PUBLIC_URL", ""),
+
+		PushProvider:       getEnv("PUSH_PROVIDER", "none"),
+		PushCollapseWindow: getDurationEnv("PUSH_COLLAPSE_WINDOW", 3*time.Second),
+		PushTimeout:        getDurationEnv("PUSH_TIMEOUT", 10*time.Second),
+
+		DigestInterval: getDurationEnv("DIGEST_INTERVAL", 15*time.Minute),
+		DigestHour:     getHourEnv("DIGEST_HOUR", 9),
+		DigestWeekday:  getWeekdayEnv("DIGEST_WEEKDAY", time.Monday),
+		DigestMaxChats: getIntEnv("DIGEST_MAX_CHATS", 10),
+
+		DigestSigningKey: getEnv("DIGEST_SIGNING_KEY", ""),
 	}
 }
 
+func getOIDCProviders() []OIDCProvider {
+	var providers []OIDCProvider
+	for _, name := range getListEnv("OIDC_PROVIDERS") {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers = append(providers, OIDCProvider{
+			Name:         strings.ToLower(name),
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			RedirectURL:  getEnv(prefix+"REDIRECT_URL", ""),
+			Scopes:       getListEnv(prefix + "SCOPES"),
+		})
+	}
+	return providers
+}
+
+// defaultSigningAlgorithm signs with rotating asymmetric keys in production, so
+// the signing secret is never shared with services that only verify tokens.
+func defaultSigningAlgorithm() string {
+	if getEnv("APP_ENV", "development") == "production" {
+		return "RS256"
+	}
+	return "HS256"
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultValue
+}
+
+func getListEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getIntEnv(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func getRateLimitEnv(key string, defaultValue RateLimitPolicy) RateLimitPolicy {
+	value := strings.TrimSpace(os.Getenv(key))
+	if value == "" {
+		return defaultValue
+	}
+	if value == "off" {
+		return RateLimitPolicy{}
+	}
+
+	requests, per, ok := strings.Cut(value, "/")
+	if !ok {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(per)
+	if err != nil || d <= 0 {
+		return defaultValue
+	}
+	return RateLimitPolicy{Requests: n, Per: d}
+}
+
+func getHourEnv(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 && n < 24 {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+// getWeekdayEnv accepts English weekday names such as "monday".
+func getWeekdayEnv(key string, defaultValue time.Weekday) time.Weekday {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv(key)))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if value == strings.ToLower(day.String()) {
+			return day
+		}
+	}
+	return defaultValue
+}
@@ -2,8 +2,14 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
 
+	"Flare-server/internal/middleware"
+	"Flare-server/internal/models"
+	"Flare-server/internal/repository"
 	"Flare-server/internal/service"
 )
 
@@ -18,6 +24,7 @@ func NewAuthHandler(svc *service.AuthService) *AuthHandler {
 type RegisterInput struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	Email    string `json:"email,omitempty"`
 }
 
 type LoginInput struct {
@@ -25,6 +32,10 @@ type LoginInput struct {
 	Password string `json:"password"`
 }
 
+type FirebaseTokenInput struct {
+	IDToken string `json:"idToken"`
+}
+
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var input RegisterInput
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -32,7 +43,7 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := h.service.Register(r.Context(), input.Username, input.Password)
+	user, err := h.service.Register(r.Context(), input.Username, input.Password, input.Email)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -55,9 +66,50 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := h.service.Login(r.Context(), input.Username, input.Password)
+	result, err := h.service.Login(r.Context(), input.Username, input.Password, middleware.ClientIP(r))
 	if err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		switch {
+		case middleware.WriteRateLimitError(w, err):
+		case errors.Is(err, service.ErrAccountSuspended):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if result.ChallengeToken != "" {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"twoFactorRequired": true,
+			"challengeToken":    result.ChallengeToken,
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"token": result.Token})
+}
+
+func (h *AuthHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.service.CompleteTwoFactorLogin(r.Context(), req.ChallengeToken, req.Code, middleware.ClientIP(r))
+	if err != nil {
+		switch {
+		case middleware.WriteRateLimitError(w, err):
+		case errors.Is(err, service.ErrAccountSuspended):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		}
 		return
 	}
 
@@ -65,17 +117,192 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"token": token})
 }
 
+// FirebaseLogin exchanges a Firebase ID token for a Flare token. Users with 2FA
+// must sign in this way, since their Firebase tokens are not accepted directly.
+func (h *AuthHandler) FirebaseLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var input FirebaseTokenInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.service.LoginWithFirebase(r.Context(), input.IDToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRevocationCheckFailed):
+			http.Error(w, "Failed to validate token", http.StatusInternalServerError)
+		case errors.Is(err, service.ErrAccountSuspended):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, service.ErrExternalEmailInUse):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if result.ChallengeToken != "" {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"twoFactorRequired": true,
+			"challengeToken":    result.ChallengeToken,
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"token": result.Token})
+}
+
+// FirebaseLink links the Firebase account of an ID token to the signed-in user.
+func (h *AuthHandler) FirebaseLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var input FirebaseTokenInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.LinkFirebaseIdentity(r.Context(), userInfo.ID, input.IDToken); err != nil {
+		if errors.Is(err, repository.ErrIdentityLinked) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		log.Printf("❌ Error linking Firebase identity: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Identity linked"})
+}
+
+func (h *AuthHandler) EnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	enrollment, err := h.service.EnrollTwoFactor(r.Context(), userInfo.ID)
+	if err != nil {
+		log.Printf("❌ Error enrolling two-factor authentication: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+func (h *AuthHandler) ActivateTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.TwoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.service.ActivateTwoFactor(r.Context(), userInfo.ID, req.Code)
+	if err != nil {
+		log.Printf("❌ Error activating two-factor authentication: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(codes)
+}
+
+func (h *AuthHandler) DisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.DisableTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.DisableTwoFactor(r.Context(), userInfo.ID, req.Password, req.Code); err != nil {
+		if errors.Is(err, service.ErrInvalidPassword) || errors.Is(err, service.ErrInvalidTwoFactorCode) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		log.Printf("❌ Error disabling two-factor authentication: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Two-factor authentication disabled"})
+}
+
+// JWKS publishes the public keys that verify Flare access tokens, so other
+// services can check them without sharing a secret.
+func (h *AuthHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.service.JWKS())
+}
+
 func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
-	tokenString := r.Header.Get("Authorization")
-	if tokenString == "" {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
 		http.Error(w, "Authorization header required", http.StatusUnauthorized)
 		return
 	}
 
-	tokenString = tokenString[len("Bearer "):]
+	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	if tokenString == authHeader {
+		http.Error(w, "Bearer token required", http.StatusUnauthorized)
+		return
+	}
 
 	err := h.service.Logout(r.Context(), tokenString)
 	if err != nil {
+		log.Printf("❌ Error logging out: %v", err)
 		http.Error(w, "Failed to logout", http.StatusInternalServerError)
 		return
 	}
@@ -84,23 +311,41 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out"})
 }
 
-func (h *AuthHandler) Profile(w http.ResponseWriter, r *http.Request) {
-	userInfo := r.Context().Value("user")
+// LogoutAll revokes every token of the current user, including the one used for
+// this request.
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
 	if userInfo == nil {
 		http.Error(w, "User not authenticated", http.StatusUnauthorized)
 		return
 	}
 
-	userMap, ok := userInfo.(map[string]interface{})
-	if !ok {
-		http.Error(w, "Invalid user context", http.StatusInternalServerError)
+	if err := h.service.RevokeAllTokens(r.Context(), userInfo.ID); err != nil {
+		log.Printf("❌ Error revoking tokens: %v", err)
+		http.Error(w, "Failed to logout", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Logged out from all sessions"})
+}
+
+func (h *AuthHandler) Profile(w http.ResponseWriter, r *http.Request) {
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"userID":   userMap["userID"],
-		"username": userMap["username"],
+		"userID":   userInfo.ID,
+		"username": userInfo.Username,
 		"message":  "Profile retrieved",
 	})
 }
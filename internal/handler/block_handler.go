@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/service"
+)
+
+type BlockHandler struct {
+	blockService *service.BlockService
+}
+
+func NewBlockHandler(blockService *service.BlockService) *BlockHandler {
+	return &BlockHandler{
+		blockService: blockService,
+	}
+}
+
+func (h *BlockHandler) GetBlockedUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	blocks, err := h.blockService.GetBlockedUsers(r.Context(), userInfo.ID)
+	if err != nil {
+		log.Printf("❌ Error getting blocked users: %v", err)
+		http.Error(w, "Failed to get blocked users", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(blocks)
+}
+
+func (h *BlockHandler) BlockUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.BlockUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	block, err := h.blockService.BlockUser(r.Context(), userInfo.ID, req)
+	if err != nil {
+		log.Printf("❌ Error blocking user: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(block)
+}
+
+func (h *BlockHandler) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	blockedID := extractBlockedUserID(r.URL.Path)
+	if blockedID == "" {
+		http.Error(w, "User ID is required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.blockService.UnblockUser(r.Context(), userInfo.ID, blockedID); err != nil {
+		log.Printf("❌ Error unblocking user: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "User unblocked"})
+}
+
+func extractBlockedUserID(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) >= 3 && parts[0] == "api" && parts[1] == "blocks" {
+		return parts[2]
+	}
+	return ""
+}
@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"Flare-server/internal/middleware"
+	"Flare-server/internal/models"
+	"Flare-server/internal/repository"
+	"Flare-server/internal/service"
+)
+
+type BotHandler struct {
+	botService *service.BotService
+}
+
+func NewBotHandler(botService *service.BotService) *BotHandler {
+	return &BotHandler{
+		botService: botService,
+	}
+}
+
+func (h *BotHandler) CreateBot(w http.ResponseWriter, r *http.Request) {
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateBotRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.botService.CreateBot(r.Context(), userInfo.ID, req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, repository.ErrUsernameTaken) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *BotHandler) GetBots(w http.ResponseWriter, r *http.Request) {
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	response, err := h.botService.GetBots(r.Context(), userInfo.ID)
+	if err != nil {
+		log.Printf("❌ Error getting bots: %v", err)
+		http.Error(w, "Failed to get bots", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *BotHandler) RegenerateToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	botID := extractBotID(r.URL.Path)
+	if botID == "" {
+		http.Error(w, "Bot ID is required", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.botService.RegenerateToken(r.Context(), userInfo.ID, botID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *BotHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bot := middleware.PrincipalFromContext(r.Context())
+	profile, err := h.botService.GetMe(r.Context(), bot.ID)
+	if err != nil {
+		log.Printf("❌ Error getting bot profile: %v", err)
+		http.Error(w, "Failed to get bot", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+func (h *BotHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.BotSendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	bot := middleware.PrincipalFromContext(r.Context())
+	message, err := h.botService.SendMessage(r.Context(), bot.ID, req)
+	if err != nil {
+		if !middleware.WriteRateLimitError(w, err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(message)
+}
+
+func (h *BotHandler) GetUpdates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.GetUpdatesRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+
+	bot := middleware.PrincipalFromContext(r.Context())
+	response, err := h.botService.GetUpdates(r.Context(), bot.ID, req)
+	if errors.Is(err, service.ErrBotWebhookActive) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		if r.Context().Err() != nil {
+			return
+		}
+		log.Printf("❌ Error getting bot updates: %v", err)
+		http.Error(w, "Failed to get updates", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *BotHandler) SetWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.SetWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	bot := middleware.PrincipalFromContext(r.Context())
+	if err := h.botService.SetWebhook(r.Context(), bot.ID, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Webhook set"})
+}
+
+func (h *BotHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	bot := middleware.PrincipalFromContext(r.Context())
+	if err := h.botService.DeleteWebhook(r.Context(), bot.ID); err != nil {
+		log.Printf("❌ Error deleting bot webhook: %v", err)
+		http.Error(w, "Failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Webhook deleted"})
+}
+
+func (h *BotHandler) SetCommands(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.SetCommandsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	bot := middleware.PrincipalFromContext(r.Context())
+	if err := h.botService.SetCommands(r.Context(), bot.ID, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Commands set"})
+}
+
+func (h *BotHandler) SendEphemeral(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.SendEphemeralRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	bot := middleware.PrincipalFromContext(r.Context())
+	if err := h.botService.SendEphemeral(r.Context(), bot.ID, req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Sent"})
+}
+
+func extractBotID(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) >= 3 && parts[0] == "api" && parts[1] == "bots" {
+		return parts[2]
+	}
+	return ""
+}
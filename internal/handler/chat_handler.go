@@ -8,6 +8,7 @@ import (
 	"strconv"
 	"strings"
 
+	"Flare-server/internal/middleware"
 	"Flare-server/internal/models"
 	"Flare-server/internal/service"
 )
@@ -137,11 +138,18 @@ func (h *ChatHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 	message, err := h.chatService.SendMessage(r.Context(), chatID, userInfo.ID, userInfo.Username, req)
 	if err != nil {
 		log.Printf("❌ Error sending message: %v", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		if !middleware.WriteRateLimitError(w, err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	if message == nil {
+		// A slash command that only replied to the sender over WebSocket.
+		json.NewEncoder(w).Encode(map[string]string{"message": "Command executed"})
+		return
+	}
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(message)
 }
@@ -315,6 +323,145 @@ func (h *ChatHandler) UpdateChat(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"message": "Chat updated successfully"})
 }
 
+func (h *ChatHandler) GetChatFilters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chatID := extractChatID(r.URL.Path)
+	if chatID == "" {
+		http.Error(w, "Chat ID is required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	settings, err := h.chatService.GetChatFilters(r.Context(), chatID, userInfo.ID)
+	if err != nil {
+		log.Printf("❌ Error getting chat filters: %v", err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+func (h *ChatHandler) UpdateChatFilters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chatID := extractChatID(r.URL.Path)
+	if chatID == "" {
+		http.Error(w, "Chat ID is required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.ChatFilterSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := h.chatService.UpdateChatFilters(r.Context(), chatID, userInfo.ID, req)
+	if err != nil {
+		log.Printf("❌ Error updating chat filters: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+func (h *ChatHandler) MuteChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chatID := extractChatID(r.URL.Path)
+	if chatID == "" {
+		http.Error(w, "Chat ID is required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.MuteChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	until, err := h.chatService.MuteChat(r.Context(), chatID, userInfo.ID, req)
+	if err != nil {
+		log.Printf("❌ Error muting chat: %v", err)
+		status := http.StatusBadRequest
+		if strings.HasPrefix(err.Error(), "access denied") {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"muted":      req.Muted,
+		"mutedUntil": until,
+	})
+}
+
+func (h *ChatHandler) MarkChatRead(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chatID := extractChatID(r.URL.Path)
+	if chatID == "" {
+		http.Error(w, "Chat ID is required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	readAt, err := h.chatService.MarkChatRead(r.Context(), chatID, userInfo.ID)
+	if err != nil {
+		log.Printf("❌ Error marking chat as read: %v", err)
+		status := http.StatusInternalServerError
+		if strings.HasPrefix(err.Error(), "access denied") {
+			status = http.StatusForbidden
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"lastReadAt": readAt})
+}
+
 func (h *ChatHandler) DeleteChat(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -343,34 +490,39 @@ func (h *ChatHandler) DeleteChat(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"message": "Chat deleted successfully"})
 }
 
+// GetChatCommands lists the slash commands the caller can use in the chat.
+func (h *ChatHandler) GetChatCommands(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-type UserInfo struct {
-	ID       string
-	Username string
-}
+	chatID := extractChatID(r.URL.Path)
+	if chatID == "" {
+		http.Error(w, "Chat ID is required", http.StatusBadRequest)
+		return
+	}
 
-func getUserFromContext(ctx context.Context) *UserInfo {
-	userInfo := ctx.Value("user")
+	userInfo := getUserFromContext(r.Context())
 	if userInfo == nil {
-		return nil
-	}
-	
-	userMap, ok := userInfo.(map[string]interface{})
-	if !ok {
-		return nil
-	}
-	
-	userID, hasID := userMap["userID"].(string)
-	username, hasUsername := userMap["username"].(string)
-	
-	if !hasID || !hasUsername {
-		return nil
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
 	}
-	
-	return &UserInfo{
-		ID:       userID,
-		Username: username,
+
+	commands, err := h.chatService.GetChatCommands(r.Context(), chatID, userInfo.ID)
+	if err != nil {
+		log.Printf("❌ Error getting chat commands: %v", err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ChatCommandsResponse{Commands: commands})
+}
+
+// getUserFromContext returns the user authenticated by AuthMiddleware, or nil.
+func getUserFromContext(ctx context.Context) *middleware.Principal {
+	return middleware.PrincipalFromContext(ctx)
 }
 
 func extractChatID(path string) string {
@@ -380,3 +532,11 @@ func extractChatID(path string) string {
 	}
 	return ""
 }
+
+func extractSubresourceID(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) >= 5 && parts[0] == "api" && parts[1] == "chats" {
+		return parts[4]
+	}
+	return ""
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/service"
+)
+
+type ContactHandler struct {
+	contactService *service.ContactService
+}
+
+func NewContactHandler(contactService *service.ContactService) *ContactHandler {
+	return &ContactHandler{
+		contactService: contactService,
+	}
+}
+
+func (h *ContactHandler) GetContacts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	contacts, err := h.contactService.GetContacts(r.Context(), userInfo.ID)
+	if err != nil {
+		log.Printf("❌ Error getting contacts: %v", err)
+		http.Error(w, "Failed to get contacts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(contacts)
+}
+
+func (h *ContactHandler) UpdateContact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contactID := extractContactPathID(r.URL.Path)
+	if contactID == "" {
+		http.Error(w, "Contact ID is required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.UpdateContactRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.contactService.UpdateContact(r.Context(), userInfo.ID, contactID, req); err != nil {
+		log.Printf("❌ Error updating contact: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Contact updated"})
+}
+
+func (h *ContactHandler) RemoveContact(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contactID := extractContactPathID(r.URL.Path)
+	if contactID == "" {
+		http.Error(w, "Contact ID is required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.contactService.RemoveContact(r.Context(), userInfo.ID, contactID); err != nil {
+		log.Printf("❌ Error removing contact: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Contact removed"})
+}
+
+func (h *ContactHandler) GetFriendRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	direction := r.URL.Query().Get("direction")
+	if direction != "" && direction != "incoming" && direction != "outgoing" {
+		http.Error(w, "direction must be incoming or outgoing", http.StatusBadRequest)
+		return
+	}
+
+	requests, err := h.contactService.GetFriendRequests(r.Context(), userInfo.ID, direction != "outgoing")
+	if err != nil {
+		log.Printf("❌ Error getting friend requests: %v", err)
+		http.Error(w, "Failed to get friend requests", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(requests)
+}
+
+func (h *ContactHandler) SendFriendRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.SendFriendRequestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	friendRequest, err := h.contactService.SendFriendRequest(r.Context(), userInfo.ID, userInfo.Username, req.Username)
+	if err != nil {
+		log.Printf("❌ Error sending friend request: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(friendRequest)
+}
+
+func (h *ContactHandler) AcceptFriendRequest(w http.ResponseWriter, r *http.Request) {
+	h.resolveFriendRequest(w, r, http.MethodPost, h.contactService.AcceptFriendRequest)
+}
+
+func (h *ContactHandler) DeclineFriendRequest(w http.ResponseWriter, r *http.Request) {
+	h.resolveFriendRequest(w, r, http.MethodPost, h.contactService.DeclineFriendRequest)
+}
+
+func (h *ContactHandler) CancelFriendRequest(w http.ResponseWriter, r *http.Request) {
+	h.resolveFriendRequest(w, r, http.MethodDelete, h.contactService.CancelFriendRequest)
+}
+
+type friendRequestAction func(ctx context.Context, requestID, userID string) (*models.FriendRequest, error)
+
+func (h *ContactHandler) resolveFriendRequest(w http.ResponseWriter, r *http.Request, method string, action friendRequestAction) {
+	if r.Method != method {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	requestID := extractFriendRequestID(r.URL.Path)
+	if requestID == "" {
+		http.Error(w, "Request ID is required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	friendRequest, err := action(r.Context(), requestID, userInfo.ID)
+	if err != nil {
+		log.Printf("❌ Error resolving friend request: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(friendRequest)
+}
+
+func extractContactPathID(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) >= 3 && parts[0] == "api" && parts[1] == "contacts" {
+		return parts[2]
+	}
+	return ""
+}
+
+func extractFriendRequestID(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) >= 4 && parts[0] == "api" && parts[1] == "contacts" && parts[2] == "requests" {
+		return parts[3]
+	}
+	return ""
+}
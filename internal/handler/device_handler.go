@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/service"
+)
+
+type DeviceHandler struct {
+	pushService *service.PushService
+}
+
+func NewDeviceHandler(pushService *service.PushService) *DeviceHandler {
+	return &DeviceHandler{
+		pushService: pushService,
+	}
+}
+
+func (h *DeviceHandler) GetDevices(w http.ResponseWriter, r *http.Request) {
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	devices, err := h.pushService.GetDevices(r.Context(), userInfo.ID)
+	if err != nil {
+		log.Printf("❌ Error getting devices: %v", err)
+		http.Error(w, "Failed to get devices", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.DeviceListResponse{Devices: devices})
+}
+
+func (h *DeviceHandler) RegisterDevice(w http.ResponseWriter, r *http.Request) {
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.RegisterDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	device, err := h.pushService.RegisterDevice(r.Context(), userInfo.ID, req)
+	if err != nil {
+		log.Printf("❌ Error registering device: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(device)
+}
+
+func (h *DeviceHandler) DeleteDevice(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	deviceID := extractDeviceID(r.URL.Path)
+	if deviceID == "" {
+		http.Error(w, "Device ID is required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.pushService.DeleteDevice(r.Context(), userInfo.ID, deviceID); err != nil {
+		log.Printf("❌ Error deleting device: %v", err)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Device removed"})
+}
+
+func extractDeviceID(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) == 3 && parts[0] == "api" && parts[1] == "devices" {
+		return parts[2]
+	}
+	return ""
+}
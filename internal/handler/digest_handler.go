@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/service"
+)
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head><meta charset="utf-8"><title>Flare</title></head>
+<body style="font-family:Arial,sans-serif;max-width:480px;margin:48px auto;color:#1f2328;">
+{{if .Done}}
+<p>Вы отписались от дайджеста непрочитанных сообщений. Включить его снова можно в настройках Flare.</p>
+{{else if .Invalid}}
+<p>Ссылка для отписки недействительна.</p>
+{{else}}
+<p>Отписаться от дайджеста непрочитанных сообщений?</p>
+<form method="post">
+<input type="hidden" name="token" value="{{.Token}}">
+<button type="submit">Отписаться</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+type DigestHandler struct {
+	digestService *service.DigestService
+}
+
+func NewDigestHandler(digestService *service.DigestService) *DigestHandler {
+	return &DigestHandler{
+		digestService: digestService,
+	}
+}
+
+func (h *DigestHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	settings, err := h.digestService.GetSettings(r.Context(), userInfo.ID)
+	if err != nil {
+		log.Printf("❌ Error getting digest settings: %v", err)
+		http.Error(w, "Failed to get digest settings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+func (h *DigestHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.UpdateDigestSettingsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := h.digestService.UpdateSettings(r.Context(), userInfo.ID, req)
+	if err != nil {
+		log.Printf("❌ Error updating digest settings: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// Unsubscribe handles the link in digest emails. GET only shows a confirmation
+// form, so link scanners cannot unsubscribe anyone; POST, which is also what
+// one-click List-Unsubscribe sends, turns digests off.
+func (h *DigestHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	token := r.FormValue("token")
+	page := struct {
+		Token   string
+		Done    bool
+		Invalid bool
+	}{Token: token}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		err := h.digestService.Unsubscribe(r.Context(), token)
+		switch {
+		case errors.Is(err, service.ErrInvalidUnsubscribeToken):
+			page.Invalid = true
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(http.StatusBadRequest)
+			unsubscribePage.Execute(w, page)
+			return
+		case err != nil:
+			log.Printf("❌ Error unsubscribing from digests: %v", err)
+			http.Error(w, "Failed to unsubscribe", http.StatusInternalServerError)
+			return
+		}
+		page.Done = true
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	unsubscribePage.Execute(w, page)
+}
@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"Flare-server/internal/middleware"
+	"Flare-server/internal/models"
+	"Flare-server/internal/service"
+)
+
+// maxIncomingWebhookBodySize leaves room for a message of the longest allowed
+// length plus the display name and avatar URL.
+const maxIncomingWebhookBodySize = 64 * 1024
+
+type IncomingWebhookHandler struct {
+	hookService *service.IncomingWebhookService
+}
+
+func NewIncomingWebhookHandler(hookService *service.IncomingWebhookService) *IncomingWebhookHandler {
+	return &IncomingWebhookHandler{
+		hookService: hookService,
+	}
+}
+
+func (h *IncomingWebhookHandler) CreateIncomingWebhook(w http.ResponseWriter, r *http.Request) {
+	chatID := extractChatID(r.URL.Path)
+	if chatID == "" {
+		http.Error(w, "Chat ID is required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateIncomingWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	response, err := h.hookService.CreateIncomingWebhook(r.Context(), chatID, userInfo.ID, req)
+	if err != nil {
+		log.Printf("❌ Error creating incoming webhook: %v", err)
+		http.Error(w, err.Error(), webhookErrorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *IncomingWebhookHandler) GetIncomingWebhooks(w http.ResponseWriter, r *http.Request) {
+	chatID := extractChatID(r.URL.Path)
+	if chatID == "" {
+		http.Error(w, "Chat ID is required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	hooks, err := h.hookService.GetIncomingWebhooks(r.Context(), chatID, userInfo.ID)
+	if err != nil {
+		log.Printf("❌ Error getting incoming webhooks: %v", err)
+		http.Error(w, err.Error(), webhookErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.IncomingWebhookListResponse{Webhooks: hooks})
+}
+
+func (h *IncomingWebhookHandler) DeleteIncomingWebhook(w http.ResponseWriter, r *http.Request) {
+	chatID := extractChatID(r.URL.Path)
+	hookID := extractSubresourceID(r.URL.Path)
+	if chatID == "" || hookID == "" {
+		http.Error(w, "Chat ID and webhook ID are required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.hookService.DeleteIncomingWebhook(r.Context(), chatID, hookID, userInfo.ID); err != nil {
+		log.Printf("❌ Error deleting incoming webhook: %v", err)
+		http.Error(w, err.Error(), webhookErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Incoming webhook deleted"})
+}
+
+// Post handles POST /api/hooks/{hookId}/{secret}. The URL itself is the credential.
+func (h *IncomingWebhookHandler) Post(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	hookID, secret := extractIncomingWebhookCredentials(r.URL.Path)
+	if hookID == "" || secret == "" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxIncomingWebhookBodySize)
+
+	var req models.IncomingWebhookMessage
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	message, err := h.hookService.Post(r.Context(), hookID, secret, req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidIncomingWebhook) {
+			http.Error(w, "Not found", http.StatusNotFound)
+			return
+		}
+		if !middleware.WriteRateLimitError(w, err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(message)
+}
+
+func extractIncomingWebhookCredentials(path string) (string, string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) == 4 && parts[0] == "api" && parts[1] == "hooks" {
+		return parts[2], parts[3]
+	}
+	return "", ""
+}
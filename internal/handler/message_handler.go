@@ -41,24 +41,12 @@ func (h *MessageHandler) PostMessage(w http.ResponseWriter, r *http.Request) {
 		Text string `json:"text"`
 	}
 
-	userInfo := r.Context().Value("user")
+	userInfo := getUserFromContext(r.Context())
 	if userInfo == nil {
 		http.Error(w, "User not authenticated", http.StatusUnauthorized)
 		return
 	}
 
-	userMap, ok := userInfo.(map[string]interface{})
-	if !ok {
-		http.Error(w, "Invalid user context", http.StatusInternalServerError)
-		return
-	}
-
-	username, hasUsername := userMap["username"].(string)
-	if !hasUsername {
-		http.Error(w, "Username not found in context", http.StatusInternalServerError)
-		return
-	}
-
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
@@ -72,7 +60,7 @@ func (h *MessageHandler) PostMessage(w http.ResponseWriter, r *http.Request) {
 	msg := repository.Message{
 		ID:         time.Now().Format("20060102150405.999999999"),
 		Text:       input.Text,
-		SenderName: username,
+		SenderName: userInfo.Username,
 		Timestamp:  time.Now().UnixMilli(),
 	}
 
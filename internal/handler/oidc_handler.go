@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"Flare-server/internal/repository"
+	"Flare-server/internal/service"
+)
+
+type OIDCHandler struct {
+	oidcService *service.OIDCService
+}
+
+func NewOIDCHandler(oidcService *service.OIDCService) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: oidcService,
+	}
+}
+
+func (h *OIDCHandler) GetProviders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"providers": h.oidcService.Providers()})
+}
+
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	authURL, err := h.oidcService.AuthCodeURL(r.Context(), extractOIDCProvider(r.URL.Path))
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownOIDCProvider) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("❌ Error starting OIDC login: %v", err)
+		http.Error(w, "Failed to start login", http.StatusBadGateway)
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		http.Error(w, "Login failed: "+providerErr, http.StatusBadRequest)
+		return
+	}
+	if query.Get("code") == "" || query.Get("state") == "" {
+		http.Error(w, "code and state are required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.oidcService.HandleCallback(r.Context(), extractOIDCProvider(r.URL.Path), query.Get("code"), query.Get("state"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUnknownOIDCProvider):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, service.ErrAccountSuspended):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, service.ErrExternalEmailInUse), errors.Is(err, repository.ErrIdentityLinked):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			log.Printf("❌ Error completing OIDC login: %v", err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case result.LinkedUserID != "":
+		json.NewEncoder(w).Encode(map[string]string{"message": "Identity linked"})
+	case result.ChallengeToken != "":
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"twoFactorRequired": true,
+			"challengeToken":    result.ChallengeToken,
+		})
+	default:
+		json.NewEncoder(w).Encode(map[string]string{"token": result.Token})
+	}
+}
+
+// Link returns the provider URL that links the identity to the signed-in user.
+// The browser is sent there by the client, since the redirect cannot carry the
+// Authorization header.
+func (h *OIDCHandler) Link(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	authURL, err := h.oidcService.LinkURL(r.Context(), extractOIDCProvider(r.URL.Path), userInfo.ID)
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownOIDCProvider) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		log.Printf("❌ Error starting OIDC link: %v", err)
+		http.Error(w, "Failed to start linking", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"url": authURL})
+}
+
+func extractOIDCProvider(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) >= 4 && parts[0] == "api" && parts[1] == "auth" && parts[2] == "oidc" {
+		return parts[3]
+	}
+	return ""
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"Flare-server/internal/middleware"
+	"Flare-server/internal/models"
+	"Flare-server/internal/service"
+)
+
+type PollHandler struct {
+	pollService *service.PollService
+	wsHandler   *WebSocketHandler
+}
+
+func NewPollHandler(pollService *service.PollService, wsHandler *WebSocketHandler) *PollHandler {
+	return &PollHandler{
+		pollService: pollService,
+		wsHandler:   wsHandler,
+	}
+}
+
+func (h *PollHandler) CreatePoll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chatID := extractChatID(r.URL.Path)
+	if chatID == "" {
+		http.Error(w, "Chat ID is required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreatePollRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	message, err := h.pollService.CreatePoll(r.Context(), chatID, userInfo.ID, userInfo.Username, req)
+	if err != nil {
+		log.Printf("❌ Error creating poll: %v", err)
+		if !middleware.WriteRateLimitError(w, err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	h.wsHandler.broadcastNewMessage(r.Context(), message)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(message)
+}
+
+func (h *PollHandler) Vote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chatID, messageID, userInfo, ok := h.pollRequestContext(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.PollVoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	update, err := h.pollService.Vote(r.Context(), chatID, messageID, userInfo.ID, req.OptionIDs)
+	if err != nil {
+		log.Printf("❌ Error voting in poll: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.respondPollUpdate(w, update)
+}
+
+func (h *PollHandler) RetractVote(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chatID, messageID, userInfo, ok := h.pollRequestContext(w, r)
+	if !ok {
+		return
+	}
+
+	update, err := h.pollService.RetractVote(r.Context(), chatID, messageID, userInfo.ID)
+	if err != nil {
+		log.Printf("❌ Error retracting poll vote: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.respondPollUpdate(w, update)
+}
+
+func (h *PollHandler) ClosePoll(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chatID, messageID, userInfo, ok := h.pollRequestContext(w, r)
+	if !ok {
+		return
+	}
+
+	update, err := h.pollService.ClosePoll(r.Context(), chatID, messageID, userInfo.ID)
+	if err != nil {
+		log.Printf("❌ Error closing poll: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.respondPollUpdate(w, update)
+}
+
+func (h *PollHandler) pollRequestContext(w http.ResponseWriter, r *http.Request) (string, string, *middleware.Principal, bool) {
+	chatID := extractChatID(r.URL.Path)
+	messageID := extractSubresourceID(r.URL.Path)
+	if chatID == "" || messageID == "" {
+		http.Error(w, "Chat ID and poll ID are required", http.StatusBadRequest)
+		return "", "", nil, false
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return "", "", nil, false
+	}
+
+	return chatID, messageID, userInfo, true
+}
+
+func (h *PollHandler) respondPollUpdate(w http.ResponseWriter, update *models.PollUpdate) {
+	h.wsHandler.BroadcastMessage(update.ChatID, "poll_updated", update)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(update)
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/service"
+)
+
+type ScheduleHandler struct {
+	scheduleService *service.ScheduleService
+}
+
+func NewScheduleHandler(scheduleService *service.ScheduleService) *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduleService: scheduleService,
+	}
+}
+
+func (h *ScheduleHandler) ScheduleMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chatID := extractChatID(r.URL.Path)
+	if chatID == "" {
+		http.Error(w, "Chat ID is required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.ScheduleMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	scheduled, err := h.scheduleService.ScheduleMessage(r.Context(), chatID, userInfo.ID, userInfo.Username, req)
+	if err != nil {
+		log.Printf("❌ Error scheduling message: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(scheduled)
+}
+
+func (h *ScheduleHandler) GetScheduledMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chatID := extractChatID(r.URL.Path)
+	if chatID == "" {
+		http.Error(w, "Chat ID is required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	messages, err := h.scheduleService.GetScheduledMessages(r.Context(), chatID, userInfo.ID)
+	if err != nil {
+		log.Printf("❌ Error getting scheduled messages: %v", err)
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.ScheduledMessagesResponse{ScheduledMessages: messages})
+}
+
+func (h *ScheduleHandler) UpdateScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chatID := extractChatID(r.URL.Path)
+	scheduledID := extractSubresourceID(r.URL.Path)
+	if chatID == "" || scheduledID == "" {
+		http.Error(w, "Chat ID and scheduled message ID are required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.UpdateScheduledMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	scheduled, err := h.scheduleService.UpdateScheduledMessage(r.Context(), chatID, scheduledID, userInfo.ID, req)
+	if err != nil {
+		log.Printf("❌ Error updating scheduled message: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scheduled)
+}
+
+func (h *ScheduleHandler) CancelScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	chatID := extractChatID(r.URL.Path)
+	scheduledID := extractSubresourceID(r.URL.Path)
+	if chatID == "" || scheduledID == "" {
+		http.Error(w, "Chat ID and scheduled message ID are required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.scheduleService.CancelScheduledMessage(r.Context(), chatID, scheduledID, userInfo.ID); err != nil {
+		log.Printf("❌ Error cancelling scheduled message: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": "Scheduled message cancelled"})
+}
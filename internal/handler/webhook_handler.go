@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/service"
+)
+
+type WebhookHandler struct {
+	webhookService *service.WebhookService
+}
+
+func NewWebhookHandler(webhookService *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	chatID := extractChatID(r.URL.Path)
+	if chatID == "" {
+		http.Error(w, "Chat ID is required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := h.webhookService.CreateWebhook(r.Context(), chatID, userInfo.ID, req)
+	if err != nil {
+		log.Printf("❌ Error creating webhook: %v", err)
+		http.Error(w, err.Error(), webhookErrorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(webhook)
+}
+
+func (h *WebhookHandler) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	chatID := extractChatID(r.URL.Path)
+	if chatID == "" {
+		http.Error(w, "Chat ID is required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	webhooks, err := h.webhookService.GetWebhooks(r.Context(), chatID, userInfo.ID)
+	if err != nil {
+		log.Printf("❌ Error getting webhooks: %v", err)
+		http.Error(w, err.Error(), webhookErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.WebhookListResponse{Webhooks: webhooks})
+}
+
+func (h *WebhookHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	chatID := extractChatID(r.URL.Path)
+	webhookID := extractSubresourceID(r.URL.Path)
+	if chatID == "" || webhookID == "" {
+		http.Error(w, "Chat ID and webhook ID are required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req models.UpdateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := h.webhookService.UpdateWebhook(r.Context(), chatID, webhookID, userInfo.ID, req)
+	if err != nil {
+		log.Printf("❌ Error updating webhook: %v", err)
+		http.Error(w, err.Error(), webhookErrorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhook)
+}
+
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	chatID := extractChatID(r.URL.Path)
+	webhookID := extractSubresourceID(r.URL.Path)
+	if chatID == "" || webhookID == "" {
+		http.Error(w, "Chat ID and webhook ID are required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.webhookService.DeleteWebhook(r.Context(), chatID, webhookID, userInfo.ID); err != nil {
+		log.Printf("❌ Error deleting webhook: %v", err)
+		http.Error(w, err.Error(), webhookErrorStatus(err, http.StatusInternalServerError))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Webhook deleted"})
+}
+
+func (h *WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	chatID := extractChatID(r.URL.Path)
+	webhookID := extractSubresourceID(r.URL.Path)
+	if chatID == "" || webhookID == "" {
+		http.Error(w, "Chat ID and webhook ID are required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	status := models.WebhookDeliveryStatus(r.URL.Query().Get("status"))
+	deliveries, err := h.webhookService.GetDeliveries(r.Context(), chatID, webhookID, userInfo.ID, status)
+	if err != nil {
+		log.Printf("❌ Error getting webhook deliveries: %v", err)
+		http.Error(w, err.Error(), webhookErrorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(models.WebhookDeliveriesResponse{Deliveries: deliveries})
+}
+
+func (h *WebhookHandler) RetryDelivery(w http.ResponseWriter, r *http.Request) {
+	chatID := extractChatID(r.URL.Path)
+	webhookID := extractSubresourceID(r.URL.Path)
+	deliveryID := extractDeliveryID(r.URL.Path)
+	if chatID == "" || webhookID == "" || deliveryID == "" {
+		http.Error(w, "Chat ID, webhook ID and delivery ID are required", http.StatusBadRequest)
+		return
+	}
+
+	userInfo := getUserFromContext(r.Context())
+	if userInfo == nil {
+		http.Error(w, "User not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.webhookService.RetryDelivery(r.Context(), chatID, webhookID, deliveryID, userInfo.ID); err != nil {
+		log.Printf("❌ Error retrying webhook delivery: %v", err)
+		http.Error(w, err.Error(), webhookErrorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Delivery queued"})
+}
+
+func webhookErrorStatus(err error, fallback int) int {
+	switch {
+	case strings.HasPrefix(err.Error(), "access denied"):
+		return http.StatusForbidden
+	case strings.HasSuffix(err.Error(), "not found"):
+		return http.StatusNotFound
+	}
+	return fallback
+}
+
+// extractDeliveryID returns {deliveryId} from
+// /api/chats/{id}/webhooks/{webhookId}/deliveries/{deliveryId}/retry.
+func extractDeliveryID(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) >= 7 && parts[0] == "api" && parts[1] == "chats" && parts[5] == "deliveries" {
+		return parts[6]
+	}
+	return ""
+}
@@ -3,12 +3,15 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"sync"
 	"time"
 
+	"Flare-server/internal/middleware"
 	"Flare-server/internal/models"
+	"Flare-server/internal/ratelimit"
 	"Flare-server/internal/service"
 
 	"github.com/gorilla/websocket"
@@ -25,6 +28,9 @@ type WebSocketMessage struct {
 	ChatID  string      `json:"chatId,omitempty"`
 	Data    interface{} `json:"data,omitempty"`
 	Error   string      `json:"error,omitempty"`
+
+	// skipUsers lists users that must not receive a chat broadcast.
+	skipUsers map[string]bool
 }
 
 type Client struct {
@@ -34,6 +40,9 @@ type Client struct {
 	Conn     *websocket.Conn
 	Send     chan WebSocketMessage
 	Hub      *Hub
+
+	// violations counts consecutive rate-limited frames. Only readPump touches it.
+	violations int
 }
 
 type Hub struct {
@@ -45,13 +54,23 @@ type Hub struct {
 	mutex      sync.RWMutex
 }
 
+// WebSocketLimits are the per-user limits for incoming frames. A client that keeps
+// sending after being limited DisconnectAfter times in a row is disconnected.
+type WebSocketLimits struct {
+	Messages        *ratelimit.Limiter
+	Typing          *ratelimit.Limiter
+	DisconnectAfter int
+}
+
 type WebSocketHandler struct {
 	hub         *Hub
 	chatService *service.ChatService
 	authService *service.AuthService
+	pollService *service.PollService
+	limits      WebSocketLimits
 }
 
-func NewWebSocketHandler(chatService *service.ChatService, authService *service.AuthService) *WebSocketHandler {
+func NewWebSocketHandler(chatService *service.ChatService, authService *service.AuthService, pollService *service.PollService, limits WebSocketLimits) *WebSocketHandler {
 	hub := &Hub{
 		clients:    make(map[*Client]bool),
 		broadcast:  make(chan WebSocketMessage),
@@ -64,6 +83,8 @@ func NewWebSocketHandler(chatService *service.ChatService, authService *service.
 		hub:         hub,
 		chatService: chatService,
 		authService: authService,
+		pollService: pollService,
+		limits:      limits,
 	}
 
 	go hub.run()
@@ -85,6 +106,10 @@ func (h *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Reques
 	}
 
 	userInfo, err := h.validateToken(token)
+	if errors.Is(err, service.ErrAccountSuspended) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Invalid token", http.StatusUnauthorized)
 		return
@@ -143,6 +168,9 @@ func (h *Hub) run() {
 			if message.ChatID != "" {
 				if clients, exists := h.chatRooms[message.ChatID]; exists {
 					for client := range clients {
+						if message.skipUsers[client.UserID] {
+							continue
+						}
 						select {
 						case client.Send <- message:
 						default:
@@ -190,6 +218,18 @@ func (c *Client) readPump(handler *WebSocketHandler) {
 			break
 		}
 
+		allowed, disconnect := handler.checkRateLimit(c, msg)
+		if disconnect {
+			log.Printf("Disconnecting %s for exceeding rate limits", c.Username)
+			c.Conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "rate limit exceeded"),
+				time.Now().Add(time.Second))
+			break
+		}
+		if !allowed {
+			continue
+		}
+
 		handler.handleMessage(c, msg)
 	}
 }
@@ -234,6 +274,8 @@ func (h *WebSocketHandler) handleMessage(client *Client, msg WebSocketMessage) {
 		h.handleSendMessage(client, msg)
 	case "typing":
 		h.handleTyping(client, msg)
+	case "poll_vote", "poll_retract", "poll_close":
+		h.handlePollAction(client, msg)
 	default:
 		client.Send <- WebSocketMessage{
 			Type:  "error",
@@ -242,6 +284,48 @@ func (h *WebSocketHandler) handleMessage(client *Client, msg WebSocketMessage) {
 	}
 }
 
+// checkRateLimit applies the frame limits and answers limited frames with an error
+// frame carrying retryAfter in seconds.
+func (h *WebSocketHandler) checkRateLimit(client *Client, msg WebSocketMessage) (allowed bool, disconnect bool) {
+	var limiter *ratelimit.Limiter
+	switch msg.Type {
+	case "send_message", "poll_vote", "poll_retract", "poll_close":
+		limiter = h.limits.Messages
+	case "typing":
+		limiter = h.limits.Typing
+	}
+	if limiter == nil {
+		return true, false
+	}
+
+	err := limiter.Allow(context.Background(), "user:"+client.UserID)
+	if err == nil {
+		client.violations = 0
+		return true, false
+	}
+
+	client.violations++
+	if h.limits.DisconnectAfter > 0 && client.violations >= h.limits.DisconnectAfter {
+		return false, true
+	}
+
+	client.Send <- errorFrame(err)
+	return false, false
+}
+
+func errorFrame(err error) WebSocketMessage {
+	frame := WebSocketMessage{
+		Type:  "error",
+		Error: err.Error(),
+	}
+
+	var limitErr *ratelimit.LimitError
+	if errors.As(err, &limitErr) {
+		frame.Data = map[string]interface{}{"retryAfter": limitErr.RetryAfterSeconds()}
+	}
+	return frame
+}
+
 func (h *WebSocketHandler) handleJoinChat(client *Client, msg WebSocketMessage) {
 	chatID, ok := msg.Data.(string)
 	if !ok {
@@ -315,18 +399,19 @@ func (h *WebSocketHandler) handleSendMessage(client *Client, msg WebSocketMessag
 	req := models.SendMessageRequest{Text: messageData.Text}
 	message, err := h.chatService.SendMessage(ctx, messageData.ChatID, client.UserID, client.Username, req)
 	if err != nil {
-		client.Send <- WebSocketMessage{
-			Type:  "error",
-			Error: err.Error(),
-		}
+		client.Send <- errorFrame(err)
 		return
 	}
-
-	h.hub.broadcast <- WebSocketMessage{
-		Type:   "new_message",
-		ChatID: messageData.ChatID,
-		Data:   message,
+	if message == nil {
+		return
 	}
+
+	h.broadcastNewMessage(ctx, message)
+}
+
+// broadcastNewMessage skips members who hid the sender's messages.
+func (h *WebSocketHandler) broadcastNewMessage(ctx context.Context, message *models.Message) {
+	h.BroadcastMessageExcept(message.ChatID, "new_message", message, h.chatService.MessageRecipientsToSkip(ctx, message.SenderID))
 }
 
 func (h *WebSocketHandler) handleTyping(client *Client, msg WebSocketMessage) {
@@ -342,6 +427,49 @@ func (h *WebSocketHandler) handleTyping(client *Client, msg WebSocketMessage) {
 			"userId":   client.UserID,
 			"username": client.Username,
 		},
+		skipUsers: h.chatService.PresenceRecipientsToSkip(context.Background(), client.UserID),
+	}
+}
+
+func (h *WebSocketHandler) handlePollAction(client *Client, msg WebSocketMessage) {
+	var pollData struct {
+		ChatID    string `json:"chatId"`
+		MessageID string `json:"messageId"`
+		OptionIDs []int  `json:"optionIds"`
+	}
+
+	dataBytes, _ := json.Marshal(msg.Data)
+	if err := json.Unmarshal(dataBytes, &pollData); err != nil {
+		client.Send <- WebSocketMessage{
+			Type:  "error",
+			Error: "Invalid poll data",
+		}
+		return
+	}
+
+	ctx := context.Background()
+	var update *models.PollUpdate
+	var err error
+	switch msg.Type {
+	case "poll_vote":
+		update, err = h.pollService.Vote(ctx, pollData.ChatID, pollData.MessageID, client.UserID, pollData.OptionIDs)
+	case "poll_retract":
+		update, err = h.pollService.RetractVote(ctx, pollData.ChatID, pollData.MessageID, client.UserID)
+	case "poll_close":
+		update, err = h.pollService.ClosePoll(ctx, pollData.ChatID, pollData.MessageID, client.UserID)
+	}
+	if err != nil {
+		client.Send <- WebSocketMessage{
+			Type:  "error",
+			Error: err.Error(),
+		}
+		return
+	}
+
+	h.hub.broadcast <- WebSocketMessage{
+		Type:   "poll_updated",
+		ChatID: update.ChatID,
+		Data:   update,
 	}
 }
 
@@ -353,14 +481,77 @@ func (h *WebSocketHandler) BroadcastMessage(chatID string, messageType string, d
 	}
 }
 
+// BroadcastMessageExcept is BroadcastMessage that skips the given users.
+func (h *WebSocketHandler) BroadcastMessageExcept(chatID string, messageType string, data interface{}, skipUserIDs map[string]bool) {
+	h.hub.broadcast <- WebSocketMessage{
+		Type:      messageType,
+		ChatID:    chatID,
+		Data:      data,
+		skipUsers: skipUserIDs,
+	}
+}
 
-func (h *WebSocketHandler) validateToken(token string) (*UserInfo, error) {
-	return &UserInfo{
-		ID:       "user123",
-		Username: "testuser",
+// SendToUser delivers an event to every connection of a user regardless of which
+// chats they have joined. Slow clients miss the event rather than block the caller.
+func (h *WebSocketHandler) SendToUser(userID string, messageType string, data interface{}) {
+	message := WebSocketMessage{
+		Type: messageType,
+		Data: data,
+	}
+
+	h.hub.mutex.RLock()
+	defer h.hub.mutex.RUnlock()
+
+	for client := range h.hub.clients {
+		if client.UserID != userID {
+			continue
+		}
+		select {
+		case client.Send <- message:
+		default:
+			log.Printf("Dropping %s event for slow client %s", messageType, client.Username)
+		}
+	}
+}
+
+// IsUserOnline reports whether the user has a connection to this server.
+func (h *WebSocketHandler) IsUserOnline(userID string) bool {
+	h.hub.mutex.RLock()
+	defer h.hub.mutex.RUnlock()
+
+	for client := range h.hub.clients {
+		if client.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *WebSocketHandler) validateToken(token string) (*middleware.Principal, error) {
+	user, err := h.authService.ValidateToken(context.Background(), token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &middleware.Principal{
+		ID:       user.ID,
+		Username: user.Username,
 	}, nil
 }
 
+// DisconnectUser closes every connection of the user on this server, e.g. after the
+// account was suspended.
+func (h *WebSocketHandler) DisconnectUser(userID string) {
+	h.hub.mutex.RLock()
+	defer h.hub.mutex.RUnlock()
+
+	for client := range h.hub.clients {
+		if client.UserID == userID {
+			client.Conn.Close()
+		}
+	}
+}
+
 func generateClientID() string {
 	return time.Now().Format("20060102150405.999999999")
 }
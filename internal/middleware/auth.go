@@ -1,19 +1,13 @@
 package middleware
 
 import (
-	"context"
+	"errors"
 	"net/http"
 	"strings"
 
 	"Flare-server/internal/service"
-
-	"github.com/golang-jwt/jwt/v5"
 )
 
-type contextKey string
-
-const UserContextKey contextKey = "user"
-
 func AuthMiddleware(authService *service.AuthService) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -29,36 +23,57 @@ func AuthMiddleware(authService *service.AuthService) func(http.Handler) http.Ha
 				return
 			}
 
-			isBlacklisted, err := authService.IsTokenBlacklisted(r.Context(), tokenString)
-			if err != nil {
+			user, err := authService.ValidateToken(r.Context(), tokenString)
+			if errors.Is(err, service.ErrRevocationCheckFailed) {
 				http.Error(w, "Failed to validate token", http.StatusInternalServerError)
 				return
 			}
-			if isBlacklisted {
-				http.Error(w, "Token is blacklisted", http.StatusUnauthorized)
+			if err != nil {
+				status := http.StatusUnauthorized
+				if errors.Is(err, service.ErrAccountSuspended) {
+					status = http.StatusForbidden
+				}
+				http.Error(w, err.Error(), status)
 				return
 			}
 
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				return authService.JWTKey, nil
+			ctx := ContextWithPrincipal(r.Context(), &Principal{
+				ID:       user.ID,
+				Username: user.Username,
 			})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
 
-			if err != nil || !token.Valid {
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
+// BotAuthMiddleware authenticates Bot API requests sent with
+// "Authorization: Bot <token>".
+func BotAuthMiddleware(botService *service.BotService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			token := strings.TrimPrefix(authHeader, "Bot ")
+			if authHeader == "" || token == authHeader {
+				http.Error(w, "Bot token required", http.StatusUnauthorized)
 				return
 			}
 
-			if claims, ok := token.Claims.(jwt.MapClaims); ok {
-				userInfo := map[string]interface{}{
-					"userID":   claims["userID"],
-					"username": claims["username"],
+			bot, err := botService.AuthenticateBot(r.Context(), token)
+			if err != nil {
+				status := http.StatusUnauthorized
+				if errors.Is(err, service.ErrAccountSuspended) {
+					status = http.StatusForbidden
 				}
-				ctx := context.WithValue(r.Context(), UserContextKey, userInfo)
-				next.ServeHTTP(w, r.WithContext(ctx))
-			} else {
-				http.Error(w, "Invalid token claims", http.StatusUnauthorized)
+				http.Error(w, err.Error(), status)
 				return
 			}
+
+			ctx := ContextWithPrincipal(r.Context(), &Principal{
+				ID:       bot.ID,
+				Username: bot.Username,
+				Bot:      true,
+			})
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
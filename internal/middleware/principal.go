@@ -0,0 +1,24 @@
+package middleware
+
+import "context"
+
+// Principal is the authenticated user a request is made by.
+type Principal struct {
+	ID       string
+	Username string
+	Bot      bool
+}
+
+// principalKey is unexported so only this package can store a Principal.
+type principalKey struct{}
+
+func ContextWithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the authenticated user, or nil on routes without
+// AuthMiddleware.
+func PrincipalFromContext(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
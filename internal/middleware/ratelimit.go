@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	"Flare-server/internal/ratelimit"
+)
+
+// KeyFunc picks the rate limit key for a request.
+type KeyFunc func(r *http.Request) string
+
+// ByIP keys requests by client IP. Use it for unauthenticated routes.
+func ByIP(r *http.Request) string {
+	return "ip:" + ClientIP(r)
+}
+
+// ByUser keys requests by the authenticated user, falling back to the client IP.
+func ByUser(r *http.Request) string {
+	if principal := PrincipalFromContext(r.Context()); principal != nil {
+		return "user:" + principal.ID
+	}
+	return ByIP(r)
+}
+
+func RateLimit(limiter *ratelimit.Limiter, key KeyFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := limiter.Allow(r.Context(), key(r)); err != nil {
+				WriteRateLimitError(w, err)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WriteRateLimitError writes a 429 with Retry-After if err is a rate limit error
+// and reports whether it did.
+func WriteRateLimitError(w http.ResponseWriter, err error) bool {
+	var limitErr *ratelimit.LimitError
+	if !errors.As(err, &limitErr) {
+		return false
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(limitErr.RetryAfterSeconds()))
+	http.Error(w, err.Error(), http.StatusTooManyRequests)
+	return true
+}
+
+// ClientIP returns the address of the direct peer. X-Forwarded-For is ignored
+// because clients can set it freely.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
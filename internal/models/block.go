@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+type Block struct {
+	BlockerID    string       `json:"-" firestore:"blockerId"`
+	BlockedID    string       `json:"userId" firestore:"blockedId"`
+	HideMessages bool         `json:"hideMessages" firestore:"hideMessages"`
+	CreatedAt    time.Time    `json:"createdAt" firestore:"createdAt"`
+	Profile      *UserProfile `json:"profile,omitempty" firestore:"-"`
+}
+
+type BlockUserRequest struct {
+	Username     string `json:"username"`
+	HideMessages bool   `json:"hideMessages"`
+}
+
+type BlockListResponse struct {
+	Blocks []Block `json:"blocks"`
+}
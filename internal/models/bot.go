@@ -0,0 +1,105 @@
+package models
+
+import "time"
+
+// Bot is a bot account as shown to its owner.
+type Bot struct {
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"displayName,omitempty"`
+	OwnerID     string    `json:"ownerId"`
+	WebhookURL  string    `json:"webhookUrl,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+type CreateBotRequest struct {
+	Username    string `json:"username"`
+	DisplayName string `json:"displayName,omitempty"`
+}
+
+// BotTokenResponse carries a bot token. The token is only shown once.
+type BotTokenResponse struct {
+	Bot   Bot    `json:"bot"`
+	Token string `json:"token"`
+}
+
+type BotListResponse struct {
+	Bots []Bot `json:"bots"`
+}
+
+type BotUpdateType string
+
+const (
+	BotUpdateMessage    BotUpdateType = "message"
+	BotUpdateChatMember BotUpdateType = "chat_member"
+	BotUpdateCommand    BotUpdateType = "command"
+)
+
+// BotUpdate is an event delivered to a bot. UpdateID increases by one for every
+// update of the same bot.
+type BotUpdate struct {
+	BotID      string               `json:"-" firestore:"botId"`
+	UpdateID   int64                `json:"updateId" firestore:"updateId"`
+	Type       BotUpdateType        `json:"type" firestore:"type"`
+	Message    *Message             `json:"message,omitempty" firestore:"message,omitempty"`
+	ChatMember *BotChatMemberUpdate `json:"chatMember,omitempty" firestore:"chatMember,omitempty"`
+	Command    *BotCommandUpdate    `json:"command,omitempty" firestore:"command,omitempty"`
+	CreatedAt  time.Time            `json:"createdAt" firestore:"createdAt"`
+}
+
+type BotChatMemberUpdate struct {
+	ChatID   string `json:"chatId" firestore:"chatId"`
+	UserID   string `json:"userId" firestore:"userId"`
+	Username string `json:"username" firestore:"username"`
+	Status   string `json:"status" firestore:"status"` // joined or left
+}
+
+// BotCommandUpdate is a slash command registered by the bot that a user ran.
+type BotCommandUpdate struct {
+	ChatID   string `json:"chatId" firestore:"chatId"`
+	UserID   string `json:"userId" firestore:"userId"`
+	Username string `json:"username" firestore:"username"`
+	Command  string `json:"command" firestore:"command"`
+	Args     string `json:"args,omitempty" firestore:"args"`
+}
+
+type BotUpdatesResponse struct {
+	Updates []BotUpdate `json:"updates"`
+}
+
+// GetUpdatesRequest confirms every update below Offset and waits up to Timeout
+// seconds for new ones.
+type GetUpdatesRequest struct {
+	Offset  int64 `json:"offset"`
+	Limit   int   `json:"limit,omitempty"`
+	Timeout int   `json:"timeout,omitempty"`
+}
+
+type BotSendMessageRequest struct {
+	ChatID  string `json:"chatId"`
+	Text    string `json:"text"`
+	ReplyTo string `json:"replyTo,omitempty"`
+}
+
+type SetWebhookRequest struct {
+	URL         string `json:"url"`
+	SecretToken string `json:"secretToken,omitempty"`
+}
+
+type BotCommand struct {
+	Command     string `json:"command" firestore:"command"`
+	Description string `json:"description,omitempty" firestore:"description"`
+}
+
+// SetCommandsRequest replaces the bot's commands in one chat. An empty list
+// removes them.
+type SetCommandsRequest struct {
+	ChatID   string       `json:"chatId"`
+	Commands []BotCommand `json:"commands"`
+}
+
+type SendEphemeralRequest struct {
+	ChatID string `json:"chatId"`
+	UserID string `json:"userId"`
+	Text   string `json:"text"`
+}
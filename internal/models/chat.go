@@ -20,6 +20,9 @@ type Chat struct {
 	MemberCount int       `json:"memberCount" firestore:"memberCount"`
 	Avatar      string    `json:"avatar,omitempty" firestore:"avatar"`
 	Description string    `json:"description,omitempty" firestore:"description"`
+	MessageTTL  int64     `json:"messageTTL,omitempty" firestore:"messageTTL"`
+	SlowMode    int64     `json:"slowMode,omitempty" firestore:"slowMode"`
+	Filters     *ChatFilterSettings `json:"-" firestore:"filters,omitempty"`
 }
 
 type ChatMember struct {
@@ -28,7 +31,20 @@ type ChatMember struct {
 	UserID   string    `json:"userId" firestore:"userId"`
 	Username string    `json:"username" firestore:"username"`
 	Role     MemberRole `json:"role" firestore:"role"`
+	Bot      bool      `json:"bot,omitempty" firestore:"bot,omitempty"`
 	JoinedAt time.Time `json:"joinedAt" firestore:"joinedAt"`
+	// Muted silences notifications about the chat for this member, until
+	// MutedUntil if it is set.
+	Muted      bool       `json:"muted,omitempty" firestore:"muted,omitempty"`
+	MutedUntil *time.Time `json:"mutedUntil,omitempty" firestore:"mutedUntil,omitempty"`
+	// LastReadAt is when the member last marked the chat as read.
+	LastReadAt *time.Time `json:"lastReadAt,omitempty" firestore:"lastReadAt,omitempty"`
+	Profile  *UserProfile `json:"profile,omitempty" firestore:"-"`
+}
+
+// IsMuted reports whether the member has muted the chat at the given time.
+func (m *ChatMember) IsMuted(now time.Time) bool {
+	return m.Muted && (m.MutedUntil == nil || now.Before(*m.MutedUntil))
 }
 
 type MemberRole string
@@ -43,11 +59,16 @@ type Message struct {
 	ChatID    string    `json:"chatId" firestore:"chatId"`
 	SenderID  string    `json:"senderId" firestore:"senderId"`
 	Username  string    `json:"username" firestore:"username"`
+	Bot       bool      `json:"bot,omitempty" firestore:"bot,omitempty"`
+	WebhookID string    `json:"webhookId,omitempty" firestore:"webhookId,omitempty"`
+	AvatarURL string    `json:"avatarUrl,omitempty" firestore:"avatarUrl,omitempty"`
 	Text      string    `json:"text" firestore:"text"`
 	Type      MessageType `json:"type" firestore:"type"`
 	Timestamp time.Time `json:"timestamp" firestore:"timestamp"`
 	EditedAt  *time.Time `json:"editedAt,omitempty" firestore:"editedAt"`
 	ReplyTo   string    `json:"replyTo,omitempty" firestore:"replyTo"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" firestore:"expiresAt"`
+	Poll      *Poll      `json:"poll,omitempty" firestore:"poll,omitempty"`
 }
 
 type MessageType string
@@ -57,6 +78,8 @@ const (
 	MessageTypeSystem MessageType = "system"
 	MessageTypeImage  MessageType = "image"
 	MessageTypeFile   MessageType = "file"
+	MessageTypePoll   MessageType = "poll"
+	MessageTypeAction MessageType = "action" // posted with /me
 )
 
 type CreateChatRequest struct {
@@ -78,12 +101,21 @@ type ChatListResponse struct {
 type ChatMessagesResponse struct {
 	Messages []Message `json:"messages"`
 	HasMore  bool      `json:"hasMore"`
+	// NextMessageID is passed as lastMessageId to fetch the next page.
+	NextMessageID string `json:"nextMessageId,omitempty"`
 }
 
 type AddMemberRequest struct {
 	Username string `json:"username"`
 }
 
+// MuteChatRequest mutes or unmutes notifications of a chat for the current user.
+// Duration is in seconds; zero mutes until the chat is unmuted.
+type MuteChatRequest struct {
+	Muted    bool  `json:"muted"`
+	Duration int64 `json:"duration,omitempty"`
+}
+
 type ChatInfo struct {
 	Chat    Chat         `json:"chat"`
 	Members []ChatMember `json:"members"`
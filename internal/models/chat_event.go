@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+type ChatEventType string
+
+const (
+	ChatEventMessageCreated ChatEventType = "message.created"
+	ChatEventMemberJoined   ChatEventType = "member.joined"
+	ChatEventMemberLeft     ChatEventType = "member.left"
+	ChatEventChatUpdated    ChatEventType = "chat.updated"
+)
+
+// ChatEvent describes a change in a chat for integrations. Only the field that
+// matches Type is set.
+type ChatEvent struct {
+	Type      ChatEventType          `json:"type"`
+	ChatID    string                 `json:"chatId"`
+	ActorID   string                 `json:"actorId,omitempty"`
+	Message   *Message               `json:"message,omitempty"`
+	Member    *ChatMember            `json:"member,omitempty"`
+	Changes   map[string]interface{} `json:"changes,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
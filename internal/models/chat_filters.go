@@ -0,0 +1,12 @@
+package models
+
+// ChatFilterSettings are the per-chat content filter options chat admins configure.
+// Zero values fall back to the server defaults.
+type ChatFilterSettings struct {
+	BlockedWords     []string `json:"blockedWords" firestore:"blockedWords"`
+	MaskBlockedWords bool     `json:"maskBlockedWords" firestore:"maskBlockedWords"`
+	BlockLinks       bool     `json:"blockLinks" firestore:"blockLinks"`
+	AllowedDomains   []string `json:"allowedDomains" firestore:"allowedDomains"`
+	MaxLength        int      `json:"maxLength" firestore:"maxLength"`
+	MaxRepeatedChars int      `json:"maxRepeatedChars" firestore:"maxRepeatedChars"`
+}
@@ -0,0 +1,22 @@
+package models
+
+// CommandInfo describes a slash command available in a chat.
+type CommandInfo struct {
+	Command     string `json:"command"`
+	Description string `json:"description,omitempty"`
+	Usage       string `json:"usage,omitempty"`
+	AdminOnly   bool   `json:"adminOnly,omitempty"`
+	Bot         string `json:"bot,omitempty"` // username of the bot that handles it
+}
+
+type ChatCommandsResponse struct {
+	Commands []CommandInfo `json:"commands"`
+}
+
+// CommandResponse is an ephemeral reply shown only to the user who ran a command.
+type CommandResponse struct {
+	ChatID  string `json:"chatId"`
+	Command string `json:"command,omitempty"`
+	BotID   string `json:"botId,omitempty"`
+	Text    string `json:"text"`
+}
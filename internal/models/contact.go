@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+type FriendRequestStatus string
+
+const (
+	FriendRequestPending   FriendRequestStatus = "pending"
+	FriendRequestAccepted  FriendRequestStatus = "accepted"
+	FriendRequestDeclined  FriendRequestStatus = "declined"
+	FriendRequestCancelled FriendRequestStatus = "cancelled"
+)
+
+type FriendRequest struct {
+	ID           string              `json:"id" firestore:"id"`
+	FromUserID   string              `json:"fromUserId" firestore:"fromUserId"`
+	FromUsername string              `json:"fromUsername" firestore:"fromUsername"`
+	ToUserID     string              `json:"toUserId" firestore:"toUserId"`
+	ToUsername   string              `json:"toUsername" firestore:"toUsername"`
+	Status       FriendRequestStatus `json:"status" firestore:"status"`
+	CreatedAt    time.Time           `json:"createdAt" firestore:"createdAt"`
+	UpdatedAt    time.Time           `json:"updatedAt" firestore:"updatedAt"`
+}
+
+type Contact struct {
+	OwnerID   string       `json:"-" firestore:"ownerId"`
+	ContactID string       `json:"userId" firestore:"contactId"`
+	Nickname  string       `json:"nickname,omitempty" firestore:"nickname"`
+	CreatedAt time.Time    `json:"createdAt" firestore:"createdAt"`
+	Profile   *UserProfile `json:"profile,omitempty" firestore:"-"`
+}
+
+type SendFriendRequestRequest struct {
+	Username string `json:"username"`
+}
+
+type UpdateContactRequest struct {
+	Nickname string `json:"nickname"`
+}
+
+type ContactListResponse struct {
+	Contacts []Contact `json:"contacts"`
+}
+
+type FriendRequestListResponse struct {
+	Requests []FriendRequest `json:"requests"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+type DevicePlatform string
+
+const (
+	DevicePlatformAndroid DevicePlatform = "android"
+	DevicePlatformIOS     DevicePlatform = "ios"
+	DevicePlatformWeb     DevicePlatform = "web"
+)
+
+// Device is a push notification token registered by a user. The ID is a hash of
+// the token, so the token itself is never returned by the API.
+type Device struct {
+	ID         string         `json:"id" firestore:"id"`
+	UserID     string         `json:"-" firestore:"userId"`
+	Token      string         `json:"-" firestore:"token"`
+	Platform   DevicePlatform `json:"platform" firestore:"platform"`
+	Name       string         `json:"name,omitempty" firestore:"name"`
+	CreatedAt  time.Time      `json:"createdAt" firestore:"createdAt"`
+	LastSeenAt time.Time      `json:"lastSeenAt" firestore:"lastSeenAt"`
+}
+
+type RegisterDeviceRequest struct {
+	Token    string         `json:"token"`
+	Platform DevicePlatform `json:"platform"`
+	Name     string         `json:"name,omitempty"`
+}
+
+type DeviceListResponse struct {
+	Devices []Device `json:"devices"`
+}
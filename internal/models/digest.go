@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type DigestFrequency string
+
+const (
+	DigestOff    DigestFrequency = "off"
+	DigestDaily  DigestFrequency = "daily"
+	DigestWeekly DigestFrequency = "weekly"
+)
+
+// DigestSettings controls the email digest of unread messages.
+type DigestSettings struct {
+	Frequency DigestFrequency `json:"frequency"`
+	NextAt    *time.Time      `json:"nextAt,omitempty"`
+	LastRunAt *time.Time      `json:"lastRunAt,omitempty"`
+}
+
+type UpdateDigestSettingsRequest struct {
+	Frequency DigestFrequency `json:"frequency"`
+}
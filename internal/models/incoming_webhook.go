@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// IncomingWebhook lets an integration post into a chat without an account. It is
+// addressed by a secret URL; only a hash of the secret is stored.
+type IncomingWebhook struct {
+	ID         string     `json:"id" firestore:"id"`
+	ChatID     string     `json:"chatId" firestore:"chatId"`
+	Name       string     `json:"name" firestore:"name"`
+	AvatarURL  string     `json:"avatarUrl,omitempty" firestore:"avatarUrl"`
+	SecretHash string     `json:"-" firestore:"secretHash"`
+	CreatedBy  string     `json:"createdBy" firestore:"createdBy"`
+	CreatedAt  time.Time  `json:"createdAt" firestore:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" firestore:"lastUsedAt"`
+}
+
+type CreateIncomingWebhookRequest struct {
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatarUrl,omitempty"`
+}
+
+// IncomingWebhookCreatedResponse carries the secret URL, which is only shown once.
+type IncomingWebhookCreatedResponse struct {
+	Webhook IncomingWebhook `json:"webhook"`
+	URL     string          `json:"url"`
+}
+
+type IncomingWebhookListResponse struct {
+	Webhooks []IncomingWebhook `json:"webhooks"`
+}
+
+// IncomingWebhookMessage is the payload integrations post to the secret URL.
+// DisplayName and AvatarURL override the webhook's defaults for one message.
+type IncomingWebhookMessage struct {
+	Text        string `json:"text"`
+	DisplayName string `json:"displayName,omitempty"`
+	AvatarURL   string `json:"avatarUrl,omitempty"`
+}
@@ -0,0 +1,94 @@
+package models
+
+import "time"
+
+// GlobalRole is a server-wide role, independent of per-chat roles.
+type GlobalRole string
+
+const (
+	GlobalRoleUser      GlobalRole = ""
+	GlobalRoleModerator GlobalRole = "moderator"
+	GlobalRoleAdmin     GlobalRole = "admin"
+)
+
+type ReportTargetType string
+
+const (
+	ReportTargetMessage ReportTargetType = "message"
+	ReportTargetUser    ReportTargetType = "user"
+	ReportTargetChat    ReportTargetType = "chat"
+)
+
+type ReportStatus string
+
+const (
+	ReportStatusOpen      ReportStatus = "open"
+	ReportStatusResolved  ReportStatus = "resolved"
+	ReportStatusDismissed ReportStatus = "dismissed"
+)
+
+type Report struct {
+	ID         string           `json:"id" firestore:"id"`
+	ReporterID string           `json:"reporterId" firestore:"reporterId"`
+	TargetType ReportTargetType `json:"targetType" firestore:"targetType"`
+	TargetID   string           `json:"targetId" firestore:"targetId"`
+	ChatID     string           `json:"chatId,omitempty" firestore:"chatId"`
+	Reason     string           `json:"reason" firestore:"reason"`
+	Details    string           `json:"details,omitempty" firestore:"details"`
+	Status     ReportStatus     `json:"status" firestore:"status"`
+	CreatedAt  time.Time        `json:"createdAt" firestore:"createdAt"`
+	ResolvedBy string           `json:"resolvedBy,omitempty" firestore:"resolvedBy"`
+	ResolvedAt *time.Time       `json:"resolvedAt,omitempty" firestore:"resolvedAt"`
+}
+
+type CreateReportRequest struct {
+	TargetType ReportTargetType `json:"targetType"`
+	TargetID   string           `json:"targetId"`
+	ChatID     string           `json:"chatId,omitempty"`
+	Reason     string           `json:"reason"`
+	Details    string           `json:"details,omitempty"`
+}
+
+type ReportListResponse struct {
+	Reports []Report `json:"reports"`
+}
+
+type ModerationActionType string
+
+const (
+	ModerationDeleteMessage ModerationActionType = "delete_message"
+	ModerationSuspendUser   ModerationActionType = "suspend_user"
+	ModerationUnsuspendUser ModerationActionType = "unsuspend_user"
+	ModerationDeleteChat    ModerationActionType = "delete_chat"
+	ModerationDismissReport ModerationActionType = "dismiss_report"
+	ModerationSetRole       ModerationActionType = "set_role"
+	ModerationUnlockAccount ModerationActionType = "unlock_account"
+)
+
+// ModerationAction is an entry of the moderation audit trail.
+type ModerationAction struct {
+	ID          string               `json:"id" firestore:"id"`
+	ModeratorID string               `json:"moderatorId" firestore:"moderatorId"`
+	Action      ModerationActionType `json:"action" firestore:"action"`
+	TargetID    string               `json:"targetId" firestore:"targetId"`
+	ChatID      string               `json:"chatId,omitempty" firestore:"chatId"`
+	ReportID    string               `json:"reportId,omitempty" firestore:"reportId"`
+	Reason      string               `json:"reason,omitempty" firestore:"reason"`
+	Until       *time.Time           `json:"until,omitempty" firestore:"until"`
+	Role        GlobalRole           `json:"role,omitempty" firestore:"role"`
+	CreatedAt   time.Time            `json:"createdAt" firestore:"createdAt"`
+}
+
+type ModerationActionRequest struct {
+	Action   ModerationActionType `json:"action"`
+	TargetID string               `json:"targetId"`
+	ChatID   string               `json:"chatId,omitempty"`
+	ReportID string               `json:"reportId,omitempty"`
+	Reason   string               `json:"reason,omitempty"`
+	Duration string               `json:"duration,omitempty"` // e.g. "24h", required for suspend_user
+	Role     GlobalRole           `json:"role,omitempty"`     // for set_role
+}
+
+type ModerationAuditResponse struct {
+	Actions []ModerationAction `json:"actions"`
+}
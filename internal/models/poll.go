@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+type Poll struct {
+	Question       string       `json:"question" firestore:"question"`
+	Options        []PollOption `json:"options" firestore:"options"`
+	MultipleChoice bool         `json:"multipleChoice" firestore:"multipleChoice"`
+	Anonymous      bool         `json:"anonymous" firestore:"anonymous"`
+	ClosesAt       *time.Time   `json:"closesAt,omitempty" firestore:"closesAt"`
+	ClosedAt       *time.Time   `json:"closedAt,omitempty" firestore:"closedAt"`
+	TotalVoters    int          `json:"totalVoters" firestore:"totalVoters"`
+}
+
+type PollOption struct {
+	ID        int      `json:"id" firestore:"id"`
+	Text      string   `json:"text" firestore:"text"`
+	VoteCount int      `json:"voteCount" firestore:"voteCount"`
+	Voters    []string `json:"voters,omitempty" firestore:"voters"`
+}
+
+// IsClosed reports whether the poll was closed explicitly or its close time passed.
+func (p *Poll) IsClosed(now time.Time) bool {
+	if p.ClosedAt != nil {
+		return true
+	}
+	return p.ClosesAt != nil && !p.ClosesAt.After(now)
+}
+
+type PollVote struct {
+	ChatID    string    `json:"chatId" firestore:"chatId"`
+	MessageID string    `json:"messageId" firestore:"messageId"`
+	UserID    string    `json:"userId" firestore:"userId"`
+	OptionIDs []int     `json:"optionIds" firestore:"optionIds"`
+	VotedAt   time.Time `json:"votedAt" firestore:"votedAt"`
+}
+
+type CreatePollRequest struct {
+	Question       string     `json:"question"`
+	Options        []string   `json:"options"`
+	MultipleChoice bool       `json:"multipleChoice"`
+	Anonymous      bool       `json:"anonymous"`
+	ClosesAt       *time.Time `json:"closesAt,omitempty"`
+}
+
+type PollVoteRequest struct {
+	OptionIDs []int `json:"optionIds"`
+}
+
+type PollUpdate struct {
+	ChatID    string `json:"chatId"`
+	MessageID string `json:"messageId"`
+	Poll      Poll   `json:"poll"`
+}
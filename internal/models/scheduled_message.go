@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+type ScheduledMessageStatus string
+
+const (
+	ScheduledStatusPending   ScheduledMessageStatus = "pending"
+	ScheduledStatusSending   ScheduledMessageStatus = "sending"
+	ScheduledStatusSent      ScheduledMessageStatus = "sent"
+	ScheduledStatusCancelled ScheduledMessageStatus = "cancelled"
+	ScheduledStatusFailed    ScheduledMessageStatus = "failed"
+)
+
+type ScheduledMessage struct {
+	ID          string                 `json:"id" firestore:"id"`
+	ChatID      string                 `json:"chatId" firestore:"chatId"`
+	SenderID    string                 `json:"senderId" firestore:"senderId"`
+	Username    string                 `json:"username" firestore:"username"`
+	Text        string                 `json:"text" firestore:"text"`
+	ReplyTo     string                 `json:"replyTo,omitempty" firestore:"replyTo"`
+	SendAt      time.Time              `json:"sendAt" firestore:"sendAt"`
+	Status      ScheduledMessageStatus `json:"status" firestore:"status"`
+	CreatedAt   time.Time              `json:"createdAt" firestore:"createdAt"`
+	UpdatedAt   time.Time              `json:"updatedAt" firestore:"updatedAt"`
+	SentAt      *time.Time             `json:"sentAt,omitempty" firestore:"sentAt"`
+	MessageID   string                 `json:"messageId,omitempty" firestore:"messageId"`
+	Error       string                 `json:"error,omitempty" firestore:"error"`
+	Attempts    int                    `json:"-" firestore:"attempts"`
+	LockedBy    string                 `json:"-" firestore:"lockedBy"`
+	LockedUntil time.Time              `json:"-" firestore:"lockedUntil"`
+}
+
+type ScheduleMessageRequest struct {
+	Text    string    `json:"text"`
+	ReplyTo string    `json:"replyTo,omitempty"`
+	SendAt  time.Time `json:"sendAt"`
+}
+
+type UpdateScheduledMessageRequest struct {
+	Text   *string    `json:"text,omitempty"`
+	SendAt *time.Time `json:"sendAt,omitempty"`
+}
+
+type ScheduledMessagesResponse struct {
+	ScheduledMessages []ScheduledMessage `json:"scheduledMessages"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+type UserProfile struct {
+	ID          string    `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"displayName,omitempty"`
+	AvatarURL   string    `json:"avatarUrl,omitempty"`
+	Bio         string    `json:"bio,omitempty"`
+	Locale      string    `json:"locale,omitempty"`
+	Timezone    string    `json:"timezone,omitempty"`
+	Bot         bool      `json:"bot,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+
+	Settings *UserSettings `json:"settings,omitempty"`
+}
+
+// UserSettings holds privacy preferences. They are only returned to the user themselves.
+type UserSettings struct {
+	Discoverable             bool   `json:"discoverable"`
+	ContactsOnlyPrivateChats bool   `json:"contactsOnlyPrivateChats"`
+	Email                    string `json:"email,omitempty"`
+	TwoFactorEnabled         bool   `json:"twoFactorEnabled"`
+}
+
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"displayName,omitempty"`
+	AvatarURL   *string `json:"avatarUrl,omitempty"`
+	Bio         *string `json:"bio,omitempty"`
+	Locale      *string `json:"locale,omitempty"`
+	Timezone    *string `json:"timezone,omitempty"`
+
+	Discoverable             *bool `json:"discoverable,omitempty"`
+	ContactsOnlyPrivateChats *bool `json:"contactsOnlyPrivateChats,omitempty"`
+
+	Email *string `json:"email,omitempty"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+}
+
+type PasswordResetRequest struct {
+	Username string `json:"username"`
+}
+
+type PasswordResetConfirmRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+type UserSearchResponse struct {
+	Users      []UserProfile `json:"users"`
+	HasMore    bool          `json:"hasMore"`
+	NextOffset int           `json:"nextOffset,omitempty"`
+}
@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+// Webhook is an outgoing webhook of a chat. Secret signs the payloads and is
+// only returned when the webhook is created.
+type Webhook struct {
+	ID                  string          `json:"id" firestore:"id"`
+	ChatID              string          `json:"chatId" firestore:"chatId"`
+	URL                 string          `json:"url" firestore:"url"`
+	Events              []ChatEventType `json:"events,omitempty" firestore:"events"`
+	Secret              string          `json:"secret,omitempty" firestore:"secret"`
+	CreatedBy           string          `json:"createdBy" firestore:"createdBy"`
+	CreatedAt           time.Time       `json:"createdAt" firestore:"createdAt"`
+	UpdatedAt           time.Time       `json:"updatedAt" firestore:"updatedAt"`
+	Disabled            bool            `json:"disabled" firestore:"disabled"`
+	DisabledReason      string          `json:"disabledReason,omitempty" firestore:"disabledReason"`
+	ConsecutiveFailures int             `json:"consecutiveFailures" firestore:"consecutiveFailures"`
+}
+
+// Subscribed reports whether the webhook wants events of the given type. A
+// webhook without an event list receives all events.
+func (w *Webhook) Subscribed(eventType ChatEventType) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, t := range w.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type CreateWebhookRequest struct {
+	URL    string          `json:"url"`
+	Events []ChatEventType `json:"events,omitempty"`
+}
+
+type UpdateWebhookRequest struct {
+	Disabled *bool `json:"disabled,omitempty"`
+}
+
+type WebhookListResponse struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySending   WebhookDeliveryStatus = "sending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryDead      WebhookDeliveryStatus = "dead"
+)
+
+// WebhookAttempt is one HTTP request made for a delivery.
+type WebhookAttempt struct {
+	At         time.Time `json:"at" firestore:"at"`
+	StatusCode int       `json:"statusCode,omitempty" firestore:"statusCode"`
+	Error      string    `json:"error,omitempty" firestore:"error"`
+	DurationMs int64     `json:"durationMs" firestore:"durationMs"`
+}
+
+// WebhookDelivery is a queued event for a webhook together with its delivery log.
+// Deliveries that ran out of attempts are kept with status "dead".
+type WebhookDelivery struct {
+	ID            string                `json:"id" firestore:"id"`
+	WebhookID     string                `json:"webhookId" firestore:"webhookId"`
+	ChatID        string                `json:"chatId" firestore:"chatId"`
+	EventType     ChatEventType         `json:"eventType" firestore:"eventType"`
+	Payload       string                `json:"payload" firestore:"payload"`
+	Status        WebhookDeliveryStatus `json:"status" firestore:"status"`
+	AttemptCount  int                   `json:"attemptCount" firestore:"attemptCount"`
+	Attempts      []WebhookAttempt      `json:"attempts,omitempty" firestore:"attempts"`
+	NextAttemptAt time.Time             `json:"nextAttemptAt" firestore:"nextAttemptAt"`
+	CreatedAt     time.Time             `json:"createdAt" firestore:"createdAt"`
+	UpdatedAt     time.Time             `json:"updatedAt" firestore:"updatedAt"`
+	DeliveredAt   *time.Time            `json:"deliveredAt,omitempty" firestore:"deliveredAt"`
+	LockedBy      string                `json:"-" firestore:"lockedBy"`
+	LockedUntil   time.Time             `json:"-" firestore:"lockedUntil"`
+}
+
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
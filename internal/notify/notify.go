@@ -0,0 +1,36 @@
+// Package notify delivers out-of-band messages such as password reset links.
+package notify
+
+import (
+	"context"
+	"log"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+	// HTML is an optional alternative to the plain-text Body.
+	HTML string
+	// Headers are added to the mail as is, e.g. List-Unsubscribe.
+	Headers map[string]string
+}
+
+// Notifier sends a message to a single recipient.
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogNotifier writes messages to the server log instead of delivering them. It is
+// meant for development, where no mail server is available; the server refuses to
+// start with it when APP_ENV=production, since the log would contain reset links.
+type LogNotifier struct{}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Send(ctx context.Context, msg Message) error {
+	log.Printf("📧 Notification to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,124 @@
+// Package notifytest provides an SMTP server for testing code that sends mail.
+package notifytest
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"Flare-server/internal/notify"
+)
+
+// SMTPSink is a minimal SMTP server that accepts every message and records it.
+type SMTPSink struct {
+	listener net.Listener
+	messages chan Message
+}
+
+// Message is a mail as received by the sink. Data is the raw message with
+// dot-stuffing removed.
+type Message struct {
+	From string
+	To   []string
+	Data string
+}
+
+// NewSMTPSink starts a sink on a local port. It is closed when the test ends.
+func NewSMTPSink(t *testing.T) *SMTPSink {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	sink := &SMTPSink{listener: listener, messages: make(chan Message, 10)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go sink.serve(conn)
+		}
+	}()
+	return sink
+}
+
+// Config returns the configuration of an SMTPNotifier delivering to the sink.
+func (s *SMTPSink) Config() notify.SMTPConfig {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return notify.SMTPConfig{Host: "127.0.0.1", Port: addr.Port, From: "no-reply@flare.test"}
+}
+
+// Receive waits for the next message and fails the test if none arrives.
+func (s *SMTPSink) Receive(t *testing.T) Message {
+	t.Helper()
+	select {
+	case msg := <-s.messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return Message{}
+	}
+}
+
+func (s *SMTPSink) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 sink ESMTP")
+	var msg Message
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250 sink")
+		case "MAIL":
+			msg = Message{From: addressArg(line)}
+			reply("250 OK")
+		case "RCPT":
+			msg.To = append(msg.To, addressArg(line))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			msg.Data = data.String()
+			s.messages <- msg
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func addressArg(line string) string {
+	start, end := strings.Index(line, "<"), strings.Index(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
@@ -0,0 +1,92 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"Flare-server/internal/notify"
+	"Flare-server/internal/notify/notifytest"
+)
+
+func TestSMTPNotifierSendsPlainText(t *testing.T) {
+	sink := notifytest.NewSMTPSink(t)
+	notifier := notify.NewSMTPNotifier(sink.Config())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := notifier.Send(ctx, notify.Message{
+		To:      "alice@example.com",
+		Subject: "Flare password reset",
+		Body:    "Hi alice,\n\nhttps://flare.test/reset?token=abc\n",
+	})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	got := sink.Receive(t)
+	if got.From != "no-reply@flare.test" {
+		t.Errorf("MAIL FROM = %q", got.From)
+	}
+	if len(got.To) != 1 || got.To[0] != "alice@example.com" {
+		t.Errorf("RCPT TO = %v", got.To)
+	}
+
+	parsed, err := mail.ReadMessage(strings.NewReader(got.Data))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+	if subject := parsed.Header.Get("Subject"); !strings.Contains(subject, "password") {
+		t.Errorf("Subject = %q", subject)
+	}
+	if ct := parsed.Header.Get("Content-Type"); ct != "text/plain; charset=UTF-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if !strings.Contains(got.Data, "https://flare.test/reset?token=abc\r\n") {
+		t.Errorf("body does not contain the reset link:\n%s", got.Data)
+	}
+}
+
+func TestSMTPNotifierReportsRejectedRecipient(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		conn.Write([]byte("220 sink ESMTP\r\n"))
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case strings.HasPrefix(line, "RCPT"):
+				conn.Write([]byte("550 No such user\r\n"))
+			case strings.HasPrefix(line, "QUIT"):
+				conn.Write([]byte("221 Bye\r\n"))
+				return
+			default:
+				conn.Write([]byte("250 OK\r\n"))
+			}
+		}
+	}()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	notifier := notify.NewSMTPNotifier(notify.SMTPConfig{Host: "127.0.0.1", Port: port, From: "no-reply@flare.test"})
+	err = notifier.Send(context.Background(), notify.Message{To: "nobody@example.com", Subject: "s", Body: "b"})
+	if err == nil || !strings.Contains(err.Error(), "RCPT TO") {
+		t.Fatalf("expected RCPT TO error, got %v", err)
+	}
+}
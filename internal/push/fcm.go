@@ -0,0 +1,78 @@
+package push
+
+import (
+	"context"
+	"fmt"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// fcmBatchSize is the most messages FCM accepts in one SendEach call.
+const fcmBatchSize = 500
+
+// FCMProvider sends notifications through Firebase Cloud Messaging, which
+// covers Android, iOS (via APNs) and web push.
+type FCMProvider struct {
+	client *messaging.Client
+}
+
+func NewFCMProvider(client *messaging.Client) *FCMProvider {
+	return &FCMProvider{client: client}
+}
+
+func (p *FCMProvider) Send(ctx context.Context, notifications []Notification) (*Result, error) {
+	var messages []*messaging.Message
+	for _, n := range notifications {
+		for _, token := range n.Tokens {
+			messages = append(messages, fcmMessage(n, token))
+		}
+	}
+
+	result := &Result{}
+	for start := 0; start < len(messages); start += fcmBatchSize {
+		end := min(start+fcmBatchSize, len(messages))
+		batch := messages[start:end]
+
+		response, err := p.client.SendEach(ctx, batch)
+		if err != nil {
+			return result, fmt.Errorf("failed to send push notifications: %w", err)
+		}
+
+		result.Sent += response.SuccessCount
+		result.Failed += response.FailureCount
+		for i, r := range response.Responses {
+			if r.Error != nil && (messaging.IsUnregistered(r.Error) || messaging.IsSenderIDMismatch(r.Error)) {
+				result.InvalidTokens = append(result.InvalidTokens, batch[i].Token)
+			}
+		}
+	}
+	return result, nil
+}
+
+// fcmMessage builds the message for one device. The collapse key is applied in
+// the way each platform understands it, so a device keeps only the newest
+// notification per key.
+func fcmMessage(n Notification, token string) *messaging.Message {
+	message := &messaging.Message{
+		Token: token,
+		Data:  n.Data,
+		Notification: &messaging.Notification{
+			Title: n.Title,
+			Body:  n.Body,
+		},
+		Android: &messaging.AndroidConfig{Priority: "high"},
+	}
+
+	if n.CollapseKey != "" {
+		message.Android.CollapseKey = n.CollapseKey
+		message.Android.Notification = &messaging.AndroidNotification{Tag: n.CollapseKey}
+		message.APNS = &messaging.APNSConfig{
+			Headers: map[string]string{"apns-collapse-id": n.CollapseKey},
+			Payload: &messaging.APNSPayload{Aps: &messaging.Aps{ThreadID: n.CollapseKey}},
+		}
+		message.Webpush = &messaging.WebpushConfig{
+			Notification: &messaging.WebpushNotification{Tag: n.CollapseKey},
+		}
+	}
+	return message
+}
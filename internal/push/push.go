@@ -0,0 +1,91 @@
+// Package push delivers notifications to users' mobile and web devices.
+package push
+
+import (
+	"context"
+	"sync"
+)
+
+// Notification is a push to all devices of one recipient.
+type Notification struct {
+	Tokens []string
+	Title  string
+	Body   string
+	Data   map[string]string
+	// CollapseKey groups notifications on the device: a newer notification with
+	// the same key replaces the one still shown.
+	CollapseKey string
+}
+
+// Result summarizes a Send call. InvalidTokens lists device tokens the provider
+// reported as unregistered; they should not be used again.
+type Result struct {
+	Sent          int
+	Failed        int
+	InvalidTokens []string
+}
+
+// Provider sends notifications through a push service.
+type Provider interface {
+	Send(ctx context.Context, notifications []Notification) (*Result, error)
+}
+
+// NoopProvider drops all notifications. It is the default when no push service
+// is configured.
+type NoopProvider struct{}
+
+func (NoopProvider) Send(ctx context.Context, notifications []Notification) (*Result, error) {
+	return &Result{}, nil
+}
+
+// maxFakeSent is how many notifications FakeProvider keeps.
+const maxFakeSent = 100
+
+// FakeProvider records the most recent notifications instead of sending them. It
+// is meant for development and tests; tokens passed to MarkInvalid are reported
+// back as unregistered.
+type FakeProvider struct {
+	mutex   sync.Mutex
+	sent    []Notification
+	invalid map[string]bool
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{invalid: make(map[string]bool)}
+}
+
+func (p *FakeProvider) Send(ctx context.Context, notifications []Notification) (*Result, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	result := &Result{}
+	for _, n := range notifications {
+		for _, token := range n.Tokens {
+			if p.invalid[token] {
+				result.Failed++
+				result.InvalidTokens = append(result.InvalidTokens, token)
+			} else {
+				result.Sent++
+			}
+		}
+		p.sent = append(p.sent, n)
+	}
+	if len(p.sent) > maxFakeSent {
+		p.sent = append([]Notification(nil), p.sent[len(p.sent)-maxFakeSent:]...)
+	}
+	return result, nil
+}
+
+// MarkInvalid makes later sends report the token as unregistered.
+func (p *FakeProvider) MarkInvalid(token string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.invalid[token] = true
+}
+
+// Sent returns the recorded notifications, oldest first.
+func (p *FakeProvider) Sent() []Notification {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]Notification(nil), p.sent...)
+}
@@ -0,0 +1,81 @@
+package push
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestFakeProviderReportsInvalidTokens(t *testing.T) {
+	p := NewFakeProvider()
+	p.MarkInvalid("stale")
+
+	result, err := p.Send(context.Background(), []Notification{
+		{Tokens: []string{"phone", "stale"}, Title: "Alice", Body: "hi"},
+		{Tokens: []string{"laptop"}, Title: "Bob", Body: "hello"},
+	})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if result.Sent != 2 || result.Failed != 1 {
+		t.Errorf("got %d sent, %d failed, want 2 and 1", result.Sent, result.Failed)
+	}
+	if len(result.InvalidTokens) != 1 || result.InvalidTokens[0] != "stale" {
+		t.Errorf("InvalidTokens = %v, want [stale]", result.InvalidTokens)
+	}
+
+	sent := p.Sent()
+	if len(sent) != 2 || sent[0].Title != "Alice" || sent[1].Title != "Bob" {
+		t.Errorf("unexpected recorded notifications: %+v", sent)
+	}
+}
+
+func TestFakeProviderKeepsOnlyRecentNotifications(t *testing.T) {
+	p := NewFakeProvider()
+	for i := 0; i < maxFakeSent+50; i++ {
+		p.Send(context.Background(), []Notification{{Tokens: []string{"phone"}, Body: fmt.Sprint(i)}})
+	}
+
+	sent := p.Sent()
+	if len(sent) != maxFakeSent {
+		t.Fatalf("recorded %d notifications, want %d", len(sent), maxFakeSent)
+	}
+	if sent[0].Body != "50" || sent[len(sent)-1].Body != fmt.Sprint(maxFakeSent+49) {
+		t.Errorf("recorded %s..%s, want the newest notifications", sent[0].Body, sent[len(sent)-1].Body)
+	}
+
+	// Callers get a copy.
+	sent[0].Body = "changed"
+	if p.Sent()[0].Body != "50" {
+		t.Error("Sent exposed the provider's records")
+	}
+}
+
+func TestNoopProviderSendsNothing(t *testing.T) {
+	result, err := NoopProvider{}.Send(context.Background(), []Notification{{Tokens: []string{"phone"}, Body: "hi"}})
+	if err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if result.Sent != 0 || result.Failed != 0 || len(result.InvalidTokens) != 0 {
+		t.Errorf("unexpected result: %+v", result)
+	}
+}
+
+func TestFCMMessageAppliesCollapseKey(t *testing.T) {
+	n := Notification{Title: "Alice", Body: "hi", Data: map[string]string{"chatId": "chat-1"}, CollapseKey: "chat-1"}
+
+	message := fcmMessage(n, "phone")
+	if message.Token != "phone" || message.Notification.Title != "Alice" || message.Data["chatId"] != "chat-1" {
+		t.Errorf("unexpected message: %+v", message)
+	}
+	if message.Android.CollapseKey != "chat-1" || message.Android.Notification.Tag != "chat-1" {
+		t.Errorf("Android collapse key not set: %+v", message.Android)
+	}
+	if message.APNS.Headers["apns-collapse-id"] != "chat-1" || message.Webpush.Notification.Tag != "chat-1" {
+		t.Error("APNs or web push collapse key not set")
+	}
+
+	if message := fcmMessage(Notification{Title: "Alice"}, "phone"); message.APNS != nil || message.Webpush != nil {
+		t.Error("notifications without a collapse key should not set platform options")
+	}
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type firestoreBucket struct {
+	Tokens    float64   `firestore:"tokens"`
+	UpdatedAt time.Time `firestore:"updatedAt"`
+	// ExpiresAt is when the bucket is full again; a Firestore TTL policy on this
+	// field removes idle buckets.
+	ExpiresAt time.Time `firestore:"expiresAt"`
+}
+
+// FirestoreStore shares buckets between replicas through a Firestore transaction
+// per request. It costs a read and a write per limited request.
+type FirestoreStore struct {
+	client *firestore.Client
+	coll   string
+}
+
+func NewFirestoreStore(client *firestore.Client) *FirestoreStore {
+	return &FirestoreStore{client: client, coll: "rate_limits"}
+}
+
+func (s *FirestoreStore) Take(ctx context.Context, key string, policy Policy, now time.Time) (time.Duration, error) {
+	sum := sha256.Sum256([]byte(key))
+	ref := s.client.Collection(s.coll).Doc(hex.EncodeToString(sum[:]))
+
+	var retryAfter time.Duration
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		b := firestoreBucket{Tokens: policy.burst(), UpdatedAt: now}
+
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if err := doc.DataTo(&b); err != nil {
+				return err
+			}
+		}
+
+		b.Tokens = policy.refill(b.Tokens, b.UpdatedAt, now)
+		b.Tokens, retryAfter = policy.take(b.Tokens)
+		b.UpdatedAt = now
+		b.ExpiresAt = now.Add(time.Duration((policy.burst() - b.Tokens) / policy.rate() * float64(time.Second)))
+
+		return tx.Set(ref, b)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return retryAfter, nil
+}
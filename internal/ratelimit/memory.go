@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+const maxMemoryBuckets = 10000
+
+type memoryBucket struct {
+	key      string
+	tokens   float64
+	lastSeen time.Time
+}
+
+// MemoryStore keeps buckets in process memory. Limits are per server replica.
+// At most maxMemoryBuckets buckets are kept; when a new key arrives at the cap,
+// the least recently seen bucket is dropped.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	// recent orders buckets from the most to the least recently seen.
+	recent *list.List
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*list.Element),
+		recent:  list.New(),
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, policy Policy, now time.Time) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b *memoryBucket
+	if e, ok := s.buckets[key]; ok {
+		s.recent.MoveToFront(e)
+		b = e.Value.(*memoryBucket)
+	} else {
+		if s.recent.Len() >= maxMemoryBuckets {
+			oldest := s.recent.Back()
+			s.recent.Remove(oldest)
+			delete(s.buckets, oldest.Value.(*memoryBucket).key)
+		}
+		b = &memoryBucket{key: key, tokens: policy.burst(), lastSeen: now}
+		s.buckets[key] = s.recent.PushFront(b)
+	}
+
+	b.tokens = policy.refill(b.tokens, b.lastSeen, now)
+	b.lastSeen = now
+
+	var retryAfter time.Duration
+	b.tokens, retryAfter = policy.take(b.tokens)
+	return retryAfter, nil
+}
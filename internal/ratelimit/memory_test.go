@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreLimitsKey(t *testing.T) {
+	s := NewMemoryStore()
+	policy := Policy{Requests: 2, Per: time.Minute}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		if wait, _ := s.Take(context.Background(), "ip:1", policy, now); wait != 0 {
+			t.Fatalf("request %d was limited", i)
+		}
+	}
+	if wait, _ := s.Take(context.Background(), "ip:1", policy, now); wait != 30*time.Second {
+		t.Errorf("retry after %v, want 30s", wait)
+	}
+	if wait, _ := s.Take(context.Background(), "ip:1", policy, now.Add(30*time.Second)); wait != 0 {
+		t.Error("request was limited after the bucket refilled")
+	}
+}
+
+func TestMemoryStoreEvictsLeastRecentlySeen(t *testing.T) {
+	s := NewMemoryStore()
+	policy := Policy{Requests: 1, Per: time.Hour}
+	now := time.Now()
+
+	for i := 0; i < maxMemoryBuckets; i++ {
+		s.Take(context.Background(), fmt.Sprint(i), policy, now)
+	}
+	// Key 0 is seen again, so key 1 is now the least recently seen.
+	s.Take(context.Background(), "0", policy, now)
+	for i := 0; i < 10; i++ {
+		s.Take(context.Background(), fmt.Sprint("new", i), policy, now)
+	}
+
+	if len(s.buckets) != maxMemoryBuckets || s.recent.Len() != maxMemoryBuckets {
+		t.Fatalf("store holds %d buckets, want %d", len(s.buckets), maxMemoryBuckets)
+	}
+	if _, ok := s.buckets["0"]; !ok {
+		t.Error("a recently seen bucket was evicted")
+	}
+	if _, ok := s.buckets["1"]; ok {
+		t.Error("the least recently seen bucket was kept")
+	}
+	if wait, _ := s.Take(context.Background(), "0", policy, now); wait == 0 {
+		t.Error("a kept bucket lost its state")
+	}
+}
@@ -0,0 +1,110 @@
+// Package ratelimit implements token-bucket rate limiting with pluggable storage,
+// so limits can be kept in memory for a single server or shared between replicas.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// Policy allows Requests per Per on average, with bursts of up to Requests.
+// A policy with no requests disables limiting.
+type Policy struct {
+	Requests int
+	Per      time.Duration
+}
+
+func (p Policy) Enabled() bool {
+	return p.Requests > 0 && p.Per > 0
+}
+
+func (p Policy) rate() float64 {
+	return float64(p.Requests) / p.Per.Seconds()
+}
+
+func (p Policy) burst() float64 {
+	return float64(p.Requests)
+}
+
+// refill returns the tokens in a bucket at now, given its state at last.
+func (p Policy) refill(tokens float64, last, now time.Time) float64 {
+	elapsed := now.Sub(last).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(p.burst(), tokens+elapsed*p.rate())
+}
+
+// take consumes one token if available and returns the new token count and how
+// long the caller must wait otherwise.
+func (p Policy) take(tokens float64) (float64, time.Duration) {
+	if tokens < 1 {
+		return tokens, time.Duration((1 - tokens) / p.rate() * float64(time.Second))
+	}
+	return tokens - 1, 0
+}
+
+// Store keeps bucket state. Take consumes one token for key and returns zero if the
+// request is allowed or the time to wait before retrying.
+type Store interface {
+	Take(ctx context.Context, key string, policy Policy, now time.Time) (time.Duration, error)
+}
+
+// LimitError is returned when a request exceeds its limit.
+type LimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("rate limit exceeded, retry in %d seconds", e.RetryAfterSeconds())
+}
+
+// RetryAfterSeconds rounds up so clients never retry too early.
+func (e *LimitError) RetryAfterSeconds() int {
+	seconds := int(math.Ceil(e.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// Take consumes a token for key under policy. Storage errors are logged and the
+// request is allowed, so an outage of the backend does not take the API down.
+func Take(ctx context.Context, store Store, key string, policy Policy) error {
+	if !policy.Enabled() {
+		return nil
+	}
+
+	retryAfter, err := store.Take(ctx, key, policy, time.Now())
+	if err != nil {
+		log.Printf("Rate limit store error for %s: %v", key, err)
+		return nil
+	}
+	if retryAfter > 0 {
+		return &LimitError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// Limiter applies one named policy, e.g. "login" or "messages".
+type Limiter struct {
+	store  Store
+	name   string
+	policy Policy
+}
+
+func NewLimiter(store Store, name string, policy Policy) *Limiter {
+	return &Limiter{
+		store:  store,
+		name:   name,
+		policy: policy,
+	}
+}
+
+// Allow returns a *LimitError if key has exhausted its budget.
+func (l *Limiter) Allow(ctx context.Context, key string) error {
+	return Take(ctx, l.store, l.name+":"+key, l.policy)
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"Flare-server/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type BlockRepo struct {
+	client *firestore.Client
+	coll   string
+}
+
+func NewBlockRepo(client *firestore.Client) *BlockRepo {
+	return &BlockRepo{client: client, coll: "user_blocks"}
+}
+
+// Block creates or updates the block of blockedID by blockerID.
+func (r *BlockRepo) Block(ctx context.Context, block models.Block) error {
+	_, err := r.blockRef(block.BlockerID, block.BlockedID).Set(ctx, block)
+	if err != nil {
+		return fmt.Errorf("failed to block user: %w", err)
+	}
+	return nil
+}
+
+func (r *BlockRepo) Unblock(ctx context.Context, blockerID, blockedID string) error {
+	ref := r.blockRef(blockerID, blockedID)
+	if _, err := ref.Get(ctx); err != nil {
+		return fmt.Errorf("user is not blocked")
+	}
+
+	_, err := ref.Delete(ctx)
+	return err
+}
+
+func (r *BlockRepo) GetBlocks(ctx context.Context, blockerID string) ([]models.Block, error) {
+	iter := r.client.Collection(r.coll).Where("blockerId", "==", blockerID).Documents(ctx)
+	defer iter.Stop()
+
+	blocks := []models.Block{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get blocks: %w", err)
+		}
+
+		var block models.Block
+		if err := doc.DataTo(&block); err != nil {
+			continue
+		}
+		blocks = append(blocks, block)
+	}
+
+	return blocks, nil
+}
+
+func (r *BlockRepo) IsBlocked(ctx context.Context, blockerID, blockedID string) (bool, error) {
+	_, err := r.blockRef(blockerID, blockedID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check block: %w", err)
+	}
+	return true, nil
+}
+
+// IsBlockedEitherWay reports whether either user has blocked the other.
+func (r *BlockRepo) IsBlockedEitherWay(ctx context.Context, userID, otherID string) (bool, error) {
+	blocked, err := r.IsBlocked(ctx, userID, otherID)
+	if err != nil || blocked {
+		return blocked, err
+	}
+	return r.IsBlocked(ctx, otherID, userID)
+}
+
+// GetBlockerIDs returns the IDs of users who have blocked userID.
+func (r *BlockRepo) GetBlockerIDs(ctx context.Context, userID string) (map[string]bool, error) {
+	return r.collectIDs(ctx, r.client.Collection(r.coll).Where("blockedId", "==", userID), "blockerId")
+}
+
+// GetMessageHiderIDs returns the IDs of users who blocked userID and asked to hide
+// their messages.
+func (r *BlockRepo) GetMessageHiderIDs(ctx context.Context, userID string) (map[string]bool, error) {
+	query := r.client.Collection(r.coll).
+		Where("blockedId", "==", userID).
+		Where("hideMessages", "==", true)
+	return r.collectIDs(ctx, query, "blockerId")
+}
+
+func (r *BlockRepo) collectIDs(ctx context.Context, query firestore.Query, field string) (map[string]bool, error) {
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	ids := make(map[string]bool)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get blocks: %w", err)
+		}
+
+		if id, ok := doc.Data()[field].(string); ok {
+			ids[id] = true
+		}
+	}
+
+	return ids, nil
+}
+
+func (r *BlockRepo) blockRef(blockerID, blockedID string) *firestore.DocumentRef {
+	return r.client.Collection(r.coll).Doc(blockerID + "_" + blockedID)
+}
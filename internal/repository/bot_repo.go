@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"Flare-server/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// BotCommandSet is the list of slash commands a bot registered in one chat.
+type BotCommandSet struct {
+	BotID     string              `firestore:"botId"`
+	ChatID    string              `firestore:"chatId"`
+	Commands  []models.BotCommand `firestore:"commands"`
+	UpdatedAt time.Time           `firestore:"updatedAt"`
+}
+
+type BotRepo struct {
+	client       *firestore.Client
+	usersColl    string
+	updatesColl  string
+	commandsColl string
+}
+
+func NewBotRepo(client *firestore.Client) *BotRepo {
+	return &BotRepo{
+		client:       client,
+		usersColl:    "users",
+		updatesColl:  "bot_updates",
+		commandsColl: "bot_commands",
+	}
+}
+
+func (r *BotRepo) GetBotsByOwner(ctx context.Context, ownerID string) ([]User, error) {
+	return r.queryBots(ctx, r.client.Collection(r.usersColl).Where("botOwnerId", "==", ownerID))
+}
+
+// GetWebhookBots returns the bots that receive updates by webhook.
+func (r *BotRepo) GetWebhookBots(ctx context.Context) ([]User, error) {
+	return r.queryBots(ctx, r.client.Collection(r.usersColl).Where("botWebhookActive", "==", true))
+}
+
+func (r *BotRepo) queryBots(ctx context.Context, query firestore.Query) ([]User, error) {
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	var bots []User
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate bots: %w", err)
+		}
+
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			continue
+		}
+		user.ID = doc.Ref.ID
+		if user.IsBot {
+			bots = append(bots, user)
+		}
+	}
+	return bots, nil
+}
+
+func (r *BotRepo) SetBotWebhook(ctx context.Context, botID, url, secret string) error {
+	_, err := r.client.Collection(r.usersColl).Doc(botID).Update(ctx, []firestore.Update{
+		{Path: "botWebhookUrl", Value: url},
+		{Path: "botWebhookSecret", Value: secret},
+		{Path: "botWebhookActive", Value: url != ""},
+		{Path: "updatedAt", Value: time.Now()},
+	})
+	return err
+}
+
+// AddBotUpdate stores an update with the bot's next update ID.
+func (r *BotRepo) AddBotUpdate(ctx context.Context, update models.BotUpdate) (*models.BotUpdate, error) {
+	botRef := r.client.Collection(r.usersColl).Doc(update.BotID)
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(botRef)
+		if err != nil {
+			return err
+		}
+		var bot User
+		if err := doc.DataTo(&bot); err != nil {
+			return err
+		}
+
+		update.UpdateID = bot.BotLastUpdateID + 1
+		update.CreatedAt = time.Now()
+		if err := tx.Update(botRef, []firestore.Update{{Path: "botLastUpdateId", Value: update.UpdateID}}); err != nil {
+			return err
+		}
+		return tx.Create(r.updateRef(update.BotID, update.UpdateID), update)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &update, nil
+}
+
+// GetBotUpdates returns up to limit updates starting at offset, oldest first.
+func (r *BotRepo) GetBotUpdates(ctx context.Context, botID string, offset int64, limit int) ([]models.BotUpdate, error) {
+	iter := r.client.Collection(r.updatesColl).
+		Where("botId", "==", botID).
+		Where("updateId", ">=", offset).
+		OrderBy("updateId", firestore.Asc).
+		Limit(limit).
+		Documents(ctx)
+	defer iter.Stop()
+
+	var updates []models.BotUpdate
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate bot updates: %w", err)
+		}
+
+		var update models.BotUpdate
+		if err := doc.DataTo(&update); err != nil {
+			continue
+		}
+		updates = append(updates, update)
+	}
+	return updates, nil
+}
+
+// ConfirmBotUpdates deletes the bot's updates below offset.
+func (r *BotRepo) ConfirmBotUpdates(ctx context.Context, botID string, offset int64) error {
+	iter := r.client.Collection(r.updatesColl).
+		Where("botId", "==", botID).
+		Where("updateId", "<", offset).
+		Documents(ctx)
+	defer iter.Stop()
+
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to iterate bot updates: %w", err)
+		}
+		if _, err := doc.Ref.Delete(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *BotRepo) DeleteBotUpdate(ctx context.Context, botID string, updateID int64) error {
+	_, err := r.updateRef(botID, updateID).Delete(ctx)
+	return err
+}
+
+// DeleteOldBotUpdates removes updates that were never fetched or delivered.
+func (r *BotRepo) DeleteOldBotUpdates(ctx context.Context, before time.Time) (int, error) {
+	iter := r.client.Collection(r.updatesColl).Where("createdAt", "<", before).Documents(ctx)
+	defer iter.Stop()
+
+	deleted := 0
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("failed to iterate old bot updates: %w", err)
+		}
+		if _, err := doc.Ref.Delete(ctx); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+func (r *BotRepo) updateRef(botID string, updateID int64) *firestore.DocumentRef {
+	return r.client.Collection(r.updatesColl).Doc(fmt.Sprintf("%s_%012d", botID, updateID))
+}
+
+// SetBotCommands replaces the bot's commands in a chat. An empty list deletes them.
+func (r *BotRepo) SetBotCommands(ctx context.Context, botID, chatID string, commands []models.BotCommand) error {
+	if len(commands) == 0 {
+		return r.DeleteBotCommands(ctx, botID, chatID)
+	}
+
+	_, err := r.commandsRef(botID, chatID).Set(ctx, BotCommandSet{
+		BotID:     botID,
+		ChatID:    chatID,
+		Commands:  commands,
+		UpdatedAt: time.Now(),
+	})
+	return err
+}
+
+func (r *BotRepo) DeleteBotCommands(ctx context.Context, botID, chatID string) error {
+	_, err := r.commandsRef(botID, chatID).Delete(ctx)
+	return err
+}
+
+// GetChatBotCommands returns the commands every bot registered in the chat.
+func (r *BotRepo) GetChatBotCommands(ctx context.Context, chatID string) ([]BotCommandSet, error) {
+	iter := r.client.Collection(r.commandsColl).Where("chatId", "==", chatID).Documents(ctx)
+	defer iter.Stop()
+
+	var sets []BotCommandSet
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate bot commands: %w", err)
+		}
+
+		var set BotCommandSet
+		if err := doc.DataTo(&set); err != nil {
+			continue
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+func (r *BotRepo) commandsRef(botID, chatID string) *firestore.DocumentRef {
+	return r.client.Collection(r.commandsColl).Doc(botID + "_" + chatID)
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -10,6 +11,24 @@ import (
 
 	"cloud.google.com/go/firestore"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// lastMessagePageSize is how many messages GetLastMessage reads at a time
+	// while skipping expired ones.
+	lastMessagePageSize       = 10
+	maxExpiredMessagesScanned = 200
+	// deletePageSize stays under the 500 writes a batch may contain.
+	deletePageSize = 200
+)
+
+var (
+	// ErrMessageExists is returned when a message is saved under an ID that is taken.
+	ErrMessageExists = errors.New("message already exists")
+	// ErrChatMemberNotFound is returned when the user is not a member of the chat.
+	ErrChatMemberNotFound = errors.New("chat member not found")
 )
 
 type ChatRepo struct {
@@ -137,7 +156,80 @@ func (r *ChatRepo) RemoveChatMember(ctx context.Context, chatID, userID string)
 		return err
 	}
 	
-	return fmt.Errorf("chat member not found")
+	return ErrChatMemberNotFound
+}
+
+// SetMemberMuted mutes or unmutes the chat for one member. until may be nil for
+// an open-ended mute.
+func (r *ChatRepo) SetMemberMuted(ctx context.Context, chatID, userID string, muted bool, until *time.Time) error {
+	ref, err := r.memberRef(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+
+	var mutedUntil interface{} = firestore.Delete
+	if muted && until != nil {
+		mutedUntil = *until
+	}
+	_, err = ref.Update(ctx, []firestore.Update{
+		{Path: "muted", Value: muted},
+		{Path: "mutedUntil", Value: mutedUntil},
+	})
+	return err
+}
+
+// MarkChatRead records that the member has read the chat up to the given time.
+func (r *ChatRepo) MarkChatRead(ctx context.Context, chatID, userID string, at time.Time) error {
+	ref, err := r.memberRef(ctx, chatID, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = ref.Update(ctx, []firestore.Update{{Path: "lastReadAt", Value: at}})
+	return err
+}
+
+func (r *ChatRepo) memberRef(ctx context.Context, chatID, userID string) (*firestore.DocumentRef, error) {
+	iter := r.client.Collection("chat_members").
+		Where("chatId", "==", chatID).
+		Where("userId", "==", userID).
+		Limit(1).
+		Documents(ctx)
+	defer iter.Stop()
+
+	doc, err := iter.Next()
+	if err == iterator.Done {
+		return nil, ErrChatMemberNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find chat member: %w", err)
+	}
+	return doc.Ref, nil
+}
+
+// GetUserMemberships returns the memberships of a user in all chats.
+func (r *ChatRepo) GetUserMemberships(ctx context.Context, userID string) ([]models.ChatMember, error) {
+	iter := r.client.Collection("chat_members").Where("userId", "==", userID).Documents(ctx)
+	defer iter.Stop()
+
+	var members []models.ChatMember
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate chat members: %w", err)
+		}
+
+		var member models.ChatMember
+		if err := doc.DataTo(&member); err != nil {
+			continue
+		}
+		member.ID = doc.Ref.ID
+		members = append(members, member)
+	}
+	return members, nil
 }
 
 func (r *ChatRepo) GetChatMembers(ctx context.Context, chatID string) ([]models.ChatMember, error) {
@@ -165,6 +257,44 @@ func (r *ChatRepo) GetChatMembers(ctx context.Context, chatID string) ([]models.
 	return members, nil
 }
 
+// UpdateMemberUsernames rewrites the username copied into every membership of the
+// user after a username change.
+func (r *ChatRepo) UpdateMemberUsernames(ctx context.Context, userID, username string) error {
+	iter := r.client.Collection("chat_members").Where("userId", "==", userID).Documents(ctx)
+	defer iter.Stop()
+
+	batch := r.client.Batch()
+	pending := 0
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to iterate chat members: %w", err)
+		}
+
+		batch.Update(doc.Ref, []firestore.Update{{Path: "username", Value: username}})
+		pending++
+
+		if pending == 500 {
+			if _, err := batch.Commit(ctx); err != nil {
+				return fmt.Errorf("failed to update member usernames: %w", err)
+			}
+			batch = r.client.Batch()
+			pending = 0
+		}
+	}
+
+	if pending > 0 {
+		if _, err := batch.Commit(ctx); err != nil {
+			return fmt.Errorf("failed to update member usernames: %w", err)
+		}
+	}
+
+	return nil
+}
+
 func (r *ChatRepo) IsUserInChat(ctx context.Context, chatID, userID string) (bool, error) {
 	iter := r.client.Collection("chat_members").
 		Where("chatId", "==", chatID).
@@ -184,17 +314,28 @@ func (r *ChatRepo) IsUserInChat(ctx context.Context, chatID, userID string) (boo
 	return true, nil
 }
 
+// SaveMessage stores a new message. A message with an ID is created under that
+// ID and ErrMessageExists is returned if it is already taken.
 func (r *ChatRepo) SaveMessage(ctx context.Context, message models.Message) (*models.Message, error) {
 	message.Timestamp = time.Now()
 	
-	docRef, _, err := r.client.Collection("messages").Add(ctx, message)
-	if err != nil {
-		return nil, fmt.Errorf("failed to save message: %w", err)
+	if message.ID != "" {
+		_, err := r.client.Collection("messages").Doc(message.ID).Create(ctx, message)
+		if status.Code(err) == codes.AlreadyExists {
+			return nil, ErrMessageExists
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to save message: %w", err)
+		}
+	} else {
+		docRef, _, err := r.client.Collection("messages").Add(ctx, message)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save message: %w", err)
+		}
+		message.ID = docRef.ID
 	}
 	
-	message.ID = docRef.ID
-	
-	_, err = r.client.Collection("chats").Doc(message.ChatID).Update(ctx, []firestore.Update{
+	_, err := r.client.Collection("chats").Doc(message.ChatID).Update(ctx, []firestore.Update{
 		{Path: "updatedAt", Value: time.Now()},
 	})
 	if err != nil {
@@ -204,11 +345,13 @@ func (r *ChatRepo) SaveMessage(ctx context.Context, message models.Message) (*mo
 	return &message, nil
 }
 
-func (r *ChatRepo) GetChatMessages(ctx context.Context, chatID string, limit int, lastMessageID string) ([]models.Message, error) {
+// GetChatMessages returns up to limit messages sent before lastMessageID, oldest
+// first. If the scan stopped early at a run of expired messages, resumeAfter is
+// the ID of the last message scanned, from which the next page continues.
+func (r *ChatRepo) GetChatMessages(ctx context.Context, chatID string, limit int, lastMessageID string) (messages []models.Message, resumeAfter string, err error) {
 	query := r.client.Collection("messages").
 		Where("chatId", "==", chatID).
-		OrderBy("timestamp", firestore.Desc).
-		Limit(limit)
+		OrderBy("timestamp", firestore.Desc)
 	
 	if lastMessageID != "" {
 		lastDoc, err := r.client.Collection("messages").Doc(lastMessageID).Get(ctx)
@@ -217,59 +360,210 @@ func (r *ChatRepo) GetChatMessages(ctx context.Context, chatID string, limit int
 		}
 	}
 	
-	iter := query.Documents(ctx)
-	defer iter.Stop()
+	messages, resume, err := r.liveMessages(ctx, query, limit, limit)
+	if err != nil {
+		return nil, "", err
+	}
+	if resume != nil {
+		resumeAfter = resume.Ref.ID
+	}
+	
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
 	
+	return messages, resumeAfter, nil
+}
+
+// liveMessages returns up to limit messages of query that have not expired,
+// reading pageSize documents at a time so expired messages do not shorten the
+// result. Expired messages are deleted by the reaper shortly after they expire,
+// so the scan gives up after maxExpiredMessagesScanned of them and returns the
+// last document read, after which the scan can be resumed.
+func (r *ChatRepo) liveMessages(ctx context.Context, query firestore.Query, limit, pageSize int) ([]models.Message, *firestore.DocumentSnapshot, error) {
+	now := time.Now()
 	var messages []models.Message
+	skipped := 0
+	for {
+		iter := query.Limit(pageSize).Documents(ctx)
+		var last *firestore.DocumentSnapshot
+		read := 0
+		for len(messages) < limit {
+			doc, err := iter.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				iter.Stop()
+				return nil, nil, fmt.Errorf("failed to iterate messages: %w", err)
+			}
+			last = doc
+			read++
+			
+			var message models.Message
+			if err := doc.DataTo(&message); err != nil {
+				continue
+			}
+			if isMessageExpired(message, now) {
+				skipped++
+				continue
+			}
+			message.ID = doc.Ref.ID
+			messages = append(messages, message)
+		}
+		iter.Stop()
+		
+		if len(messages) >= limit || read < pageSize {
+			return messages, nil, nil
+		}
+		if skipped >= maxExpiredMessagesScanned {
+			return messages, last, nil
+		}
+		query = query.StartAfter(last)
+	}
+}
+
+// GetMessagesSince returns the newest limit messages of the chat sent after
+// since, oldest first.
+func (r *ChatRepo) GetMessagesSince(ctx context.Context, chatID string, since time.Time, limit int) ([]models.Message, error) {
+	query := r.client.Collection("messages").
+		Where("chatId", "==", chatID).
+		Where("timestamp", ">", since).
+		OrderBy("timestamp", firestore.Desc)
+
+	messages, _, err := r.liveMessages(ctx, query, limit, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+func (r *ChatRepo) GetLastMessage(ctx context.Context, chatID string) (*models.Message, error) {
+	query := r.client.Collection("messages").
+		Where("chatId", "==", chatID).
+		OrderBy("timestamp", firestore.Desc)
+	
+	// Expired messages may still be stored until the reaper gets to them.
+	messages, _, err := r.liveMessages(ctx, query, 1, lastMessagePageSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get last message: %w", err)
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("no messages found")
+	}
+	return &messages[0], nil
+}
+
+// DeleteExpiredMessages removes up to limit messages whose expiresAt has passed and
+// returns them so callers can notify connected clients.
+func (r *ChatRepo) DeleteExpiredMessages(ctx context.Context, now time.Time, limit int) ([]models.Message, error) {
+	iter := r.client.Collection("messages").
+		Where("expiresAt", "<=", now).
+		Limit(limit).
+		Documents(ctx)
+	defer iter.Stop()
+
+	batch := r.client.Batch()
+	var deleted []models.Message
 	for {
 		doc, err := iter.Next()
 		if err == iterator.Done {
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("failed to iterate messages: %w", err)
+			return nil, fmt.Errorf("failed to query expired messages: %w", err)
 		}
-		
+
 		var message models.Message
 		if err := doc.DataTo(&message); err != nil {
 			continue
 		}
 		message.ID = doc.Ref.ID
-		messages = append(messages, message)
+
+		batch.Delete(doc.Ref)
+		deleted = append(deleted, message)
 	}
-	
-	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
-		messages[i], messages[j] = messages[j], messages[i]
+
+	if len(deleted) == 0 {
+		return nil, nil
 	}
-	
-	return messages, nil
+
+	// Votes go first, so a poll whose votes could not be deleted is retried.
+	for _, message := range deleted {
+		if message.Type != models.MessageTypePoll {
+			continue
+		}
+		votes := r.client.Collection("poll_votes").Where("messageId", "==", message.ID)
+		if err := r.deleteAll(ctx, votes); err != nil {
+			return nil, fmt.Errorf("failed to delete poll votes of expired message %s: %w", message.ID, err)
+		}
+	}
+
+	if _, err := batch.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to delete expired messages: %w", err)
+	}
+
+	return deleted, nil
 }
 
-func (r *ChatRepo) GetLastMessage(ctx context.Context, chatID string) (*models.Message, error) {
-	iter := r.client.Collection("messages").
-		Where("chatId", "==", chatID).
-		OrderBy("timestamp", firestore.Desc).
-		Limit(1).
-		Documents(ctx)
-	defer iter.Stop()
-	
-	doc, err := iter.Next()
-	if err == iterator.Done {
-		return nil, fmt.Errorf("no messages found")
+func (r *ChatRepo) GetMessage(ctx context.Context, messageID string) (*models.Message, error) {
+	doc, err := r.client.Collection("messages").Doc(messageID).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("message not found")
+	}
+
+	var message models.Message
+	if err := doc.DataTo(&message); err != nil {
+		return nil, fmt.Errorf("failed to decode message: %w", err)
+	}
+	message.ID = doc.Ref.ID
+	return &message, nil
+}
+
+// FindMessage returns the message, or nil if it does not exist.
+func (r *ChatRepo) FindMessage(ctx context.Context, messageID string) (*models.Message, error) {
+	doc, err := r.client.Collection("messages").Doc(messageID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
 	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get last message: %w", err)
+		return nil, fmt.Errorf("failed to get message: %w", err)
 	}
-	
+
 	var message models.Message
 	if err := doc.DataTo(&message); err != nil {
 		return nil, fmt.Errorf("failed to decode message: %w", err)
 	}
 	message.ID = doc.Ref.ID
-	
 	return &message, nil
 }
 
+// DeleteMessage removes a single message together with its poll votes.
+func (r *ChatRepo) DeleteMessage(ctx context.Context, messageID string) error {
+	batch := r.client.Batch()
+	batch.Delete(r.client.Collection("messages").Doc(messageID))
+
+	votesIter := r.client.Collection("poll_votes").Where("messageId", "==", messageID).Documents(ctx)
+	defer votesIter.Stop()
+
+	for {
+		doc, err := votesIter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to iterate poll votes for deletion: %w", err)
+		}
+		batch.Delete(doc.Ref)
+	}
+
+	_, err := batch.Commit(ctx)
+	return err
+}
+
 func (r *ChatRepo) FindPrivateChat(ctx context.Context, user1ID, user2ID string) (*models.Chat, error) {
 	iter := r.client.Collection("chat_members").Where("userId", "==", user1ID).Documents(ctx)
 	defer iter.Stop()
@@ -325,40 +619,49 @@ func (r *ChatRepo) UpdateChat(ctx context.Context, chatID string, updates map[st
 	return err
 }
 
+// DeleteChat removes a chat with its polls' votes, messages and members. Each
+// collection is deleted in batches of deletePageSize, and the chat document goes
+// last so a deletion that fails halfway can be retried.
 func (r *ChatRepo) DeleteChat(ctx context.Context, chatID string) error {
-	batch := r.client.Batch()
-	
-	chatRef := r.client.Collection("chats").Doc(chatID)
-	batch.Delete(chatRef)
-	
-	membersIter := r.client.Collection("chat_members").Where("chatId", "==", chatID).Documents(ctx)
-	defer membersIter.Stop()
-	
+	if err := r.deleteAll(ctx, r.client.Collection("poll_votes").Where("chatId", "==", chatID)); err != nil {
+		return fmt.Errorf("failed to delete poll votes: %w", err)
+	}
+	if err := r.deleteAll(ctx, r.client.Collection("messages").Where("chatId", "==", chatID)); err != nil {
+		return fmt.Errorf("failed to delete messages: %w", err)
+	}
+	if err := r.deleteAll(ctx, r.client.Collection("chat_members").Where("chatId", "==", chatID)); err != nil {
+		return fmt.Errorf("failed to delete members: %w", err)
+	}
+
+	_, err := r.client.Collection("chats").Doc(chatID).Delete(ctx)
+	return err
+}
+
+// deleteAll deletes every document matching query, deletePageSize at a time.
+func (r *ChatRepo) deleteAll(ctx context.Context, query firestore.Query) error {
+	query = query.Limit(deletePageSize)
 	for {
-		doc, err := membersIter.Next()
-		if err == iterator.Done {
-			break
-		}
+		docs, err := query.Documents(ctx).GetAll()
 		if err != nil {
-			return fmt.Errorf("failed to iterate members for deletion: %w", err)
+			return err
 		}
-		batch.Delete(doc.Ref)
-	}
-	
-	messagesIter := r.client.Collection("messages").Where("chatId", "==", chatID).Documents(ctx)
-	defer messagesIter.Stop()
-	
-	for {
-		doc, err := messagesIter.Next()
-		if err == iterator.Done {
-			break
+		if len(docs) == 0 {
+			return nil
 		}
-		if err != nil {
-			return fmt.Errorf("failed to iterate messages for deletion: %w", err)
+
+		batch := r.client.Batch()
+		for _, doc := range docs {
+			batch.Delete(doc.Ref)
+		}
+		if _, err := batch.Commit(ctx); err != nil {
+			return err
+		}
+		if len(docs) < deletePageSize {
+			return nil
 		}
-		batch.Delete(doc.Ref)
 	}
-	
-	_, err := batch.Commit(ctx)
-	return err
+}
+
+func isMessageExpired(message models.Message, now time.Time) bool {
+	return message.ExpiresAt != nil && !message.ExpiresAt.After(now)
 }
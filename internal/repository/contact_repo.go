@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"Flare-server/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type ContactRepo struct {
+	client       *firestore.Client
+	requestsColl string
+	contactsColl string
+}
+
+func NewContactRepo(client *firestore.Client) *ContactRepo {
+	return &ContactRepo{
+		client:       client,
+		requestsColl: "friend_requests",
+		contactsColl: "contacts",
+	}
+}
+
+// CreateFriendRequest stores the request under a deterministic ID so there is at
+// most one request per direction; a declined or cancelled request can be re-sent.
+func (r *ContactRepo) CreateFriendRequest(ctx context.Context, req models.FriendRequest) (*models.FriendRequest, error) {
+	ref := r.client.Collection(r.requestsColl).Doc(req.FromUserID + "_" + req.ToUserID)
+
+	req.Status = models.FriendRequestPending
+	req.CreatedAt = time.Now()
+	req.UpdatedAt = time.Now()
+
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if current, _ := doc.DataAt("status"); current == string(models.FriendRequestPending) {
+				return fmt.Errorf("friend request already sent")
+			}
+		}
+		return tx.Set(ref, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req.ID = ref.ID
+	return &req, nil
+}
+
+func (r *ContactRepo) GetFriendRequest(ctx context.Context, requestID string) (*models.FriendRequest, error) {
+	doc, err := r.client.Collection(r.requestsColl).Doc(requestID).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("friend request not found")
+	}
+	return decodeFriendRequest(doc)
+}
+
+// GetPendingFriendRequest returns the pending request from fromUserID to toUserID,
+// or nil if there is none.
+func (r *ContactRepo) GetPendingFriendRequest(ctx context.Context, fromUserID, toUserID string) (*models.FriendRequest, error) {
+	req, err := r.GetFriendRequest(ctx, fromUserID+"_"+toUserID)
+	if err != nil || req.Status != models.FriendRequestPending {
+		return nil, nil
+	}
+	return req, nil
+}
+
+func (r *ContactRepo) GetPendingFriendRequests(ctx context.Context, userID string, incoming bool) ([]models.FriendRequest, error) {
+	field := "fromUserId"
+	if incoming {
+		field = "toUserId"
+	}
+
+	iter := r.client.Collection(r.requestsColl).
+		Where(field, "==", userID).
+		Where("status", "==", models.FriendRequestPending).
+		Documents(ctx)
+	defer iter.Stop()
+
+	requests := []models.FriendRequest{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate friend requests: %w", err)
+		}
+
+		req, err := decodeFriendRequest(doc)
+		if err != nil {
+			continue
+		}
+		requests = append(requests, *req)
+	}
+
+	return requests, nil
+}
+
+// ResolveFriendRequest moves a pending request to newStatus. When the request is
+// accepted, both contact entries are created in the same transaction.
+func (r *ContactRepo) ResolveFriendRequest(ctx context.Context, requestID string, newStatus models.FriendRequestStatus, check func(req *models.FriendRequest) error) (*models.FriendRequest, error) {
+	ref := r.client.Collection(r.requestsColl).Doc(requestID)
+
+	var resolved *models.FriendRequest
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return fmt.Errorf("friend request not found")
+		}
+
+		req, err := decodeFriendRequest(doc)
+		if err != nil {
+			return err
+		}
+		if err := check(req); err != nil {
+			return err
+		}
+		if req.Status != models.FriendRequestPending {
+			return fmt.Errorf("friend request is already %s", req.Status)
+		}
+
+		now := time.Now()
+		req.Status = newStatus
+		req.UpdatedAt = now
+
+		if newStatus == models.FriendRequestAccepted {
+			if err := tx.Set(r.contactRef(req.FromUserID, req.ToUserID), models.Contact{OwnerID: req.FromUserID, ContactID: req.ToUserID, CreatedAt: now}); err != nil {
+				return err
+			}
+			if err := tx.Set(r.contactRef(req.ToUserID, req.FromUserID), models.Contact{OwnerID: req.ToUserID, ContactID: req.FromUserID, CreatedAt: now}); err != nil {
+				return err
+			}
+		}
+
+		resolved = req
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: newStatus},
+			{Path: "updatedAt", Value: now},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resolved, nil
+}
+
+func (r *ContactRepo) GetContacts(ctx context.Context, ownerID string) ([]models.Contact, error) {
+	iter := r.client.Collection(r.contactsColl).Where("ownerId", "==", ownerID).Documents(ctx)
+	defer iter.Stop()
+
+	contacts := []models.Contact{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate contacts: %w", err)
+		}
+
+		var contact models.Contact
+		if err := doc.DataTo(&contact); err != nil {
+			continue
+		}
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, nil
+}
+
+func (r *ContactRepo) IsContact(ctx context.Context, ownerID, contactID string) (bool, error) {
+	_, err := r.contactRef(ownerID, contactID).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check contact: %w", err)
+	}
+	return true, nil
+}
+
+func (r *ContactRepo) UpdateContactNickname(ctx context.Context, ownerID, contactID, nickname string) error {
+	_, err := r.contactRef(ownerID, contactID).Update(ctx, []firestore.Update{
+		{Path: "nickname", Value: nickname},
+	})
+	if status.Code(err) == codes.NotFound {
+		return fmt.Errorf("contact not found")
+	}
+	return err
+}
+
+// DeleteContact removes the contact relationship in both directions.
+func (r *ContactRepo) DeleteContact(ctx context.Context, userID, contactID string) error {
+	batch := r.client.Batch()
+	batch.Delete(r.contactRef(userID, contactID))
+	batch.Delete(r.contactRef(contactID, userID))
+
+	_, err := batch.Commit(ctx)
+	return err
+}
+
+func (r *ContactRepo) contactRef(ownerID, contactID string) *firestore.DocumentRef {
+	return r.client.Collection(r.contactsColl).Doc(ownerID + "_" + contactID)
+}
+
+func decodeFriendRequest(doc *firestore.DocumentSnapshot) (*models.FriendRequest, error) {
+	var req models.FriendRequest
+	if err := doc.DataTo(&req); err != nil {
+		return nil, fmt.Errorf("failed to decode friend request: %w", err)
+	}
+	req.ID = doc.Ref.ID
+	return &req, nil
+}
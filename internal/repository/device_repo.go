@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"Flare-server/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// maxInQueryValues is the most values Firestore accepts in an "in" filter.
+const maxInQueryValues = 30
+
+// DeviceRepo stores push tokens under the hash of the token, so registering the
+// same token again, even from another account, updates the existing device.
+type DeviceRepo struct {
+	client *firestore.Client
+	coll   string
+}
+
+func NewDeviceRepo(client *firestore.Client) *DeviceRepo {
+	return &DeviceRepo{
+		client: client,
+		coll:   "devices",
+	}
+}
+
+// RegisterDevice creates the device or moves an existing token to the user.
+func (r *DeviceRepo) RegisterDevice(ctx context.Context, device models.Device) (*models.Device, error) {
+	ref := r.deviceRef(device.Token)
+	device.ID = ref.ID
+
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		now := time.Now()
+		device.CreatedAt = now
+		device.LastSeenAt = now
+
+		doc, err := tx.Get(ref)
+		if err == nil && doc.Exists() {
+			var existing models.Device
+			if err := doc.DataTo(&existing); err == nil && existing.UserID == device.UserID {
+				device.CreatedAt = existing.CreatedAt
+			}
+		}
+		return tx.Set(ref, device)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to register device: %w", err)
+	}
+	return &device, nil
+}
+
+func (r *DeviceRepo) GetUserDevices(ctx context.Context, userID string) ([]models.Device, error) {
+	return r.collect(ctx, r.client.Collection(r.coll).Where("userId", "==", userID))
+}
+
+// GetUsersDevices returns the devices of several users keyed by user ID.
+func (r *DeviceRepo) GetUsersDevices(ctx context.Context, userIDs []string) (map[string][]models.Device, error) {
+	result := make(map[string][]models.Device)
+	for start := 0; start < len(userIDs); start += maxInQueryValues {
+		end := min(start+maxInQueryValues, len(userIDs))
+
+		devices, err := r.collect(ctx, r.client.Collection(r.coll).Where("userId", "in", userIDs[start:end]))
+		if err != nil {
+			return nil, err
+		}
+		for _, device := range devices {
+			result[device.UserID] = append(result[device.UserID], device)
+		}
+	}
+	return result, nil
+}
+
+// DeleteDevice removes a device of the user. Devices of other users are reported
+// as not found.
+func (r *DeviceRepo) DeleteDevice(ctx context.Context, userID, deviceID string) error {
+	ref := r.client.Collection(r.coll).Doc(deviceID)
+	doc, err := ref.Get(ctx)
+	if err != nil {
+		return fmt.Errorf("device not found")
+	}
+
+	var device models.Device
+	if err := doc.DataTo(&device); err != nil || device.UserID != userID {
+		return fmt.Errorf("device not found")
+	}
+
+	_, err = ref.Delete(ctx)
+	return err
+}
+
+// DeleteTokens removes devices whose tokens the push provider no longer accepts.
+func (r *DeviceRepo) DeleteTokens(ctx context.Context, tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	batch := r.client.Batch()
+	for _, token := range tokens {
+		batch.Delete(r.deviceRef(token))
+	}
+	_, err := batch.Commit(ctx)
+	return err
+}
+
+func (r *DeviceRepo) collect(ctx context.Context, query firestore.Query) ([]models.Device, error) {
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	var devices []models.Device
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate devices: %w", err)
+		}
+
+		var device models.Device
+		if err := doc.DataTo(&device); err != nil {
+			continue
+		}
+		device.ID = doc.Ref.ID
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+func (r *DeviceRepo) deviceRef(token string) *firestore.DocumentRef {
+	sum := sha256.Sum256([]byte(token))
+	return r.client.Collection(r.coll).Doc(hex.EncodeToString(sum[:]))
+}
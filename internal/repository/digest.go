@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"Flare-server/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// SetDigestSchedule stores the digest frequency and when the next digest is due.
+// nextAt is nil when digests are off.
+func (r *UserRepo) SetDigestSchedule(ctx context.Context, userID string, frequency models.DigestFrequency, nextAt *time.Time) error {
+	return r.UpdateUser(ctx, userID, []firestore.Update{
+		{Path: "digestFrequency", Value: frequency},
+		{Path: "digestNextAt", Value: nextAt},
+	})
+}
+
+// GetDueDigestUsers returns users whose next digest is due at now.
+func (r *UserRepo) GetDueDigestUsers(ctx context.Context, now time.Time, limit int) ([]User, error) {
+	iter := r.client.Collection(r.usersColl).
+		Where("digestNextAt", "<=", now).
+		OrderBy("digestNextAt", firestore.Asc).
+		Limit(limit).
+		Documents(ctx)
+	defer iter.Stop()
+
+	var users []User
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to query due digests: %w", err)
+		}
+
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			continue
+		}
+		user.ID = doc.Ref.ID
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// ClaimDigest moves the user's next digest forward to next(user) in a
+// transaction, so only one replica sends it. It returns nil when the digest is no
+// longer due, e.g. because another replica claimed it first.
+func (r *UserRepo) ClaimDigest(ctx context.Context, userID string, now time.Time, next func(user *User) *time.Time) (*User, error) {
+	ref := r.client.Collection(r.usersColl).Doc(userID)
+
+	var claimed *User
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		claimed = nil
+
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+		user.ID = doc.Ref.ID
+		if user.DigestNextAt == nil || user.DigestNextAt.After(now) {
+			return nil
+		}
+
+		claimed = &user
+		return tx.Update(ref, []firestore.Update{{Path: "digestNextAt", Value: next(&user)}})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim digest: %w", err)
+	}
+	return claimed, nil
+}
+
+// FinishDigest records that the user's digest covered everything up to at.
+func (r *UserRepo) FinishDigest(ctx context.Context, userID string, at time.Time) error {
+	_, err := r.client.Collection(r.usersColl).Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "digestLastRunAt", Value: at},
+	})
+	return err
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var ErrIdentityLinked = errors.New("identity is already linked to another user")
+
+// UserIdentity links an external identity (an OIDC subject at a provider) to a
+// Flare user.
+type UserIdentity struct {
+	UserID    string    `firestore:"userId"`
+	Provider  string    `firestore:"provider"`
+	Subject   string    `firestore:"subject"`
+	Email     string    `firestore:"email"`
+	CreatedAt time.Time `firestore:"createdAt"`
+}
+
+// OIDCState is the server side of an authorization request, keyed by its state
+// parameter so any replica can finish the flow.
+type OIDCState struct {
+	Provider  string    `firestore:"provider"`
+	Verifier  string    `firestore:"verifier"`
+	Nonce     string    `firestore:"nonce"`
+	ExpiresAt time.Time `firestore:"expiresAt"`
+	// LinkUserID is set when a signed-in user links the identity to their account
+	// instead of logging in with it.
+	LinkUserID string `firestore:"linkUserId,omitempty"`
+}
+
+// GetUserByIdentity returns the user linked to the identity, or nil if it is not
+// linked yet.
+func (r *UserRepo) GetUserByIdentity(ctx context.Context, provider, subject string) (*User, error) {
+	doc, err := r.identityRef(provider, subject).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get identity: %w", err)
+	}
+
+	var identity UserIdentity
+	if err := doc.DataTo(&identity); err != nil {
+		return nil, fmt.Errorf("failed to decode identity: %w", err)
+	}
+	return r.GetUserByID(ctx, identity.UserID)
+}
+
+func (r *UserRepo) LinkIdentity(ctx context.Context, identity UserIdentity) error {
+	identity.CreatedAt = time.Now()
+
+	_, err := r.identityRef(identity.Provider, identity.Subject).Create(ctx, identity)
+	if status.Code(err) == codes.AlreadyExists {
+		return ErrIdentityLinked
+	}
+	if err != nil {
+		return fmt.Errorf("failed to link identity: %w", err)
+	}
+	return nil
+}
+
+func (r *UserRepo) GetUserByEmail(ctx context.Context, email string) (*User, error) {
+	iter := r.client.Collection(r.usersColl).Where("email", "==", strings.ToLower(email)).Limit(1).Documents(ctx)
+	defer iter.Stop()
+
+	doc, err := iter.Next()
+	if err == iterator.Done {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var user User
+	if err := doc.DataTo(&user); err != nil {
+		return nil, err
+	}
+	user.ID = doc.Ref.ID
+	return &user, nil
+}
+
+func (r *UserRepo) SaveOIDCState(ctx context.Context, state string, data OIDCState) error {
+	_, err := r.client.Collection(r.oidcStateColl).Doc(state).Set(ctx, data)
+	if err != nil {
+		return fmt.Errorf("failed to save login state: %w", err)
+	}
+	return nil
+}
+
+// ConsumeOIDCState returns and deletes the state, so a callback cannot be replayed.
+func (r *UserRepo) ConsumeOIDCState(ctx context.Context, state string) (*OIDCState, error) {
+	ref := r.client.Collection(r.oidcStateColl).Doc(state)
+
+	var data OIDCState
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return fmt.Errorf("invalid login state")
+		}
+		if err := doc.DataTo(&data); err != nil {
+			return err
+		}
+		return tx.Delete(ref)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if time.Now().After(data.ExpiresAt) {
+		return nil, fmt.Errorf("login state has expired")
+	}
+	return &data, nil
+}
+
+// GetUserIdentities returns the identities at provider linked to the user.
+func (r *UserRepo) GetUserIdentities(ctx context.Context, userID, provider string) ([]UserIdentity, error) {
+	iter := r.client.Collection(r.identityColl).
+		Where("userId", "==", userID).
+		Where("provider", "==", provider).
+		Documents(ctx)
+	defer iter.Stop()
+
+	var identities []UserIdentity
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get identities: %w", err)
+		}
+
+		var identity UserIdentity
+		if err := doc.DataTo(&identity); err != nil {
+			continue
+		}
+		identities = append(identities, identity)
+	}
+	return identities, nil
+}
+
+// identityRef hashes the key because subjects are opaque strings that may contain
+// '/'.
+func (r *UserRepo) identityRef(provider, subject string) *firestore.DocumentRef {
+	sum := sha256.Sum256([]byte(provider + ":" + subject))
+	return r.client.Collection(r.identityColl).Doc(hex.EncodeToString(sum[:]))
+}
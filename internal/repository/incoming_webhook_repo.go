@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"Flare-server/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+type IncomingWebhookRepo struct {
+	client *firestore.Client
+	coll   string
+}
+
+func NewIncomingWebhookRepo(client *firestore.Client) *IncomingWebhookRepo {
+	return &IncomingWebhookRepo{
+		client: client,
+		coll:   "incoming_webhooks",
+	}
+}
+
+func (r *IncomingWebhookRepo) CreateIncomingWebhook(ctx context.Context, hook models.IncomingWebhook) (*models.IncomingWebhook, error) {
+	hook.CreatedAt = time.Now()
+
+	docRef := r.client.Collection(r.coll).NewDoc()
+	hook.ID = docRef.ID
+	if _, err := docRef.Create(ctx, hook); err != nil {
+		return nil, fmt.Errorf("failed to create incoming webhook: %w", err)
+	}
+	return &hook, nil
+}
+
+func (r *IncomingWebhookRepo) GetIncomingWebhook(ctx context.Context, id string) (*models.IncomingWebhook, error) {
+	doc, err := r.client.Collection(r.coll).Doc(id).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("incoming webhook not found")
+	}
+
+	var hook models.IncomingWebhook
+	if err := doc.DataTo(&hook); err != nil {
+		return nil, err
+	}
+	hook.ID = doc.Ref.ID
+	return &hook, nil
+}
+
+func (r *IncomingWebhookRepo) GetChatIncomingWebhooks(ctx context.Context, chatID string) ([]models.IncomingWebhook, error) {
+	iter := r.client.Collection(r.coll).Where("chatId", "==", chatID).Documents(ctx)
+	defer iter.Stop()
+
+	var hooks []models.IncomingWebhook
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate incoming webhooks: %w", err)
+		}
+
+		var hook models.IncomingWebhook
+		if err := doc.DataTo(&hook); err != nil {
+			continue
+		}
+		hook.ID = doc.Ref.ID
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+func (r *IncomingWebhookRepo) DeleteIncomingWebhook(ctx context.Context, id string) error {
+	_, err := r.client.Collection(r.coll).Doc(id).Delete(ctx)
+	return err
+}
+
+func (r *IncomingWebhookRepo) TouchIncomingWebhook(ctx context.Context, id string) error {
+	_, err := r.client.Collection(r.coll).Doc(id).Update(ctx, []firestore.Update{
+		{Path: "lastUsedAt", Value: time.Now()},
+	})
+	return err
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// LoginAttempts tracks failed logins for one key, either a username or a client IP.
+type LoginAttempts struct {
+	Failures      int        `firestore:"failures"`
+	LastFailureAt time.Time  `firestore:"lastFailureAt"`
+	LockedUntil   *time.Time `firestore:"lockedUntil"`
+}
+
+// LoginAttemptsUpdate mutates the attempts record inside a transaction.
+type LoginAttemptsUpdate func(attempts *LoginAttempts) error
+
+func (r *UserRepo) GetLoginAttempts(ctx context.Context, key string) (*LoginAttempts, error) {
+	doc, err := r.attemptsRef(key).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return &LoginAttempts{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get login attempts: %w", err)
+	}
+
+	var attempts LoginAttempts
+	if err := doc.DataTo(&attempts); err != nil {
+		return nil, fmt.Errorf("failed to decode login attempts: %w", err)
+	}
+	return &attempts, nil
+}
+
+// UpdateLoginAttempts applies update transactionally so concurrent failures on
+// several replicas are all counted.
+func (r *UserRepo) UpdateLoginAttempts(ctx context.Context, key string, update LoginAttemptsUpdate) (*LoginAttempts, error) {
+	ref := r.attemptsRef(key)
+
+	var attempts LoginAttempts
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		attempts = LoginAttempts{}
+
+		doc, err := tx.Get(ref)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if err := doc.DataTo(&attempts); err != nil {
+				return err
+			}
+		}
+
+		if err := update(&attempts); err != nil {
+			return err
+		}
+		return tx.Set(ref, attempts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update login attempts: %w", err)
+	}
+	return &attempts, nil
+}
+
+func (r *UserRepo) ResetLoginAttempts(ctx context.Context, key string) error {
+	_, err := r.attemptsRef(key).Delete(ctx)
+	return err
+}
+
+// attemptsRef hashes the key because IPv6 addresses and usernames may contain
+// characters that are not valid in document IDs.
+func (r *UserRepo) attemptsRef(key string) *firestore.DocumentRef {
+	sum := sha256.Sum256([]byte(key))
+	return r.client.Collection(r.attemptsColl).Doc(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"Flare-server/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+type ModerationRepo struct {
+	client      *firestore.Client
+	reportsColl string
+	auditColl   string
+}
+
+func NewModerationRepo(client *firestore.Client) *ModerationRepo {
+	return &ModerationRepo{
+		client:      client,
+		reportsColl: "reports",
+		auditColl:   "moderation_audit",
+	}
+}
+
+func (r *ModerationRepo) CreateReport(ctx context.Context, report models.Report) (*models.Report, error) {
+	report.Status = models.ReportStatusOpen
+	report.CreatedAt = time.Now()
+
+	docRef, _, err := r.client.Collection(r.reportsColl).Add(ctx, report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create report: %w", err)
+	}
+
+	report.ID = docRef.ID
+	return &report, nil
+}
+
+func (r *ModerationRepo) GetReport(ctx context.Context, reportID string) (*models.Report, error) {
+	doc, err := r.client.Collection(r.reportsColl).Doc(reportID).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("report not found")
+	}
+
+	var report models.Report
+	if err := doc.DataTo(&report); err != nil {
+		return nil, fmt.Errorf("failed to decode report: %w", err)
+	}
+	report.ID = doc.Ref.ID
+	return &report, nil
+}
+
+// GetReports returns reports with the given status, oldest first so the queue is
+// worked in order.
+func (r *ModerationRepo) GetReports(ctx context.Context, status models.ReportStatus, limit int) ([]models.Report, error) {
+	iter := r.client.Collection(r.reportsColl).
+		Where("status", "==", status).
+		OrderBy("createdAt", firestore.Asc).
+		Limit(limit).
+		Documents(ctx)
+	defer iter.Stop()
+
+	reports := []models.Report{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate reports: %w", err)
+		}
+
+		var report models.Report
+		if err := doc.DataTo(&report); err != nil {
+			continue
+		}
+		report.ID = doc.Ref.ID
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// ResolveReport closes an open report. Closing an already closed report is an error
+// so two moderators cannot both act on it.
+func (r *ModerationRepo) ResolveReport(ctx context.Context, reportID string, status models.ReportStatus, moderatorID string) error {
+	ref := r.client.Collection(r.reportsColl).Doc(reportID)
+
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return fmt.Errorf("report not found")
+		}
+		if current, _ := doc.DataAt("status"); current != string(models.ReportStatusOpen) {
+			return fmt.Errorf("report is already %v", current)
+		}
+
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: status},
+			{Path: "resolvedBy", Value: moderatorID},
+			{Path: "resolvedAt", Value: time.Now()},
+		})
+	})
+}
+
+func (r *ModerationRepo) LogAction(ctx context.Context, action models.ModerationAction) (*models.ModerationAction, error) {
+	action.CreatedAt = time.Now()
+
+	docRef, _, err := r.client.Collection(r.auditColl).Add(ctx, action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log moderation action: %w", err)
+	}
+
+	action.ID = docRef.ID
+	return &action, nil
+}
+
+// DeleteAction removes an audit record, for an action that failed to apply after
+// it was logged.
+func (r *ModerationRepo) DeleteAction(ctx context.Context, actionID string) error {
+	if _, err := r.client.Collection(r.auditColl).Doc(actionID).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete moderation action: %w", err)
+	}
+	return nil
+}
+
+// GetAuditLog returns the most recent moderation actions, optionally only those
+// against targetID.
+func (r *ModerationRepo) GetAuditLog(ctx context.Context, targetID string, limit int) ([]models.ModerationAction, error) {
+	query := r.client.Collection(r.auditColl).Query
+	if targetID != "" {
+		query = query.Where("targetId", "==", targetID)
+	}
+
+	iter := query.OrderBy("createdAt", firestore.Desc).Limit(limit).Documents(ctx)
+	defer iter.Stop()
+
+	actions := []models.ModerationAction{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate moderation audit: %w", err)
+		}
+
+		var action models.ModerationAction
+		if err := doc.DataTo(&action); err != nil {
+			continue
+		}
+		action.ID = doc.Ref.ID
+		actions = append(actions, action)
+	}
+
+	return actions, nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+var ErrInvalidResetToken = errors.New("reset token is invalid or has expired")
+
+// PasswordReset is stored under the hash of its token, so a leaked database does
+// not expose usable reset links.
+type PasswordReset struct {
+	UserID    string     `firestore:"userId"`
+	CreatedAt time.Time  `firestore:"createdAt"`
+	ExpiresAt time.Time  `firestore:"expiresAt"`
+	UsedAt    *time.Time `firestore:"usedAt"`
+}
+
+func (r *UserRepo) CreatePasswordReset(ctx context.Context, token, userID string, ttl time.Duration) error {
+	now := time.Now()
+	_, err := r.resetRef(token).Set(ctx, PasswordReset{
+		UserID:    userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create password reset: %w", err)
+	}
+	return nil
+}
+
+// ResetPassword consumes the reset token and stores the new password hash in one
+// transaction, so a token can only ever be used once.
+func (r *UserRepo) ResetPassword(ctx context.Context, token, hashedPassword string) (*User, error) {
+	ref := r.resetRef(token)
+
+	var user User
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return ErrInvalidResetToken
+		}
+
+		var reset PasswordReset
+		if err := doc.DataTo(&reset); err != nil {
+			return err
+		}
+		now := time.Now()
+		if reset.UsedAt != nil || now.After(reset.ExpiresAt) {
+			return ErrInvalidResetToken
+		}
+
+		userRef := r.client.Collection(r.usersColl).Doc(reset.UserID)
+		userDoc, err := tx.Get(userRef)
+		if err != nil {
+			return fmt.Errorf("user not found")
+		}
+		if err := userDoc.DataTo(&user); err != nil {
+			return err
+		}
+		user.ID = userDoc.Ref.ID
+
+		if err := tx.Update(ref, []firestore.Update{{Path: "usedAt", Value: now}}); err != nil {
+			return err
+		}
+		return tx.Update(userRef, []firestore.Update{
+			{Path: "password", Value: hashedPassword},
+			{Path: "passwordChangedAt", Value: now},
+			{Path: "updatedAt", Value: now},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepo) resetRef(token string) *firestore.DocumentRef {
+	sum := sha256.Sum256([]byte(token))
+	return r.client.Collection(r.resetsColl).Doc(hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"Flare-server/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type PollRepo struct {
+	client    *firestore.Client
+	votesColl string
+}
+
+func NewPollRepo(client *firestore.Client) *PollRepo {
+	return &PollRepo{client: client, votesColl: "poll_votes"}
+}
+
+// PollVoteFunc receives the poll message and the caller's previous vote (nil if
+// none), mutates msg.Poll in place and returns the vote to store, or nil to remove it.
+type PollVoteFunc func(msg *models.Message, previous *models.PollVote) (*models.PollVote, error)
+
+// ApplyPollVote runs fn inside a transaction that covers both the poll message and
+// the user's vote document, so concurrent votes never lose counter updates.
+func (r *PollRepo) ApplyPollVote(ctx context.Context, messageID, userID string, fn PollVoteFunc) (*models.Message, error) {
+	msgRef := r.client.Collection("messages").Doc(messageID)
+	voteRef := r.client.Collection(r.votesColl).Doc(messageID + "_" + userID)
+
+	var result *models.Message
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		msg, err := getPollMessage(tx, msgRef)
+		if err != nil {
+			return err
+		}
+
+		var previous *models.PollVote
+		voteDoc, err := tx.Get(voteRef)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return fmt.Errorf("failed to get poll vote: %w", err)
+		}
+		if err == nil {
+			previous = &models.PollVote{}
+			if err := voteDoc.DataTo(previous); err != nil {
+				return fmt.Errorf("failed to decode poll vote: %w", err)
+			}
+		}
+
+		vote, err := fn(msg, previous)
+		if err != nil {
+			return err
+		}
+
+		if vote != nil {
+			if err := tx.Set(voteRef, vote); err != nil {
+				return err
+			}
+		} else if previous != nil {
+			if err := tx.Delete(voteRef); err != nil {
+				return err
+			}
+		}
+
+		result = msg
+		return tx.Update(msgRef, []firestore.Update{{Path: "poll", Value: msg.Poll}})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (r *PollRepo) UpdatePoll(ctx context.Context, messageID string, fn func(msg *models.Message) error) (*models.Message, error) {
+	msgRef := r.client.Collection("messages").Doc(messageID)
+
+	var result *models.Message
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		msg, err := getPollMessage(tx, msgRef)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(msg); err != nil {
+			return err
+		}
+
+		result = msg
+		return tx.Update(msgRef, []firestore.Update{{Path: "poll", Value: msg.Poll}})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func getPollMessage(tx *firestore.Transaction, ref *firestore.DocumentRef) (*models.Message, error) {
+	doc, err := tx.Get(ref)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, fmt.Errorf("poll not found")
+		}
+		return nil, fmt.Errorf("failed to get poll: %w", err)
+	}
+
+	var msg models.Message
+	if err := doc.DataTo(&msg); err != nil {
+		return nil, fmt.Errorf("failed to decode poll: %w", err)
+	}
+	msg.ID = doc.Ref.ID
+
+	if msg.Type != models.MessageTypePoll || msg.Poll == nil {
+		return nil, fmt.Errorf("message is not a poll")
+	}
+	if isMessageExpired(msg, time.Now()) {
+		return nil, fmt.Errorf("poll not found")
+	}
+
+	return &msg, nil
+}
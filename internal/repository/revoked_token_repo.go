@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// RevokedToken is a denylist entry keyed by token ID. It is only needed until the
+// token would have expired anyway, so ExpiresAt can also back a Firestore TTL
+// policy.
+type RevokedToken struct {
+	ID        string    `firestore:"-"`
+	UserID    string    `firestore:"userId"`
+	ExpiresAt time.Time `firestore:"expiresAt"`
+	RevokedAt time.Time `firestore:"revokedAt"`
+}
+
+type RevokedTokenRepo struct {
+	client *firestore.Client
+	coll   string
+}
+
+func NewRevokedTokenRepo(client *firestore.Client) *RevokedTokenRepo {
+	return &RevokedTokenRepo{
+		client: client,
+		coll:   "revoked_tokens",
+	}
+}
+
+func (r *RevokedTokenRepo) RevokeToken(ctx context.Context, token RevokedToken) error {
+	_, err := r.client.Collection(r.coll).Doc(token.ID).Set(ctx, token)
+	return err
+}
+
+func (r *RevokedTokenRepo) IsTokenRevoked(ctx context.Context, id string) (bool, error) {
+	_, err := r.client.Collection(r.coll).Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// GetRevokedTokensSince returns the entries revoked after since, oldest first.
+func (r *RevokedTokenRepo) GetRevokedTokensSince(ctx context.Context, since time.Time) ([]RevokedToken, error) {
+	iter := r.client.Collection(r.coll).Where("revokedAt", ">", since).OrderBy("revokedAt", firestore.Asc).Documents(ctx)
+	defer iter.Stop()
+
+	var tokens []RevokedToken
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate revoked tokens: %w", err)
+		}
+
+		var token RevokedToken
+		if err := doc.DataTo(&token); err != nil {
+			continue
+		}
+		token.ID = doc.Ref.ID
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+// DeleteExpiredRevokedTokens removes entries for tokens that have expired.
+func (r *RevokedTokenRepo) DeleteExpiredRevokedTokens(ctx context.Context, now time.Time) (int, error) {
+	iter := r.client.Collection(r.coll).Where("expiresAt", "<=", now).Documents(ctx)
+	defer iter.Stop()
+
+	deleted := 0
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("failed to iterate expired revoked tokens: %w", err)
+		}
+		if _, err := doc.Ref.Delete(ctx); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"Flare-server/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type ScheduledMessageRepo struct {
+	client *firestore.Client
+	coll   string
+}
+
+func NewScheduledMessageRepo(client *firestore.Client) *ScheduledMessageRepo {
+	return &ScheduledMessageRepo{client: client, coll: "scheduled_messages"}
+}
+
+func (r *ScheduledMessageRepo) CreateScheduledMessage(ctx context.Context, msg models.ScheduledMessage) (*models.ScheduledMessage, error) {
+	msg.Status = models.ScheduledStatusPending
+	msg.CreatedAt = time.Now()
+	msg.UpdatedAt = time.Now()
+
+	docRef, _, err := r.client.Collection(r.coll).Add(ctx, msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scheduled message: %w", err)
+	}
+
+	msg.ID = docRef.ID
+	return &msg, nil
+}
+
+func (r *ScheduledMessageRepo) GetScheduledMessage(ctx context.Context, id string) (*models.ScheduledMessage, error) {
+	doc, err := r.client.Collection(r.coll).Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, fmt.Errorf("scheduled message not found")
+		}
+		return nil, fmt.Errorf("failed to get scheduled message: %w", err)
+	}
+	return decodeScheduledMessage(doc)
+}
+
+func (r *ScheduledMessageRepo) GetPendingScheduledMessages(ctx context.Context, chatID, senderID string) ([]models.ScheduledMessage, error) {
+	iter := r.client.Collection(r.coll).
+		Where("chatId", "==", chatID).
+		Where("senderId", "==", senderID).
+		Where("status", "==", models.ScheduledStatusPending).
+		OrderBy("sendAt", firestore.Asc).
+		Documents(ctx)
+	defer iter.Stop()
+
+	messages := []models.ScheduledMessage{}
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate scheduled messages: %w", err)
+		}
+
+		msg, err := decodeScheduledMessage(doc)
+		if err != nil {
+			continue
+		}
+		messages = append(messages, *msg)
+	}
+
+	return messages, nil
+}
+
+// UpdatePendingScheduledMessage applies updates only while the message is still
+// pending, so an edit can never race with a scheduler that already claimed it.
+func (r *ScheduledMessageRepo) UpdatePendingScheduledMessage(ctx context.Context, id string, updates []firestore.Update) (*models.ScheduledMessage, error) {
+	ref := r.client.Collection(r.coll).Doc(id)
+	updates = append(updates, firestore.Update{Path: "updatedAt", Value: time.Now()})
+
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return fmt.Errorf("scheduled message not found")
+			}
+			return err
+		}
+
+		var current models.ScheduledMessage
+		if err := doc.DataTo(&current); err != nil {
+			return err
+		}
+		if current.Status != models.ScheduledStatusPending {
+			return fmt.Errorf("scheduled message is already %s", current.Status)
+		}
+
+		return tx.Update(ref, updates)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r.GetScheduledMessage(ctx, id)
+}
+
+// GetDueScheduledMessages returns pending messages whose send time has passed and
+// messages stuck in "sending" whose lease expired (e.g. the replica crashed).
+func (r *ScheduledMessageRepo) GetDueScheduledMessages(ctx context.Context, now time.Time, limit int) ([]models.ScheduledMessage, error) {
+	queries := []firestore.Query{
+		r.client.Collection(r.coll).
+			Where("status", "==", models.ScheduledStatusPending).
+			Where("sendAt", "<=", now).
+			OrderBy("sendAt", firestore.Asc).
+			Limit(limit),
+		r.client.Collection(r.coll).
+			Where("status", "==", models.ScheduledStatusSending).
+			Where("lockedUntil", "<=", now).
+			Limit(limit),
+	}
+
+	var messages []models.ScheduledMessage
+	for _, query := range queries {
+		iter := query.Documents(ctx)
+		for {
+			doc, err := iter.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				iter.Stop()
+				return nil, fmt.Errorf("failed to query due scheduled messages: %w", err)
+			}
+
+			msg, err := decodeScheduledMessage(doc)
+			if err != nil {
+				continue
+			}
+			messages = append(messages, *msg)
+		}
+		iter.Stop()
+	}
+
+	return messages, nil
+}
+
+// ClaimScheduledMessage atomically moves a due message into "sending" and leases it
+// to workerID. It returns nil without an error when another worker got there first.
+func (r *ScheduledMessageRepo) ClaimScheduledMessage(ctx context.Context, id, workerID string, lease time.Duration) (*models.ScheduledMessage, error) {
+	ref := r.client.Collection(r.coll).Doc(id)
+
+	var claimed *models.ScheduledMessage
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		claimed = nil
+
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+
+		msg, err := decodeScheduledMessage(doc)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		switch msg.Status {
+		case models.ScheduledStatusPending:
+			if msg.SendAt.After(now) {
+				return nil
+			}
+		case models.ScheduledStatusSending:
+			if msg.LockedUntil.After(now) {
+				return nil
+			}
+		default:
+			return nil
+		}
+
+		msg.Status = models.ScheduledStatusSending
+		msg.LockedBy = workerID
+		msg.LockedUntil = now.Add(lease)
+		msg.Attempts++
+
+		claimed = msg
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: msg.Status},
+			{Path: "lockedBy", Value: msg.LockedBy},
+			{Path: "lockedUntil", Value: msg.LockedUntil},
+			{Path: "attempts", Value: msg.Attempts},
+			{Path: "updatedAt", Value: now},
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim scheduled message: %w", err)
+	}
+
+	return claimed, nil
+}
+
+func (r *ScheduledMessageRepo) MarkScheduledMessageSent(ctx context.Context, id, messageID string) error {
+	now := time.Now()
+	_, err := r.client.Collection(r.coll).Doc(id).Update(ctx, []firestore.Update{
+		{Path: "status", Value: models.ScheduledStatusSent},
+		{Path: "messageId", Value: messageID},
+		{Path: "sentAt", Value: now},
+		{Path: "lockedBy", Value: ""},
+		{Path: "updatedAt", Value: now},
+	})
+	return err
+}
+
+func (r *ScheduledMessageRepo) MarkScheduledMessageFailed(ctx context.Context, id, reason string) error {
+	_, err := r.client.Collection(r.coll).Doc(id).Update(ctx, []firestore.Update{
+		{Path: "status", Value: models.ScheduledStatusFailed},
+		{Path: "error", Value: reason},
+		{Path: "lockedBy", Value: ""},
+		{Path: "updatedAt", Value: time.Now()},
+	})
+	return err
+}
+
+// ReleaseScheduledMessage hands a claimed message back to the pending queue after a
+// transient failure so the next scheduler tick can retry it.
+func (r *ScheduledMessageRepo) ReleaseScheduledMessage(ctx context.Context, id, reason string) error {
+	_, err := r.client.Collection(r.coll).Doc(id).Update(ctx, []firestore.Update{
+		{Path: "status", Value: models.ScheduledStatusPending},
+		{Path: "error", Value: reason},
+		{Path: "lockedBy", Value: ""},
+		{Path: "updatedAt", Value: time.Now()},
+	})
+	return err
+}
+
+func decodeScheduledMessage(doc *firestore.DocumentSnapshot) (*models.ScheduledMessage, error) {
+	var msg models.ScheduledMessage
+	if err := doc.DataTo(&msg); err != nil {
+		return nil, fmt.Errorf("failed to decode scheduled message: %w", err)
+	}
+	msg.ID = doc.Ref.ID
+	return &msg, nil
+}
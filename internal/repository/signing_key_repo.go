@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+// SigningKey is a token signing key. A key signs new tokens until RetiredAt and is
+// published for verification until ExpiresAt. The private key is stored
+// encrypted; PrivateKey only holds the plaintext of keys written before that,
+// until they are sealed.
+type SigningKey struct {
+	ID                  string     `firestore:"-"`
+	Algorithm           string     `firestore:"algorithm"`
+	PrivateKey          []byte     `firestore:"privateKey,omitempty"`
+	EncryptedPrivateKey []byte     `firestore:"encryptedPrivateKey,omitempty"`
+	CreatedAt           time.Time  `firestore:"createdAt"`
+	RetiredAt           *time.Time `firestore:"retiredAt"`
+	ExpiresAt           *time.Time `firestore:"expiresAt"`
+}
+
+type SigningKeyRepo struct {
+	client *firestore.Client
+	coll   string
+}
+
+func NewSigningKeyRepo(client *firestore.Client) *SigningKeyRepo {
+	return &SigningKeyRepo{
+		client: client,
+		coll:   "signing_keys",
+	}
+}
+
+func (r *SigningKeyRepo) GetSigningKeys(ctx context.Context) ([]SigningKey, error) {
+	iter := r.client.Collection(r.coll).OrderBy("createdAt", firestore.Desc).Documents(ctx)
+	defer iter.Stop()
+
+	var keys []SigningKey
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate signing keys: %w", err)
+		}
+
+		var key SigningKey
+		if err := doc.DataTo(&key); err != nil {
+			continue
+		}
+		key.ID = doc.Ref.ID
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// RotateSigningKey stores newKey and retires the keys that were signing until now.
+// It does nothing if an active key of the same algorithm was created after
+// rotateBefore, so replicas racing to rotate create only one key.
+func (r *SigningKeyRepo) RotateSigningKey(ctx context.Context, newKey SigningKey, rotateBefore time.Time, gracePeriod time.Duration) (bool, error) {
+	coll := r.client.Collection(r.coll)
+	rotated := false
+
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		rotated = false
+		docs, err := tx.Documents(coll.Where("retiredAt", "==", nil)).GetAll()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		expiresAt := now.Add(gracePeriod)
+		var retire []*firestore.DocumentRef
+		for _, doc := range docs {
+			var key SigningKey
+			if err := doc.DataTo(&key); err != nil {
+				continue
+			}
+			if key.Algorithm == newKey.Algorithm && key.CreatedAt.After(rotateBefore) {
+				return nil
+			}
+			retire = append(retire, doc.Ref)
+		}
+
+		for _, ref := range retire {
+			if err := tx.Update(ref, []firestore.Update{
+				{Path: "retiredAt", Value: now},
+				{Path: "expiresAt", Value: expiresAt},
+			}); err != nil {
+				return err
+			}
+		}
+
+		rotated = true
+		return tx.Create(coll.Doc(newKey.ID), newKey)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate signing key: %w", err)
+	}
+	return rotated, nil
+}
+
+// SealSigningKey replaces the plaintext private key of a key with its encrypted
+// form.
+func (r *SigningKeyRepo) SealSigningKey(ctx context.Context, id string, encrypted []byte) error {
+	_, err := r.client.Collection(r.coll).Doc(id).Update(ctx, []firestore.Update{
+		{Path: "encryptedPrivateKey", Value: encrypted},
+		{Path: "privateKey", Value: firestore.Delete},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to seal signing key: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredSigningKeys removes retired keys whose grace period is over.
+func (r *SigningKeyRepo) DeleteExpiredSigningKeys(ctx context.Context, now time.Time) (int, error) {
+	iter := r.client.Collection(r.coll).Where("expiresAt", "<=", now).Documents(ctx)
+	defer iter.Stop()
+
+	deleted := 0
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("failed to iterate expired signing keys: %w", err)
+		}
+		if _, err := doc.Ref.Delete(ctx); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
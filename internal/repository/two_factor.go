@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+var ErrCodeAlreadyUsed = errors.New("code has already been used")
+
+// UseTOTPStep records the time step of an accepted TOTP code. A code from the same
+// or an earlier step is rejected, so every code works only once.
+func (r *UserRepo) UseTOTPStep(ctx context.Context, userID string, step int64) error {
+	ref := r.client.Collection(r.usersColl).Doc(userID)
+
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+		if step <= user.TOTPLastStep {
+			return ErrCodeAlreadyUsed
+		}
+
+		return tx.Update(ref, []firestore.Update{{Path: "totpLastStep", Value: step}})
+	})
+}
+
+// UseRecoveryCode removes the hashed recovery code from the user, failing if it
+// was already consumed.
+func (r *UserRepo) UseRecoveryCode(ctx context.Context, userID, codeHash string) error {
+	ref := r.client.Collection(r.usersColl).Doc(userID)
+
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+
+		remaining := make([]string, 0, len(user.RecoveryCodes))
+		found := false
+		for _, hash := range user.RecoveryCodes {
+			if hash == codeHash && !found {
+				found = true
+				continue
+			}
+			remaining = append(remaining, hash)
+		}
+		if !found {
+			return ErrCodeAlreadyUsed
+		}
+
+		return tx.Update(ref, []firestore.Update{
+			{Path: "recoveryCodes", Value: remaining},
+			{Path: "updatedAt", Value: time.Now()},
+		})
+	})
+}
@@ -2,36 +2,158 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"Flare-server/internal/models"
+
 	"cloud.google.com/go/firestore"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type User struct {
-	ID        string    `firestore:"id" json:"id"`
-	Username  string    `firestore:"username" json:"username"`
-	Password  string    `firestore:"password" json:"-"`
-	CreatedAt time.Time `firestore:"createdAt" json:"createdAt"`
-	UpdatedAt time.Time `firestore:"updatedAt" json:"updatedAt"`
+	ID          string    `firestore:"id" json:"id"`
+	Username    string    `firestore:"username" json:"username"`
+	Password    string    `firestore:"password" json:"-"`
+	DisplayName string    `firestore:"displayName" json:"displayName,omitempty"`
+	AvatarURL   string    `firestore:"avatarUrl" json:"avatarUrl,omitempty"`
+	Bio         string    `firestore:"bio" json:"bio,omitempty"`
+	Locale      string    `firestore:"locale" json:"locale,omitempty"`
+	Timezone    string    `firestore:"timezone" json:"timezone,omitempty"`
+	CreatedAt   time.Time `firestore:"createdAt" json:"createdAt"`
+	UpdatedAt   time.Time `firestore:"updatedAt" json:"updatedAt"`
+
+	UsernameChangedAt *time.Time `firestore:"usernameChangedAt" json:"-"`
+	HiddenFromSearch  bool       `firestore:"hiddenFromSearch" json:"-"`
+
+	ContactsOnlyPrivateChats bool `firestore:"contactsOnlyPrivateChats" json:"-"`
+
+	GlobalRole       models.GlobalRole `firestore:"globalRole" json:"-"`
+	SuspendedUntil   *time.Time        `firestore:"suspendedUntil" json:"-"`
+	SuspensionReason string            `firestore:"suspensionReason" json:"-"`
+
+	Email             string     `firestore:"email" json:"-"`
+	PasswordChangedAt *time.Time `firestore:"passwordChangedAt" json:"-"`
+	SessionsRevokedAt *time.Time `firestore:"sessionsRevokedAt" json:"-"`
+	TokenVersion      int64      `firestore:"tokenVersion" json:"-"`
+
+	DigestFrequency models.DigestFrequency `firestore:"digestFrequency" json:"-"`
+	DigestNextAt    *time.Time             `firestore:"digestNextAt" json:"-"`
+	DigestLastRunAt *time.Time             `firestore:"digestLastRunAt" json:"-"`
+
+	TOTPEnabled       bool     `firestore:"totpEnabled" json:"-"`
+	TOTPSecret        string   `firestore:"totpSecret" json:"-"`
+	TOTPPendingSecret string   `firestore:"totpPendingSecret" json:"-"`
+	TOTPLastStep      int64    `firestore:"totpLastStep" json:"-"`
+	RecoveryCodes     []string `firestore:"recoveryCodes" json:"-"`
+
+	IsBot            bool   `firestore:"isBot" json:"-"`
+	BotOwnerID       string `firestore:"botOwnerId" json:"-"`
+	BotTokenHash     string `firestore:"botTokenHash" json:"-"`
+	BotWebhookURL    string `firestore:"botWebhookUrl" json:"-"`
+	BotWebhookSecret string `firestore:"botWebhookSecret" json:"-"`
+	BotWebhookActive bool   `firestore:"botWebhookActive" json:"-"`
+	BotLastUpdateID  int64  `firestore:"botLastUpdateId" json:"-"`
+
+	UsernameLower    string   `firestore:"usernameLower" json:"-"`
+	DisplayNameLower string   `firestore:"displayNameLower" json:"-"`
+	SearchTokens     []string `firestore:"searchTokens" json:"-"`
+}
+
+func (u *User) Profile() models.UserProfile {
+	return models.UserProfile{
+		ID:          u.ID,
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		AvatarURL:   u.AvatarURL,
+		Bio:         u.Bio,
+		Locale:      u.Locale,
+		Timezone:    u.Timezone,
+		Bot:         u.IsBot,
+		CreatedAt:   u.CreatedAt,
+	}
 }
 
-type TokenBlacklist struct {
-	Token string `firestore:"token"`
+func (u *User) Settings() models.UserSettings {
+	return models.UserSettings{
+		Discoverable:             !u.HiddenFromSearch,
+		ContactsOnlyPrivateChats: u.ContactsOnlyPrivateChats,
+		Email:                    u.Email,
+		TwoFactorEnabled:         u.TOTPEnabled,
+	}
 }
 
+func (u *User) IsSuspended(now time.Time) bool {
+	return u.SuspendedUntil != nil && u.SuspendedUntil.After(now)
+}
+
+// SessionRevoked reports whether a session started at issuedAt predates the last
+// password change or sign-out from all devices. Token timestamps have second
+// precision.
+func (u *User) SessionRevoked(issuedAt time.Time) bool {
+	for _, cutoff := range []*time.Time{u.PasswordChangedAt, u.SessionsRevokedAt} {
+		if cutoff != nil && issuedAt.Before(cutoff.Truncate(time.Second)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *User) IsModerator() bool {
+	return u.GlobalRole == models.GlobalRoleModerator || u.GlobalRole == models.GlobalRoleAdmin
+}
+
+type UserAvatar struct {
+	ContentType string    `firestore:"contentType"`
+	Data        []byte    `firestore:"data"`
+	UpdatedAt   time.Time `firestore:"updatedAt"`
+}
+
+type usernameReservation struct {
+	UserID    string    `firestore:"userId"`
+	Username  string    `firestore:"username"`
+	CreatedAt time.Time `firestore:"createdAt"`
+}
+
+// botOwner counts the bots of one owner. The document ID is the owner's user ID.
+type botOwner struct {
+	BotCount  int       `firestore:"botCount"`
+	UpdatedAt time.Time `firestore:"updatedAt"`
+}
+
+var (
+	ErrUsernameTaken         = errors.New("username is already taken")
+	ErrUsernameChangeTooSoon = errors.New("username was changed too recently")
+	ErrBotLimitReached       = errors.New("bot limit reached")
+)
+
 type UserRepo struct {
 	client        *firestore.Client
 	usersColl     string
-	blacklistColl string
+	avatarsColl   string
+	usernamesColl string
+	attemptsColl  string
+	resetsColl    string
+	identityColl  string
+	oidcStateColl string
+	botOwnersColl string
 }
 
 func NewUserRepo(client *firestore.Client) *UserRepo {
 	return &UserRepo{
 		client:        client,
 		usersColl:     "users",
-		blacklistColl: "blacklisted_tokens",
+		avatarsColl:   "user_avatars",
+		usernamesColl: "usernames",
+		attemptsColl:  "login_attempts",
+		resetsColl:    "password_resets",
+		identityColl:  "user_identities",
+		oidcStateColl: "oidc_states",
+		botOwnersColl: "bot_owners",
 	}
 }
 
@@ -69,11 +191,24 @@ func (r *UserRepo) GetUserByID(ctx context.Context, userID string) (*User, error
 	return &user, nil
 }
 
+// SaveUser creates the user together with a reservation document keyed by the
+// lower-cased username, so two concurrent registrations cannot claim the same name.
 func (r *UserRepo) SaveUser(ctx context.Context, user User) (*User, error) {
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
+	user.setSearchFields()
 	
-	docRef, _, err := r.client.Collection(r.usersColl).Add(ctx, user)
+	docRef := r.client.Collection(r.usersColl).NewDoc()
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := r.checkUsernameAvailable(tx, user.Username, docRef.ID); err != nil {
+			return err
+		}
+
+		if err := tx.Create(docRef, user); err != nil {
+			return err
+		}
+		return tx.Set(r.usernameRef(user.Username), usernameReservation{UserID: docRef.ID, Username: user.Username, CreatedAt: time.Now()})
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -82,21 +217,244 @@ func (r *UserRepo) SaveUser(ctx context.Context, user User) (*User, error) {
 	return &user, nil
 }
 
-func (r *UserRepo) AddToBlacklist(ctx context.Context, token string) error {
-	_, _, err := r.client.Collection(r.blacklistColl).Add(ctx, TokenBlacklist{Token: token})
-	return err
+// SaveBot creates a bot account like SaveUser. The owner's bot count is checked
+// against maxPerOwner and incremented in the same transaction, so concurrent
+// requests cannot exceed it.
+func (r *UserRepo) SaveBot(ctx context.Context, bot User, maxPerOwner int) (*User, error) {
+	bot.CreatedAt = time.Now()
+	bot.UpdatedAt = time.Now()
+	bot.setSearchFields()
+
+	docRef := r.client.Collection(r.usersColl).NewDoc()
+	ownerRef := r.client.Collection(r.botOwnersColl).Doc(bot.BotOwnerID)
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		count, err := r.botCount(tx, ownerRef, bot.BotOwnerID)
+		if err != nil {
+			return err
+		}
+		if count >= maxPerOwner {
+			return ErrBotLimitReached
+		}
+		if err := r.checkUsernameAvailable(tx, bot.Username, docRef.ID); err != nil {
+			return err
+		}
+
+		if err := tx.Create(docRef, bot); err != nil {
+			return err
+		}
+		if err := tx.Set(r.usernameRef(bot.Username), usernameReservation{UserID: docRef.ID, Username: bot.Username, CreatedAt: time.Now()}); err != nil {
+			return err
+		}
+		return tx.Set(ownerRef, botOwner{BotCount: count + 1, UpdatedAt: time.Now()})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bot.ID = docRef.ID
+	return &bot, nil
 }
 
-func (r *UserRepo) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
-	iter := r.client.Collection(r.blacklistColl).Where("token", "==", token).Limit(1).Documents(ctx)
-	defer iter.Stop()
+// botCount returns how many bots the owner has. Owners whose bots were created
+// before the count was kept have them counted instead.
+func (r *UserRepo) botCount(tx *firestore.Transaction, ownerRef *firestore.DocumentRef, ownerID string) (int, error) {
+	doc, err := tx.Get(ownerRef)
+	if err == nil {
+		var owner botOwner
+		if err := doc.DataTo(&owner); err != nil {
+			return 0, fmt.Errorf("failed to decode bot count: %w", err)
+		}
+		return owner.BotCount, nil
+	}
+	if status.Code(err) != codes.NotFound {
+		return 0, fmt.Errorf("failed to get bot count: %w", err)
+	}
 
-	_, err := iter.Next()
-	if err == iterator.Done {
-		return false, nil
+	docs, err := tx.Documents(r.client.Collection(r.usersColl).Where("botOwnerId", "==", ownerID)).GetAll()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count bots: %w", err)
+	}
+	return len(docs), nil
+}
+
+// ChangeUsername atomically moves the user's username reservation to newUsername.
+// The change is refused if the previous change happened after lastChangedBefore.
+func (r *UserRepo) ChangeUsername(ctx context.Context, userID, newUsername string, lastChangedBefore time.Time) (*User, error) {
+	userRef := r.client.Collection(r.usersColl).Doc(userID)
+
+	var updated User
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(userRef)
+		if err != nil {
+			return fmt.Errorf("user not found")
+		}
+
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			return err
+		}
+		user.ID = doc.Ref.ID
+
+		if user.UsernameChangedAt != nil && user.UsernameChangedAt.After(lastChangedBefore) {
+			return ErrUsernameChangeTooSoon
+		}
+		if user.Username == newUsername {
+			return fmt.Errorf("new username is the same as the current one")
+		}
+
+		if err := r.checkUsernameAvailable(tx, newUsername, userID); err != nil {
+			return err
+		}
+
+		oldRef := r.usernameRef(user.Username)
+		newRef := r.usernameRef(newUsername)
+		if oldRef.ID != newRef.ID {
+			if err := tx.Delete(oldRef); err != nil {
+				return err
+			}
+		}
+		if err := tx.Set(newRef, usernameReservation{UserID: userID, Username: newUsername, CreatedAt: time.Now()}); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		user.Username = newUsername
+		user.UsernameChangedAt = &now
+		user.UpdatedAt = now
+		user.setSearchFields()
+		updated = user
+
+		return tx.Update(userRef, []firestore.Update{
+			{Path: "username", Value: newUsername},
+			{Path: "usernameChangedAt", Value: now},
+			{Path: "updatedAt", Value: now},
+			{Path: "usernameLower", Value: user.UsernameLower},
+			{Path: "displayNameLower", Value: user.DisplayNameLower},
+			{Path: "searchTokens", Value: user.SearchTokens},
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	return &updated, nil
+}
+
+// checkUsernameAvailable looks at both the reservation document and the users
+// collection, because accounts created before reservations existed have none.
+func (r *UserRepo) checkUsernameAvailable(tx *firestore.Transaction, username, userID string) error {
+	doc, err := tx.Get(r.usernameRef(username))
+	if err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("failed to check username: %w", err)
+	}
+	if err == nil {
+		var reservation usernameReservation
+		if err := doc.DataTo(&reservation); err == nil && reservation.UserID != userID {
+			return ErrUsernameTaken
+		}
+	}
+
+	docs, err := tx.Documents(r.client.Collection(r.usersColl).Where("username", "==", username).Limit(1)).GetAll()
 	if err != nil {
-		return false, err
+		return fmt.Errorf("failed to check username: %w", err)
 	}
-	return true, nil
+	if len(docs) > 0 && docs[0].Ref.ID != userID {
+		return ErrUsernameTaken
+	}
+
+	return nil
+}
+
+func (r *UserRepo) usernameRef(username string) *firestore.DocumentRef {
+	return r.client.Collection(r.usernamesColl).Doc(strings.ToLower(username))
+}
+
+func (r *UserRepo) UpdateUser(ctx context.Context, userID string, updates []firestore.Update) error {
+	updates = append(updates, firestore.Update{Path: "updatedAt", Value: time.Now()})
+
+	_, err := r.client.Collection(r.usersColl).Doc(userID).Update(ctx, updates)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
+
+// GetUsersByIDs loads several users in one round trip. Missing users are left out
+// of the result rather than reported as an error.
+func (r *UserRepo) GetUsersByIDs(ctx context.Context, userIDs []string) (map[string]*User, error) {
+	users := make(map[string]*User)
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+
+	refs := make([]*firestore.DocumentRef, 0, len(userIDs))
+	seen := make(map[string]bool)
+	for _, id := range userIDs {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		refs = append(refs, r.client.Collection(r.usersColl).Doc(id))
+	}
+
+	docs, err := r.client.GetAll(ctx, refs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+
+		var user User
+		if err := doc.DataTo(&user); err != nil {
+			continue
+		}
+		user.ID = doc.Ref.ID
+		users[user.ID] = &user
+	}
+
+	return users, nil
+}
+
+func (r *UserRepo) SaveAvatar(ctx context.Context, userID string, avatar UserAvatar) error {
+	avatar.UpdatedAt = time.Now()
+
+	_, err := r.client.Collection(r.avatarsColl).Doc(userID).Set(ctx, avatar)
+	if err != nil {
+		return fmt.Errorf("failed to save avatar: %w", err)
+	}
+	return nil
+}
+
+func (r *UserRepo) GetAvatar(ctx context.Context, userID string) (*UserAvatar, error) {
+	doc, err := r.client.Collection(r.avatarsColl).Doc(userID).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("avatar not found")
+	}
+
+	var avatar UserAvatar
+	if err := doc.DataTo(&avatar); err != nil {
+		return nil, err
+	}
+	return &avatar, nil
+}
+
+func (r *UserRepo) DeleteAvatar(ctx context.Context, userID string) error {
+	_, err := r.client.Collection(r.avatarsColl).Doc(userID).Delete(ctx)
+	return err
+}
+
+// RevokeSessions invalidates every access token issued to the user so far and
+// records the time, so sessions started before it at an external identity
+// provider are rejected as well.
+func (r *UserRepo) RevokeSessions(ctx context.Context, userID string) error {
+	now := time.Now()
+	_, err := r.client.Collection(r.usersColl).Doc(userID).Update(ctx, []firestore.Update{
+		{Path: "tokenVersion", Value: firestore.Increment(1)},
+		{Path: "sessionsRevokedAt", Value: now},
+		{Path: "updatedAt", Value: now},
+	})
+	return err
 }
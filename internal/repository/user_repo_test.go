@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionRevoked(t *testing.T) {
+	signedIn := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	before := signedIn.Add(-time.Hour)
+	after := signedIn.Add(time.Hour)
+	sameSecond := signedIn.Add(500 * time.Millisecond)
+
+	tests := []struct {
+		name string
+		user User
+		want bool
+	}{
+		{"no cutoff", User{}, false},
+		{"password changed later", User{PasswordChangedAt: &after}, true},
+		{"password changed earlier", User{PasswordChangedAt: &before}, false},
+		{"signed out everywhere later", User{SessionsRevokedAt: &after}, true},
+		{"signed out everywhere earlier", User{SessionsRevokedAt: &before}, false},
+		{"either cutoff is enough", User{PasswordChangedAt: &before, SessionsRevokedAt: &after}, true},
+		// A token issued in the same second as the cutoff came after it.
+		{"same second", User{SessionsRevokedAt: &sameSecond}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.SessionRevoked(signedIn); got != tt.want {
+				t.Errorf("SessionRevoked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
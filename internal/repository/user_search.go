@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	searchCandidateLimit = 100
+	maxSearchQueryTokens = 10
+	// searchBackfillPage stays under the 500 writes a batch may contain.
+	searchBackfillPage = 200
+)
+
+// setSearchFields derives the lower-cased prefix fields and trigram tokens used by
+// SearchUsers. It must be called whenever the username or display name changes.
+func (u *User) setSearchFields() {
+	u.UsernameLower = strings.ToLower(u.Username)
+	u.DisplayNameLower = strings.ToLower(strings.TrimSpace(u.DisplayName))
+
+	seen := make(map[string]bool)
+	u.SearchTokens = nil
+	for _, value := range []string{u.UsernameLower, u.DisplayNameLower} {
+		for _, token := range SearchTrigrams(value) {
+			if !seen[token] {
+				seen[token] = true
+				u.SearchTokens = append(u.SearchTokens, token)
+			}
+		}
+	}
+}
+
+// RefreshSearchIndex recomputes the search fields from the stored user.
+func (r *UserRepo) RefreshSearchIndex(ctx context.Context, userID string) error {
+	user, err := r.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	user.setSearchFields()
+	_, err = r.client.Collection(r.usersColl).Doc(userID).Update(ctx, searchFieldUpdates(user))
+	return err
+}
+
+// BackfillSearchIndex pages over all users and writes the search fields of every
+// user whose stored fields are missing or stale, e.g. accounts created before
+// search existed. It is safe to run on several replicas at once and returns how
+// many users were updated.
+func (r *UserRepo) BackfillSearchIndex(ctx context.Context) (int, error) {
+	query := r.client.Collection(r.usersColl).OrderBy(firestore.DocumentID, firestore.Asc).Limit(searchBackfillPage)
+
+	updated := 0
+	for {
+		docs, err := query.Documents(ctx).GetAll()
+		if err != nil {
+			return updated, fmt.Errorf("failed to list users for search backfill: %w", err)
+		}
+
+		batch := r.client.Batch()
+		pending := 0
+		for _, doc := range docs {
+			var user User
+			if err := doc.DataTo(&user); err != nil {
+				continue
+			}
+			indexed := user
+			indexed.setSearchFields()
+			if indexed.UsernameLower == user.UsernameLower &&
+				indexed.DisplayNameLower == user.DisplayNameLower &&
+				equalStrings(indexed.SearchTokens, user.SearchTokens) {
+				continue
+			}
+			batch.Update(doc.Ref, searchFieldUpdates(&indexed))
+			pending++
+		}
+		if pending > 0 {
+			if _, err := batch.Commit(ctx); err != nil {
+				return updated, fmt.Errorf("failed to backfill search fields: %w", err)
+			}
+			updated += pending
+		}
+
+		if len(docs) < searchBackfillPage {
+			return updated, nil
+		}
+		query = query.StartAfter(docs[len(docs)-1])
+	}
+}
+
+func searchFieldUpdates(user *User) []firestore.Update {
+	return []firestore.Update{
+		{Path: "usernameLower", Value: user.UsernameLower},
+		{Path: "displayNameLower", Value: user.DisplayNameLower},
+		{Path: "searchTokens", Value: user.SearchTokens},
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SearchUsers returns candidate users whose username or display name starts with
+// query, plus users sharing trigrams with it for fuzzy matches. Ranking and access
+// filtering are left to the caller.
+func (r *UserRepo) SearchUsers(ctx context.Context, query string) ([]User, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	users := r.client.Collection(r.usersColl)
+
+	queries := []firestore.Query{
+		users.Where("usernameLower", ">=", query).Where("usernameLower", "<", query+"\uf8ff").Limit(searchCandidateLimit),
+		users.Where("displayNameLower", ">=", query).Where("displayNameLower", "<", query+"\uf8ff").Limit(searchCandidateLimit),
+	}
+
+	tokens := SearchTrigrams(query)
+	if len(tokens) > maxSearchQueryTokens {
+		tokens = tokens[:maxSearchQueryTokens]
+	}
+	if len(tokens) > 0 {
+		queries = append(queries, users.Where("searchTokens", "array-contains-any", tokens).Limit(searchCandidateLimit))
+	}
+
+	seen := make(map[string]bool)
+	var candidates []User
+	for _, q := range queries {
+		iter := q.Documents(ctx)
+		for {
+			doc, err := iter.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				iter.Stop()
+				return nil, fmt.Errorf("failed to search users: %w", err)
+			}
+			if seen[doc.Ref.ID] {
+				continue
+			}
+			seen[doc.Ref.ID] = true
+
+			var user User
+			if err := doc.DataTo(&user); err != nil {
+				continue
+			}
+			user.ID = doc.Ref.ID
+			candidates = append(candidates, user)
+		}
+		iter.Stop()
+	}
+
+	return candidates, nil
+}
+
+// SearchTrigrams splits a lower-cased value into overlapping three-rune tokens,
+// ignoring whitespace and punctuation.
+func SearchTrigrams(value string) []string {
+	runes := []rune(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, value))
+
+	if len(runes) < 3 {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var tokens []string
+	for i := 0; i+3 <= len(runes); i++ {
+		token := string(runes[i : i+3])
+		if !seen[token] {
+			seen[token] = true
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
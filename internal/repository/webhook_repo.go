@@ -0,0 +1,374 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"Flare-server/internal/models"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+type WebhookRepo struct {
+	client         *firestore.Client
+	webhooksColl   string
+	deliveriesColl string
+}
+
+func NewWebhookRepo(client *firestore.Client) *WebhookRepo {
+	return &WebhookRepo{
+		client:         client,
+		webhooksColl:   "chat_webhooks",
+		deliveriesColl: "webhook_deliveries",
+	}
+}
+
+func (r *WebhookRepo) CreateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error) {
+	webhook.CreatedAt = time.Now()
+	webhook.UpdatedAt = time.Now()
+
+	docRef := r.client.Collection(r.webhooksColl).NewDoc()
+	webhook.ID = docRef.ID
+	if _, err := docRef.Create(ctx, webhook); err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+	return &webhook, nil
+}
+
+func (r *WebhookRepo) GetWebhook(ctx context.Context, id string) (*models.Webhook, error) {
+	doc, err := r.client.Collection(r.webhooksColl).Doc(id).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	var webhook models.Webhook
+	if err := doc.DataTo(&webhook); err != nil {
+		return nil, err
+	}
+	webhook.ID = doc.Ref.ID
+	return &webhook, nil
+}
+
+func (r *WebhookRepo) GetChatWebhooks(ctx context.Context, chatID string) ([]models.Webhook, error) {
+	iter := r.client.Collection(r.webhooksColl).Where("chatId", "==", chatID).Documents(ctx)
+	defer iter.Stop()
+
+	var webhooks []models.Webhook
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to iterate webhooks: %w", err)
+		}
+
+		var webhook models.Webhook
+		if err := doc.DataTo(&webhook); err != nil {
+			continue
+		}
+		webhook.ID = doc.Ref.ID
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+func (r *WebhookRepo) DeleteWebhook(ctx context.Context, id string) error {
+	_, err := r.client.Collection(r.webhooksColl).Doc(id).Delete(ctx)
+	return err
+}
+
+// SetWebhookDisabled turns a webhook off or back on. Enabling it resets the
+// failure counter.
+func (r *WebhookRepo) SetWebhookDisabled(ctx context.Context, id string, disabled bool, reason string) error {
+	updates := []firestore.Update{
+		{Path: "disabled", Value: disabled},
+		{Path: "disabledReason", Value: reason},
+		{Path: "updatedAt", Value: time.Now()},
+	}
+	if !disabled {
+		updates = append(updates, firestore.Update{Path: "consecutiveFailures", Value: 0})
+	}
+	_, err := r.client.Collection(r.webhooksColl).Doc(id).Update(ctx, updates)
+	return err
+}
+
+// RecordWebhookResult tracks consecutive failed attempts and disables the webhook
+// once they reach disableAfter. It reports whether this call disabled it.
+func (r *WebhookRepo) RecordWebhookResult(ctx context.Context, id string, success bool, disableAfter int) (bool, error) {
+	ref := r.client.Collection(r.webhooksColl).Doc(id)
+
+	var disabled bool
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		disabled = false
+
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		var webhook models.Webhook
+		if err := doc.DataTo(&webhook); err != nil {
+			return err
+		}
+
+		if success {
+			if webhook.ConsecutiveFailures == 0 {
+				return nil
+			}
+			return tx.Update(ref, []firestore.Update{{Path: "consecutiveFailures", Value: 0}})
+		}
+
+		failures := webhook.ConsecutiveFailures + 1
+		updates := []firestore.Update{{Path: "consecutiveFailures", Value: failures}}
+		if failures >= disableAfter && !webhook.Disabled {
+			disabled = true
+			updates = append(updates,
+				firestore.Update{Path: "disabled", Value: true},
+				firestore.Update{Path: "disabledReason", Value: fmt.Sprintf("%d consecutive failed deliveries", failures)},
+				firestore.Update{Path: "updatedAt", Value: time.Now()},
+			)
+		}
+		return tx.Update(ref, updates)
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to record webhook result: %w", err)
+	}
+	return disabled, nil
+}
+
+func (r *WebhookRepo) EnqueueDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	now := time.Now()
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.NextAttemptAt = now
+	delivery.CreatedAt = now
+	delivery.UpdatedAt = now
+
+	docRef := r.client.Collection(r.deliveriesColl).NewDoc()
+	delivery.ID = docRef.ID
+	_, err := docRef.Create(ctx, delivery)
+	return err
+}
+
+// GetDueDeliveries returns pending deliveries whose next attempt is due and
+// deliveries stuck in "sending" whose lease expired.
+func (r *WebhookRepo) GetDueDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	queries := []firestore.Query{
+		r.client.Collection(r.deliveriesColl).
+			Where("status", "==", models.WebhookDeliveryPending).
+			Where("nextAttemptAt", "<=", now).
+			OrderBy("nextAttemptAt", firestore.Asc).
+			Limit(limit),
+		r.client.Collection(r.deliveriesColl).
+			Where("status", "==", models.WebhookDeliverySending).
+			Where("lockedUntil", "<=", now).
+			Limit(limit),
+	}
+
+	var deliveries []models.WebhookDelivery
+	for _, query := range queries {
+		found, err := r.queryDeliveries(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, found...)
+	}
+	return deliveries, nil
+}
+
+// ClaimDelivery atomically moves a due delivery into "sending" and leases it to
+// workerID. It returns nil without an error when another worker got there first.
+func (r *WebhookRepo) ClaimDelivery(ctx context.Context, id, workerID string, lease time.Duration) (*models.WebhookDelivery, error) {
+	ref := r.client.Collection(r.deliveriesColl).Doc(id)
+
+	var claimed *models.WebhookDelivery
+	err := r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		claimed = nil
+
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		delivery, err := decodeWebhookDelivery(doc)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		switch delivery.Status {
+		case models.WebhookDeliveryPending:
+			if delivery.NextAttemptAt.After(now) {
+				return nil
+			}
+		case models.WebhookDeliverySending:
+			if delivery.LockedUntil.After(now) {
+				return nil
+			}
+		default:
+			return nil
+		}
+
+		delivery.Status = models.WebhookDeliverySending
+		delivery.LockedBy = workerID
+		delivery.LockedUntil = now.Add(lease)
+		delivery.AttemptCount++
+
+		claimed = delivery
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: delivery.Status},
+			{Path: "lockedBy", Value: delivery.LockedBy},
+			{Path: "lockedUntil", Value: delivery.LockedUntil},
+			{Path: "attemptCount", Value: delivery.AttemptCount},
+			{Path: "updatedAt", Value: now},
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook delivery: %w", err)
+	}
+	return claimed, nil
+}
+
+// FinishDeliveryAttempt logs an attempt and moves the delivery to status. A
+// pending delivery is retried at nextAttemptAt.
+func (r *WebhookRepo) FinishDeliveryAttempt(ctx context.Context, id string, attempt models.WebhookAttempt, status models.WebhookDeliveryStatus, nextAttemptAt time.Time) error {
+	now := time.Now()
+	updates := []firestore.Update{
+		{Path: "status", Value: status},
+		{Path: "attempts", Value: firestore.ArrayUnion(attempt)},
+		{Path: "nextAttemptAt", Value: nextAttemptAt},
+		{Path: "lockedBy", Value: ""},
+		{Path: "updatedAt", Value: now},
+	}
+	if status == models.WebhookDeliveryDelivered {
+		updates = append(updates, firestore.Update{Path: "deliveredAt", Value: now})
+	}
+	_, err := r.client.Collection(r.deliveriesColl).Doc(id).Update(ctx, updates)
+	return err
+}
+
+// ReleaseDelivery hands a claimed delivery back to the queue without logging an
+// attempt, for when it could not be tried at all. The claimed attempt is not
+// counted.
+func (r *WebhookRepo) ReleaseDelivery(ctx context.Context, id string, nextAttemptAt time.Time) error {
+	_, err := r.client.Collection(r.deliveriesColl).Doc(id).Update(ctx, []firestore.Update{
+		{Path: "status", Value: models.WebhookDeliveryPending},
+		{Path: "attemptCount", Value: firestore.Increment(-1)},
+		{Path: "nextAttemptAt", Value: nextAttemptAt},
+		{Path: "lockedBy", Value: ""},
+		{Path: "updatedAt", Value: time.Now()},
+	})
+	return err
+}
+
+// GetDeliveries returns the newest deliveries of a webhook, optionally only those
+// with the given status.
+func (r *WebhookRepo) GetDeliveries(ctx context.Context, webhookID string, status models.WebhookDeliveryStatus, limit int) ([]models.WebhookDelivery, error) {
+	query := r.client.Collection(r.deliveriesColl).Where("webhookId", "==", webhookID)
+	if status != "" {
+		query = query.Where("status", "==", status)
+	}
+	return r.queryDeliveries(ctx, query.OrderBy("createdAt", firestore.Desc).Limit(limit))
+}
+
+func (r *WebhookRepo) GetDelivery(ctx context.Context, id string) (*models.WebhookDelivery, error) {
+	doc, err := r.client.Collection(r.deliveriesColl).Doc(id).Get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("delivery not found")
+	}
+	return decodeWebhookDelivery(doc)
+}
+
+// RequeueDelivery moves a dead delivery back to the queue with a fresh attempt
+// budget. The attempt log is kept.
+func (r *WebhookRepo) RequeueDelivery(ctx context.Context, id string) error {
+	ref := r.client.Collection(r.deliveriesColl).Doc(id)
+	return r.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(ref)
+		if err != nil {
+			return err
+		}
+		delivery, err := decodeWebhookDelivery(doc)
+		if err != nil {
+			return err
+		}
+		if delivery.Status != models.WebhookDeliveryDead {
+			return fmt.Errorf("only dead deliveries can be retried")
+		}
+
+		now := time.Now()
+		return tx.Update(ref, []firestore.Update{
+			{Path: "status", Value: models.WebhookDeliveryPending},
+			{Path: "attemptCount", Value: 0},
+			{Path: "nextAttemptAt", Value: now},
+			{Path: "updatedAt", Value: now},
+		})
+	})
+}
+
+// DeleteOldDeliveries removes finished deliveries created before the given time.
+func (r *WebhookRepo) DeleteOldDeliveries(ctx context.Context, before time.Time) (int, error) {
+	iter := r.client.Collection(r.deliveriesColl).Where("createdAt", "<", before).Documents(ctx)
+	defer iter.Stop()
+
+	deleted := 0
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("failed to iterate old webhook deliveries: %w", err)
+		}
+
+		delivery, err := decodeWebhookDelivery(doc)
+		if err == nil && (delivery.Status == models.WebhookDeliveryPending || delivery.Status == models.WebhookDeliverySending) {
+			continue
+		}
+		if _, err := doc.Ref.Delete(ctx); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+func (r *WebhookRepo) queryDeliveries(ctx context.Context, query firestore.Query) ([]models.WebhookDelivery, error) {
+	iter := query.Documents(ctx)
+	defer iter.Stop()
+
+	var deliveries []models.WebhookDelivery
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+		}
+
+		delivery, err := decodeWebhookDelivery(doc)
+		if err != nil {
+			continue
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries, nil
+}
+
+func decodeWebhookDelivery(doc *firestore.DocumentSnapshot) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := doc.DataTo(&delivery); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook delivery: %w", err)
+	}
+	delivery.ID = doc.Ref.ID
+	return &delivery, nil
+}
@@ -3,6 +3,8 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
 	"time"
 
 	"Flare-server/internal/repository"
@@ -11,20 +13,56 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+var (
+	ErrAccountSuspended  = errors.New("account is suspended")
+	ErrSessionRevoked    = errors.New("session has been revoked")
+	ErrTwoFactorRequired = errors.New("two-factor authentication is required: exchange the Firebase ID token at /api/auth/firebase")
+)
+
 type AuthService struct {
-	userRepo *repository.UserRepo
-	JWTKey   []byte
+	userRepo         *repository.UserRepo
+	usernamePolicy   *UsernamePolicy
+	passwordPolicy   PasswordPolicy
+	loginProtection  LoginProtection
+	broadcaster      Broadcaster
+	firebaseVerifier FirebaseTokenVerifier
+	keyRing          *KeyRing
+	denylist         *TokenDenylist
+	tokenOptions     TokenOptions
+	JWTKey           []byte
 }
 
-func NewAuthService(userRepo *repository.UserRepo, jwtKey []byte) *AuthService {
+func NewAuthService(userRepo *repository.UserRepo, denylist *TokenDenylist, usernamePolicy *UsernamePolicy, passwordPolicy PasswordPolicy, loginProtection LoginProtection, tokenOptions TokenOptions, jwtKey []byte) *AuthService {
 	return &AuthService{
-		userRepo: userRepo,
-		JWTKey:   jwtKey,
+		userRepo:        userRepo,
+		denylist:        denylist,
+		usernamePolicy:  usernamePolicy,
+		passwordPolicy:  passwordPolicy,
+		loginProtection: loginProtection,
+		tokenOptions:    tokenOptions,
+		JWTKey:          jwtKey,
 	}
 }
 
-func (s *AuthService) Register(ctx context.Context, username, password string) (*repository.User, error) {
-	_, err := s.userRepo.GetUserByUsername(ctx, username)
+// SetBroadcaster wires real-time notifications. It is set after construction
+// because the WebSocket hub itself depends on AuthService.
+func (s *AuthService) SetBroadcaster(broadcaster Broadcaster) {
+	s.broadcaster = broadcaster
+}
+
+func (s *AuthService) Register(ctx context.Context, username, password, email string) (*repository.User, error) {
+	if err := s.usernamePolicy.Validate(username); err != nil {
+		return nil, err
+	}
+	if err := s.passwordPolicy.Validate(username, password); err != nil {
+		return nil, err
+	}
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.userRepo.GetUserByUsername(ctx, username)
 	if err == nil {
 		return nil, errors.New("user already exists")
 	}
@@ -37,36 +75,190 @@ func (s *AuthService) Register(ctx context.Context, username, password string) (
 	user := repository.User{
 		Username: username,
 		Password: string(hashed),
+		Email:    email,
 	}
 
-	return s.userRepo.SaveUser(ctx, user)
+	savedUser, err := s.userRepo.SaveUser(ctx, user)
+	if errors.Is(err, repository.ErrUsernameTaken) {
+		return nil, errors.New("user already exists")
+	}
+	return savedUser, err
 }
 
-func (s *AuthService) Login(ctx context.Context, username, password string) (string, error) {
+// LoginResult holds either an access token or, for users with 2FA enabled, a
+// challenge token that must be completed with CompleteTwoFactorLogin.
+type LoginResult struct {
+	Token          string
+	ChallengeToken string
+}
+
+func (s *AuthService) Login(ctx context.Context, username, password, clientIP string) (*LoginResult, error) {
+	if err := s.checkLoginAllowed(ctx, loginAttemptsUserKey(username), loginAttemptsIPKey(clientIP)); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.GetUserByUsername(ctx, username)
 	if err != nil {
-		return "", errors.New("invalid credentials")
+		s.handleLoginFailure(ctx, username, clientIP, nil)
+		return nil, errors.New("invalid credentials")
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return "", errors.New("invalid credentials")
+		s.handleLoginFailure(ctx, username, clientIP, user)
+		return nil, errors.New("invalid credentials")
 	}
 
-	expirationTime := time.Now().Add(24 * time.Hour)
-	claims := &jwt.MapClaims{
-		"userID":   user.ID,
-		"username": user.Username,
-		"exp":      expirationTime.Unix(),
+	if err := checkNotSuspended(user); err != nil {
+		return nil, err
+	}
+	return s.beginLogin(ctx, user)
+}
+
+// beginLogin finishes a login whose first factor has been checked: users with
+// 2FA get a challenge, everyone else an access token.
+func (s *AuthService) beginLogin(ctx context.Context, user *repository.User) (*LoginResult, error) {
+	if user.TOTPEnabled {
+		challenge, err := s.issueLoginChallenge(user)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{ChallengeToken: challenge}, nil
+	}
+
+	token, err := s.completeLogin(ctx, user)
+	if err != nil {
+		return nil, err
 	}
+	return &LoginResult{Token: token}, nil
+}
 
+func (s *AuthService) completeLogin(ctx context.Context, user *repository.User) (string, error) {
+	if err := s.userRepo.ResetLoginAttempts(ctx, loginAttemptsUserKey(user.Username)); err != nil {
+		log.Printf("Failed to reset login attempts for %s: %v", user.ID, err)
+	}
+	return s.IssueToken(user)
+}
+
+func (s *AuthService) IssueToken(user *repository.User) (string, error) {
+	claims, err := s.newAccessClaims(user)
+	if err != nil {
+		return "", err
+	}
+
+	if s.keyRing != nil {
+		return s.keyRing.Sign(claims)
+	}
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(s.JWTKey)
 }
 
-func (s *AuthService) Logout(ctx context.Context, token string) error {
-	return s.userRepo.AddToBlacklist(ctx, token)
+// SetKeyRing switches access tokens from HS256 with JWTKey to the asymmetric keys
+// of the ring. Tokens signed with JWTKey are no longer accepted.
+func (s *AuthService) SetKeyRing(keyRing *KeyRing) {
+	s.keyRing = keyRing
+}
+
+// JWKS returns the public keys that verify access tokens. It is empty when tokens
+// are signed with the shared secret.
+func (s *AuthService) JWKS() map[string]interface{} {
+	if s.keyRing == nil {
+		return map[string]interface{}{"keys": []interface{}{}}
+	}
+	return s.keyRing.JWKS()
 }
 
-func (s *AuthService) IsTokenBlacklisted(ctx context.Context, token string) (bool, error) {
-	return s.userRepo.IsTokenBlacklisted(ctx, token)
+// ValidateToken checks a raw token the same way AuthMiddleware does and returns the
+// user it belongs to. It accepts Flare tokens and, when enabled, Firebase ID tokens.
+func (s *AuthService) ValidateToken(ctx context.Context, tokenString string) (*repository.User, error) {
+	if s.IsFirebaseToken(tokenString) {
+		if err := s.checkNotRevoked(ctx, firebaseTokenID(tokenString)); err != nil {
+			return nil, err
+		}
+		return s.validateFirebaseToken(ctx, tokenString)
+	}
+
+	claims, err := s.parseAccessToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkNotRevoked(ctx, claims.ID); err != nil {
+		return nil, err
+	}
+
+	user, err := s.checkSession(ctx, claims.UserID, claims.IssuedAt.Time)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Version != user.TokenVersion {
+		return nil, ErrSessionRevoked
+	}
+	return user, nil
+}
+
+func (s *AuthService) checkSession(ctx context.Context, userID string, issuedAt time.Time) (*repository.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, errors.New("user not found")
+	}
+	if err := checkNotSuspended(user); err != nil {
+		return nil, err
+	}
+	if user.SessionRevoked(issuedAt) {
+		return nil, ErrSessionRevoked
+	}
+	return user, nil
+}
+
+func (s *AuthService) checkNotRevoked(ctx context.Context, tokenID string) error {
+	revoked, err := s.denylist.IsRevoked(ctx, tokenID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return errors.New("token has been revoked")
+	}
+	return nil
+}
+
+// Logout revokes a single token until it expires.
+func (s *AuthService) Logout(ctx context.Context, tokenString string) error {
+	if s.IsFirebaseToken(tokenString) {
+		user, err := s.validateFirebaseToken(ctx, tokenString)
+		if err != nil {
+			return err
+		}
+		var claims jwt.RegisteredClaims
+		if _, _, err := jwt.NewParser().ParseUnverified(tokenString, &claims); err != nil || claims.ExpiresAt == nil {
+			return errors.New("invalid token")
+		}
+		return s.denylist.Revoke(ctx, firebaseTokenID(tokenString), user.ID, claims.ExpiresAt.Time)
+	}
+
+	claims, err := s.parseAccessToken(tokenString)
+	if err != nil {
+		return err
+	}
+	return s.denylist.Revoke(ctx, claims.ID, claims.UserID, claims.ExpiresAt.Time)
+}
+
+// RevokeAllTokens ends every session of the user by bumping the token version
+// that access tokens carry. The time is recorded too, which rejects Firebase ID
+// tokens of earlier Firebase sign-ins; the refresh tokens of linked Firebase
+// accounts are revoked as well, so those clients cannot get new ID tokens.
+func (s *AuthService) RevokeAllTokens(ctx context.Context, userID string) error {
+	if err := s.userRepo.RevokeSessions(ctx, userID); err != nil {
+		return fmt.Errorf("failed to revoke tokens: %w", err)
+	}
+	if s.broadcaster != nil {
+		s.broadcaster.DisconnectUser(userID)
+	}
+	s.revokeFirebaseSessions(ctx, userID)
+	return nil
+}
+
+func checkNotSuspended(user *repository.User) error {
+	if user.IsSuspended(time.Now()) {
+		return fmt.Errorf("%w until %s", ErrAccountSuspended, user.SuspendedUntil.Format(time.RFC3339))
+	}
+	return nil
 }
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/repository"
+)
+
+type BlockService struct {
+	blockRepo   *repository.BlockRepo
+	userRepo    *repository.UserRepo
+	contactRepo *repository.ContactRepo
+}
+
+func NewBlockService(blockRepo *repository.BlockRepo, userRepo *repository.UserRepo, contactRepo *repository.ContactRepo) *BlockService {
+	return &BlockService{
+		blockRepo:   blockRepo,
+		userRepo:    userRepo,
+		contactRepo: contactRepo,
+	}
+}
+
+// BlockUser blocks the user or updates the hideMessages flag of an existing block.
+// Blocking also ends the contact relationship and any pending friend requests.
+func (s *BlockService) BlockUser(ctx context.Context, blockerID string, req models.BlockUserRequest) (*models.Block, error) {
+	blocked, err := s.userRepo.GetUserByUsername(ctx, strings.TrimSpace(req.Username))
+	if err != nil {
+		return nil, fmt.Errorf("user %s not found", req.Username)
+	}
+	if blocked.ID == blockerID {
+		return nil, fmt.Errorf("cannot block yourself")
+	}
+
+	block := models.Block{
+		BlockerID:    blockerID,
+		BlockedID:    blocked.ID,
+		HideMessages: req.HideMessages,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.blockRepo.Block(ctx, block); err != nil {
+		return nil, err
+	}
+
+	if err := s.contactRepo.DeleteContact(ctx, blockerID, blocked.ID); err != nil {
+		log.Printf("Failed to remove contact %s of user %s after block: %v", blocked.ID, blockerID, err)
+	}
+	s.cancelPendingRequest(ctx, blockerID, blocked.ID)
+	s.cancelPendingRequest(ctx, blocked.ID, blockerID)
+
+	profile := blocked.Profile()
+	block.Profile = &profile
+	return &block, nil
+}
+
+func (s *BlockService) UnblockUser(ctx context.Context, blockerID, blockedID string) error {
+	return s.blockRepo.Unblock(ctx, blockerID, blockedID)
+}
+
+func (s *BlockService) GetBlockedUsers(ctx context.Context, blockerID string) (*models.BlockListResponse, error) {
+	blocks, err := s.blockRepo.GetBlocks(ctx, blockerID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(blocks))
+	for _, block := range blocks {
+		ids = append(ids, block.BlockedID)
+	}
+
+	users, err := s.userRepo.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		log.Printf("Failed to load blocked user profiles for user %s: %v", blockerID, err)
+	}
+	for i := range blocks {
+		if user, ok := users[blocks[i].BlockedID]; ok {
+			profile := user.Profile()
+			blocks[i].Profile = &profile
+		}
+	}
+
+	return &models.BlockListResponse{Blocks: blocks}, nil
+}
+
+func (s *BlockService) cancelPendingRequest(ctx context.Context, fromUserID, toUserID string) {
+	req, err := s.contactRepo.GetPendingFriendRequest(ctx, fromUserID, toUserID)
+	if err != nil || req == nil {
+		return
+	}
+
+	_, err = s.contactRepo.ResolveFriendRequest(ctx, req.ID, models.FriendRequestCancelled, func(*models.FriendRequest) error {
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to cancel friend request %s after block: %v", req.ID, err)
+	}
+}
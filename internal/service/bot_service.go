@@ -0,0 +1,612 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/repository"
+
+	"cloud.google.com/go/firestore"
+)
+
+const (
+	maxBotUpdatesPerRequest = 100
+	maxGetUpdatesTimeout    = 50 * time.Second
+	maxWebhookSecretLength  = 256
+
+	maxBotCommandsPerChat    = 50
+	maxBotCommandDescription = 256
+
+	// A long poll re-reads Firestore this often to see updates stored by other
+	// replicas. Updates stored by this replica wake it at once.
+	botUpdatePollInterval = 2 * time.Second
+)
+
+var (
+	ErrInvalidBotToken  = errors.New("invalid bot token")
+	ErrBotWebhookActive = errors.New("webhook is active; delete it to use getUpdates")
+)
+
+// BotOptions configures bot accounts.
+type BotOptions struct {
+	MaxPerOwner int
+	// UpdateRetention is how long an update waits to be fetched or delivered.
+	UpdateRetention time.Duration
+	WebhookTimeout  time.Duration
+	// AllowPrivateWebhooks permits http and private network webhook URLs, for
+	// development.
+	AllowPrivateWebhooks bool
+}
+
+// BotService manages bot accounts owned by people and runs the Bot API. Bots are
+// users with IsBot set; they join chats like anyone else and post through
+// ChatService, so membership, blocks, filters and slow mode apply to them too.
+type BotService struct {
+	userRepo       *repository.UserRepo
+	botRepo        *repository.BotRepo
+	chatRepo       *repository.ChatRepo
+	chatService    *ChatService
+	usernamePolicy *UsernamePolicy
+	broadcaster    Broadcaster
+	options        BotOptions
+	httpClient     *http.Client
+
+	mu         sync.Mutex
+	waiters    map[string]chan struct{}
+	delivering map[string]bool
+}
+
+func NewBotService(userRepo *repository.UserRepo, botRepo *repository.BotRepo, chatRepo *repository.ChatRepo, chatService *ChatService, usernamePolicy *UsernamePolicy, broadcaster Broadcaster, options BotOptions) *BotService {
+	return &BotService{
+		userRepo:       userRepo,
+		botRepo:        botRepo,
+		chatRepo:       chatRepo,
+		chatService:    chatService,
+		usernamePolicy: usernamePolicy,
+		broadcaster:    broadcaster,
+		options:        options,
+		httpClient:     newOutboundHTTPClient(options.WebhookTimeout, options.AllowPrivateWebhooks),
+		waiters:        make(map[string]chan struct{}),
+		delivering:     make(map[string]bool),
+	}
+}
+
+func (s *BotService) CreateBot(ctx context.Context, ownerID string, req models.CreateBotRequest) (*models.BotTokenResponse, error) {
+	owner, err := s.userRepo.GetUserByID(ctx, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if owner.IsBot {
+		return nil, fmt.Errorf("bots cannot create bots")
+	}
+
+	username := strings.TrimSpace(req.Username)
+	if err := s.usernamePolicy.Validate(username); err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(strings.ToLower(username), "bot") {
+		return nil, fmt.Errorf("bot username must end with \"bot\"")
+	}
+	displayName := strings.TrimSpace(req.DisplayName)
+	if len([]rune(displayName)) > maxDisplayNameLength {
+		return nil, fmt.Errorf("display name cannot be longer than %d characters", maxDisplayNameLength)
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	bot, err := s.userRepo.SaveBot(ctx, repository.User{
+		Username:     username,
+		DisplayName:  displayName,
+		IsBot:        true,
+		BotOwnerID:   ownerID,
+		BotTokenHash: hashSecret(secret),
+	}, s.options.MaxPerOwner)
+	if errors.Is(err, repository.ErrBotLimitReached) {
+		return nil, fmt.Errorf("you cannot own more than %d bots", s.options.MaxPerOwner)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.BotTokenResponse{Bot: botView(bot), Token: bot.ID + ":" + secret}, nil
+}
+
+func (s *BotService) GetBots(ctx context.Context, ownerID string) (*models.BotListResponse, error) {
+	bots, err := s.botRepo.GetBotsByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.BotListResponse{Bots: make([]models.Bot, 0, len(bots))}
+	for i := range bots {
+		response.Bots = append(response.Bots, botView(&bots[i]))
+	}
+	return response, nil
+}
+
+// RegenerateToken replaces the bot's token. The old token stops working at once.
+func (s *BotService) RegenerateToken(ctx context.Context, ownerID, botID string) (*models.BotTokenResponse, error) {
+	bot, err := s.userRepo.GetUserByID(ctx, botID)
+	if err != nil || !bot.IsBot || bot.BotOwnerID != ownerID {
+		return nil, fmt.Errorf("bot not found")
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.UpdateUser(ctx, bot.ID, []firestore.Update{{Path: "botTokenHash", Value: hashSecret(secret)}}); err != nil {
+		return nil, err
+	}
+
+	return &models.BotTokenResponse{Bot: botView(bot), Token: bot.ID + ":" + secret}, nil
+}
+
+// AuthenticateBot resolves a "<bot id>:<secret>" token to its bot.
+func (s *BotService) AuthenticateBot(ctx context.Context, token string) (*repository.User, error) {
+	botID, secret, ok := strings.Cut(token, ":")
+	if !ok || botID == "" || secret == "" || strings.Contains(botID, "/") {
+		return nil, ErrInvalidBotToken
+	}
+
+	bot, err := s.userRepo.GetUserByID(ctx, botID)
+	if err != nil || !bot.IsBot {
+		return nil, ErrInvalidBotToken
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(bot.BotTokenHash)) != 1 {
+		return nil, ErrInvalidBotToken
+	}
+	if err := checkNotSuspended(bot); err != nil {
+		return nil, err
+	}
+	return bot, nil
+}
+
+func (s *BotService) GetMe(ctx context.Context, botID string) (*models.UserProfile, error) {
+	bot, err := s.userRepo.GetUserByID(ctx, botID)
+	if err != nil {
+		return nil, err
+	}
+	profile := bot.Profile()
+	return &profile, nil
+}
+
+// SendMessage posts as the bot and broadcasts the message like the WebSocket
+// send_message action does.
+func (s *BotService) SendMessage(ctx context.Context, botID string, req models.BotSendMessageRequest) (*models.Message, error) {
+	bot, err := s.userRepo.GetUserByID(ctx, botID)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := s.chatService.SendBotMessage(ctx, req.ChatID, bot, models.SendMessageRequest{Text: req.Text, ReplyTo: req.ReplyTo})
+	if err != nil {
+		return nil, err
+	}
+
+	s.broadcaster.BroadcastMessageExcept(message.ChatID, "new_message", message, s.chatService.MessageRecipientsToSkip(ctx, bot.ID))
+	return message, nil
+}
+
+// GetUpdates confirms the updates below req.Offset and returns the following
+// ones, waiting up to req.Timeout seconds if there are none yet.
+func (s *BotService) GetUpdates(ctx context.Context, botID string, req models.GetUpdatesRequest) (*models.BotUpdatesResponse, error) {
+	bot, err := s.userRepo.GetUserByID(ctx, botID)
+	if err != nil {
+		return nil, err
+	}
+	if bot.BotWebhookActive {
+		return nil, ErrBotWebhookActive
+	}
+
+	limit := req.Limit
+	if limit <= 0 || limit > maxBotUpdatesPerRequest {
+		limit = maxBotUpdatesPerRequest
+	}
+	timeout := time.Duration(req.Timeout) * time.Second
+	if timeout < 0 {
+		timeout = 0
+	}
+	if timeout > maxGetUpdatesTimeout {
+		timeout = maxGetUpdatesTimeout
+	}
+
+	if req.Offset > 0 {
+		if err := s.botRepo.ConfirmBotUpdates(ctx, botID, req.Offset); err != nil {
+			return nil, err
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		wake := s.waitForUpdate(botID)
+		updates, err := s.botRepo.GetBotUpdates(ctx, botID, req.Offset, limit)
+		if err != nil {
+			return nil, err
+		}
+		remaining := time.Until(deadline)
+		if len(updates) > 0 || remaining <= 0 {
+			if updates == nil {
+				updates = []models.BotUpdate{}
+			}
+			return &models.BotUpdatesResponse{Updates: updates}, nil
+		}
+
+		timer := time.NewTimer(min(remaining, botUpdatePollInterval))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+func (s *BotService) SetWebhook(ctx context.Context, botID string, req models.SetWebhookRequest) error {
+	webhookURL := strings.TrimSpace(req.URL)
+	if webhookURL == "" {
+		return fmt.Errorf("url is required")
+	}
+	if err := validateCallbackURL(webhookURL, s.options.AllowPrivateWebhooks); err != nil {
+		return err
+	}
+	if len(req.SecretToken) > maxWebhookSecretLength {
+		return fmt.Errorf("secretToken cannot be longer than %d characters", maxWebhookSecretLength)
+	}
+
+	if err := s.botRepo.SetBotWebhook(ctx, botID, webhookURL, req.SecretToken); err != nil {
+		return err
+	}
+
+	bot, err := s.userRepo.GetUserByID(ctx, botID)
+	if err == nil {
+		go s.deliverWebhook(context.Background(), *bot)
+	}
+	return nil
+}
+
+// DeleteWebhook switches the bot back to getUpdates. Pending updates are kept.
+func (s *BotService) DeleteWebhook(ctx context.Context, botID string) error {
+	return s.botRepo.SetBotWebhook(ctx, botID, "", "")
+}
+
+// HandleChatEvent turns chat events into updates for the bots in the chat. Bots
+// do not receive their own messages.
+func (s *BotService) HandleChatEvent(ctx context.Context, event models.ChatEvent) {
+	var update models.BotUpdate
+	switch event.Type {
+	case models.ChatEventMessageCreated:
+		update = models.BotUpdate{Type: models.BotUpdateMessage, Message: event.Message}
+	case models.ChatEventMemberJoined, models.ChatEventMemberLeft:
+		status := "joined"
+		if event.Type == models.ChatEventMemberLeft {
+			status = "left"
+		}
+		update = models.BotUpdate{Type: models.BotUpdateChatMember, ChatMember: &models.BotChatMemberUpdate{
+			ChatID:   event.ChatID,
+			UserID:   event.Member.UserID,
+			Username: event.Member.Username,
+			Status:   status,
+		}}
+	default:
+		return
+	}
+
+	members, err := s.chatRepo.GetChatMembers(ctx, event.ChatID)
+	if err != nil {
+		log.Printf("Failed to get members of chat %s for bot updates: %v", event.ChatID, err)
+		return
+	}
+
+	var botIDs []string
+	for _, member := range members {
+		if member.Bot && !(event.Type == models.ChatEventMessageCreated && member.UserID == event.ActorID) {
+			botIDs = append(botIDs, member.UserID)
+		}
+	}
+	// A bot that was removed is no longer a member but should still learn about it.
+	if event.Type == models.ChatEventMemberLeft && event.Member.Bot {
+		botIDs = append(botIDs, event.Member.UserID)
+	}
+	if len(botIDs) == 0 {
+		return
+	}
+
+	bots, err := s.userRepo.GetUsersByIDs(ctx, botIDs)
+	if err != nil {
+		log.Printf("Failed to load bots of chat %s: %v", event.ChatID, err)
+		return
+	}
+
+	for _, bot := range bots {
+		if !bot.IsBot {
+			continue
+		}
+		if err := s.queueUpdate(ctx, bot, update); err != nil {
+			log.Printf("Failed to store update for bot %s: %v", bot.ID, err)
+		}
+	}
+
+	// A bot's commands in a chat go away when it leaves.
+	if event.Type == models.ChatEventMemberLeft && event.Member.Bot {
+		if err := s.botRepo.DeleteBotCommands(ctx, event.Member.UserID, event.ChatID); err != nil {
+			log.Printf("Failed to delete commands of bot %s in chat %s: %v", event.Member.UserID, event.ChatID, err)
+		}
+	}
+}
+
+// SetCommands replaces the slash commands the bot handles in a chat it is a
+// member of.
+func (s *BotService) SetCommands(ctx context.Context, botID string, req models.SetCommandsRequest) error {
+	isMember, err := s.chatService.IsUserInChat(ctx, req.ChatID, botID)
+	if err != nil {
+		return fmt.Errorf("failed to check chat membership: %w", err)
+	}
+	if !isMember {
+		return fmt.Errorf("access denied: bot is not a member of this chat")
+	}
+
+	if len(req.Commands) > maxBotCommandsPerChat {
+		return fmt.Errorf("a bot can register at most %d commands per chat", maxBotCommandsPerChat)
+	}
+	seen := make(map[string]bool, len(req.Commands))
+	for i, cmd := range req.Commands {
+		name := strings.TrimPrefix(strings.TrimSpace(cmd.Command), "/")
+		if !commandNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid command name %q: use 1-32 lowercase letters, digits or underscores", cmd.Command)
+		}
+		if s.chatService.isBuiltinCommand(name) {
+			return fmt.Errorf("/%s is a built-in command", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate command /%s", name)
+		}
+		seen[name] = true
+
+		description := strings.TrimSpace(cmd.Description)
+		if len([]rune(description)) > maxBotCommandDescription {
+			return fmt.Errorf("command description cannot be longer than %d characters", maxBotCommandDescription)
+		}
+		req.Commands[i] = models.BotCommand{Command: name, Description: description}
+	}
+
+	return s.botRepo.SetBotCommands(ctx, botID, req.ChatID, req.Commands)
+}
+
+// SendEphemeral shows a reply from the bot to one member of a chat the bot is in,
+// typically the user who ran one of its commands.
+func (s *BotService) SendEphemeral(ctx context.Context, botID string, req models.SendEphemeralRequest) error {
+	isMember, err := s.chatService.IsUserInChat(ctx, req.ChatID, botID)
+	if err != nil {
+		return fmt.Errorf("failed to check chat membership: %w", err)
+	}
+	if !isMember {
+		return fmt.Errorf("access denied: bot is not a member of this chat")
+	}
+
+	return s.chatService.SendEphemeral(ctx, req.ChatID, req.UserID, models.CommandResponse{
+		BotID: botID,
+		Text:  req.Text,
+	})
+}
+
+// ChatCommands returns the commands the chat's bots registered. Running one
+// sends the bot a "command" update.
+func (s *BotService) ChatCommands(ctx context.Context, chatID string) ([]SlashCommand, error) {
+	sets, err := s.botRepo.GetChatBotCommands(ctx, chatID)
+	if err != nil || len(sets) == 0 {
+		return nil, err
+	}
+
+	botIDs := make([]string, 0, len(sets))
+	for _, set := range sets {
+		botIDs = append(botIDs, set.BotID)
+	}
+	bots, err := s.userRepo.GetUsersByIDs(ctx, botIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load bots: %w", err)
+	}
+
+	var commands []SlashCommand
+	for _, set := range sets {
+		bot, ok := bots[set.BotID]
+		if !ok || !bot.IsBot {
+			continue
+		}
+		for _, cmd := range set.Commands {
+			commands = append(commands, SlashCommand{
+				Name:        cmd.Command,
+				Description: cmd.Description,
+				Bot:         bot.Username,
+				Handler:     s.commandHandler(bot),
+			})
+		}
+	}
+	return commands, nil
+}
+
+func (s *BotService) commandHandler(bot *repository.User) CommandHandler {
+	return func(ctx context.Context, call CommandCall) (*CommandResult, error) {
+		err := s.queueUpdate(ctx, bot, models.BotUpdate{
+			Type: models.BotUpdateCommand,
+			Command: &models.BotCommandUpdate{
+				ChatID:   call.ChatID,
+				UserID:   call.UserID,
+				Username: call.Username,
+				Command:  call.Command,
+				Args:     call.Args,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to pass /%s to @%s: %w", call.Command, bot.Username, err)
+		}
+		return nil, nil
+	}
+}
+
+// queueUpdate stores an update for the bot and hands it to a waiting getUpdates
+// call or the bot's webhook.
+func (s *BotService) queueUpdate(ctx context.Context, bot *repository.User, update models.BotUpdate) error {
+	update.BotID = bot.ID
+	if _, err := s.botRepo.AddBotUpdate(ctx, update); err != nil {
+		return err
+	}
+	s.wake(bot.ID)
+	if bot.BotWebhookActive {
+		go s.deliverWebhook(context.Background(), *bot)
+	}
+	return nil
+}
+
+// Start purges stale updates and retries webhook deliveries every interval until
+// ctx is cancelled.
+func (s *BotService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if n, err := s.botRepo.DeleteOldBotUpdates(ctx, time.Now().Add(-s.options.UpdateRetention)); err != nil {
+			log.Printf("Failed to delete old bot updates: %v", err)
+		} else if n > 0 {
+			log.Printf("🤖 Deleted %d undelivered bot updates", n)
+		}
+
+		bots, err := s.botRepo.GetWebhookBots(ctx)
+		if err != nil {
+			log.Printf("Failed to get webhook bots: %v", err)
+			continue
+		}
+		for _, bot := range bots {
+			s.deliverWebhook(ctx, bot)
+		}
+	}
+}
+
+// deliverWebhook posts the bot's pending updates in order and deletes each one
+// the webhook accepts. It stops at the first failure; Start retries later. Only
+// one delivery per bot runs on a replica at a time.
+func (s *BotService) deliverWebhook(ctx context.Context, bot repository.User) {
+	s.mu.Lock()
+	if s.delivering[bot.ID] {
+		s.mu.Unlock()
+		return
+	}
+	s.delivering[bot.ID] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.delivering, bot.ID)
+		s.mu.Unlock()
+	}()
+
+	for {
+		updates, err := s.botRepo.GetBotUpdates(ctx, bot.ID, 0, maxBotUpdatesPerRequest)
+		if err != nil {
+			log.Printf("Failed to get updates for bot %s: %v", bot.ID, err)
+			return
+		}
+		if len(updates) == 0 {
+			return
+		}
+
+		for _, update := range updates {
+			if err := s.postUpdate(ctx, bot, update); err != nil {
+				log.Printf("Failed to deliver update %d to bot %s: %v", update.UpdateID, bot.ID, err)
+				return
+			}
+			if err := s.botRepo.DeleteBotUpdate(ctx, bot.ID, update.UpdateID); err != nil {
+				log.Printf("Failed to delete delivered update %d of bot %s: %v", update.UpdateID, bot.ID, err)
+				return
+			}
+		}
+	}
+}
+
+func (s *BotService) postUpdate(ctx context.Context, bot repository.User, update models.BotUpdate) error {
+	body, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, bot.BotWebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if bot.BotWebhookSecret != "" {
+		req.Header.Set("X-Flare-Bot-Secret-Token", bot.BotWebhookSecret)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// waitForUpdate returns a channel that is closed when an update for the bot is
+// stored on this replica.
+func (s *BotService) waitForUpdate(botID string) <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch, ok := s.waiters[botID]
+	if !ok {
+		ch = make(chan struct{})
+		s.waiters[botID] = ch
+	}
+	return ch
+}
+
+func (s *BotService) wake(botID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ch, ok := s.waiters[botID]; ok {
+		close(ch)
+		delete(s.waiters, botID)
+	}
+}
+
+func botView(bot *repository.User) models.Bot {
+	return models.Bot{
+		ID:          bot.ID,
+		Username:    bot.Username,
+		DisplayName: bot.DisplayName,
+		OwnerID:     bot.BotOwnerID,
+		WebhookURL:  bot.BotWebhookURL,
+		CreatedAt:   bot.CreatedAt,
+	}
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,11 @@
+package service
+
+// Broadcaster delivers real-time events to connected clients. It is implemented by
+// the WebSocket hub and lets background workers push events without importing the
+// handler package.
+type Broadcaster interface {
+	BroadcastMessage(chatID string, messageType string, data interface{})
+	BroadcastMessageExcept(chatID string, messageType string, data interface{}, skipUserIDs map[string]bool)
+	SendToUser(userID string, messageType string, data interface{})
+	DisconnectUser(userID string)
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"Flare-server/internal/models"
+)
+
+// ChatEventHook receives chat events after they have been stored. Hooks run
+// synchronously in the order they were added, so they should only record the
+// event and do slow work elsewhere.
+type ChatEventHook interface {
+	HandleChatEvent(ctx context.Context, event models.ChatEvent)
+}
+
+// AddEventHook registers a hook for events in all chats.
+func (s *ChatService) AddEventHook(hook ChatEventHook) {
+	s.hooks = append(s.hooks, hook)
+}
+
+func (s *ChatService) emit(ctx context.Context, event models.ChatEvent) {
+	event.Timestamp = time.Now()
+	for _, hook := range s.hooks {
+		hook.HandleChatEvent(ctx, event)
+	}
+}
@@ -2,23 +2,67 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"regexp"
 	"strings"
+	"time"
 
 	"Flare-server/internal/models"
+	"Flare-server/internal/ratelimit"
 	"Flare-server/internal/repository"
 )
 
+const (
+	minMessageTTL = 5
+	maxMessageTTL = 365 * 24 * 60 * 60
+	maxSlowMode   = 6 * 60 * 60
+
+	maxMuteDuration = 365 * 24 * 60 * 60
+
+	maxChatBlockedWords    = 200
+	maxBlockedWordLength   = 64
+	maxChatAllowedDomains  = 50
+	maxAllowedDomainLength = 253
+
+	// maxMessagePagesRead bounds the pages GetChatMessages reads while skipping
+	// messages hidden by blocks.
+	maxMessagePagesRead = 5
+)
+
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]*[a-z0-9])?\.)+[a-z]{2,}$`)
+
 type ChatService struct {
-	chatRepo *repository.ChatRepo
-	userRepo *repository.UserRepo
+	chatRepo    *repository.ChatRepo
+	userRepo    *repository.UserRepo
+	contactRepo *repository.ContactRepo
+	blockRepo   *repository.BlockRepo
+	filters     *FilterChain
+	maxLength   int
+	maxRepeated int
+	rateStore   ratelimit.Store
+	hooks       []ChatEventHook
+	broadcaster Broadcaster
+
+	commands       map[string]SlashCommand
+	commandSources []ChatCommandSource
 }
 
-func NewChatService(chatRepo *repository.ChatRepo, userRepo *repository.UserRepo) *ChatService {
-	return &ChatService{
-		chatRepo: chatRepo,
-		userRepo: userRepo,
+func NewChatService(chatRepo *repository.ChatRepo, userRepo *repository.UserRepo, contactRepo *repository.ContactRepo, blockRepo *repository.BlockRepo, filterOptions ServerFilterOptions, rateStore ratelimit.Store) *ChatService {
+	s := &ChatService{
+		chatRepo:    chatRepo,
+		userRepo:    userRepo,
+		contactRepo: contactRepo,
+		blockRepo:   blockRepo,
+		filters:     NewDefaultFilterChain(filterOptions),
+		maxLength:   filterOptions.MaxLength,
+		maxRepeated: filterOptions.MaxRepeatedChars,
+		rateStore:   rateStore,
+		commands:    make(map[string]SlashCommand),
 	}
+	s.registerBuiltinCommands()
+	return s
 }
 
 func (s *ChatService) CreateChat(ctx context.Context, req models.CreateChatRequest, creatorID, creatorUsername string) (*models.Chat, error) {
@@ -37,10 +81,24 @@ func (s *ChatService) CreateChat(ctx context.Context, req models.CreateChatReque
 			return nil, fmt.Errorf("user %s not found", otherUsername)
 		}
 
+		if err := s.checkNotBlocked(ctx, creatorID, otherUser.ID); err != nil {
+			return nil, err
+		}
+
 		existingChat, err := s.chatRepo.FindPrivateChat(ctx, creatorID, otherUser.ID)
 		if err == nil {
 			return existingChat, nil
 		}
+
+		if otherUser.ContactsOnlyPrivateChats && otherUser.ID != creatorID {
+			isContact, err := s.contactRepo.IsContact(ctx, otherUser.ID, creatorID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check contacts: %w", err)
+			}
+			if !isContact {
+				return nil, fmt.Errorf("access denied: %s only accepts private chats from contacts", otherUser.Username)
+			}
+		}
 	}
 
 	chat := models.Chat{
@@ -71,6 +129,12 @@ func (s *ChatService) CreateChat(ctx context.Context, req models.CreateChatReque
 	if err := s.chatRepo.AddChatMember(ctx, creatorMember); err != nil {
 		return nil, fmt.Errorf("failed to add creator to chat: %w", err)
 	}
+	s.emit(ctx, models.ChatEvent{
+		Type:    models.ChatEventMemberJoined,
+		ChatID:  createdChat.ID,
+		ActorID: creatorID,
+		Member:  &creatorMember,
+	})
 
 	for _, username := range req.Members {
 		user, err := s.userRepo.GetUserByUsername(ctx, username)
@@ -83,11 +147,18 @@ func (s *ChatService) CreateChat(ctx context.Context, req models.CreateChatReque
 			UserID:   user.ID,
 			Username: user.Username,
 			Role:     models.RoleMember,
+			Bot:      user.IsBot,
 		}
 
 		if err := s.chatRepo.AddChatMember(ctx, member); err != nil {
 			continue
 		}
+		s.emit(ctx, models.ChatEvent{
+			Type:    models.ChatEventMemberJoined,
+			ChatID:  createdChat.ID,
+			ActorID: creatorID,
+			Member:  &member,
+		})
 
 		if req.Type == models.ChatTypeGroup {
 			systemMsg := models.Message{
@@ -105,7 +176,20 @@ func (s *ChatService) CreateChat(ctx context.Context, req models.CreateChatReque
 }
 
 func (s *ChatService) GetUserChats(ctx context.Context, userID string) ([]models.Chat, error) {
-	return s.chatRepo.GetUserChats(ctx, userID)
+	chats, err := s.chatRepo.GetUserChats(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastMessages []*models.Message
+	for i := range chats {
+		if chats[i].LastMessage != nil {
+			lastMessages = append(lastMessages, chats[i].LastMessage)
+		}
+	}
+	s.resolveSenderNames(ctx, lastMessages)
+
+	return chats, nil
 }
 
 func (s *ChatService) GetChatInfo(ctx context.Context, chatID, userID string) (*models.ChatInfo, error) {
@@ -127,13 +211,77 @@ func (s *ChatService) GetChatInfo(ctx context.Context, chatID, userID string) (*
 		return nil, fmt.Errorf("failed to get chat members: %w", err)
 	}
 
+	s.attachProfiles(ctx, members)
+
 	return &models.ChatInfo{
 		Chat:    *chat,
 		Members: members,
 	}, nil
 }
 
+// SendMessage posts a user's message. Text starting with "/" runs a slash command
+// instead; commands that only reply to the sender return a nil message. A leading
+// "//" posts the rest as text starting with "/".
 func (s *ChatService) SendMessage(ctx context.Context, chatID, senderID, username string, req models.SendMessageRequest) (*models.Message, error) {
+	text := strings.TrimSpace(req.Text)
+	if strings.HasPrefix(text, "//") {
+		req.Text = text[1:]
+	} else if name, bot, args, ok := parseCommand(text); ok {
+		return s.runCommand(ctx, "", chatID, senderID, username, name, bot, args)
+	}
+	return s.sendMessage(ctx, "", chatID, senderID, username, false, req)
+}
+
+// SendScheduledMessage posts a scheduled message like SendMessage, but stores it
+// under an ID derived from the scheduled message. Dispatching the same scheduled
+// message again therefore never posts it twice: posted is false and the message
+// stored by the earlier dispatch is returned.
+func (s *ChatService) SendScheduledMessage(ctx context.Context, scheduled *models.ScheduledMessage) (message *models.Message, posted bool, err error) {
+	messageID := "scheduled_" + scheduled.ID
+
+	existing, err := s.chatRepo.FindMessage(ctx, messageID)
+	if err != nil {
+		return nil, false, err
+	}
+	if existing != nil {
+		return existing, false, nil
+	}
+
+	req := models.SendMessageRequest{Text: scheduled.Text, ReplyTo: scheduled.ReplyTo}
+	text := strings.TrimSpace(req.Text)
+	name, bot, args, isCommand := parseCommand(text)
+	switch {
+	case strings.HasPrefix(text, "//"):
+		req.Text = text[1:]
+		message, err = s.sendMessage(ctx, messageID, scheduled.ChatID, scheduled.SenderID, scheduled.Username, false, req)
+	case isCommand:
+		message, err = s.runCommand(ctx, messageID, scheduled.ChatID, scheduled.SenderID, scheduled.Username, name, bot, args)
+	default:
+		message, err = s.sendMessage(ctx, messageID, scheduled.ChatID, scheduled.SenderID, scheduled.Username, false, req)
+	}
+	if errors.Is(err, repository.ErrMessageExists) {
+		// Another dispatcher stored it between the check above and now.
+		existing, err := s.chatRepo.FindMessage(ctx, messageID)
+		return existing, false, err
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return message, message != nil, nil
+}
+
+// SendBotMessage posts as a bot account. Bots are subject to the same membership,
+// block, filter and slow mode checks as people; their messages are flagged.
+func (s *ChatService) SendBotMessage(ctx context.Context, chatID string, bot *repository.User, req models.SendMessageRequest) (*models.Message, error) {
+	if !bot.IsBot {
+		return nil, fmt.Errorf("user %s is not a bot", bot.Username)
+	}
+	return s.sendMessage(ctx, "", chatID, bot.ID, bot.Username, true, req)
+}
+
+// sendMessage posts a member's message. messageID is empty unless the caller
+// needs the message stored under a fixed ID.
+func (s *ChatService) sendMessage(ctx context.Context, messageID, chatID, senderID, username string, bot bool, req models.SendMessageRequest) (*models.Message, error) {
 	isMember, err := s.chatRepo.IsUserInChat(ctx, chatID, senderID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check chat membership: %w", err)
@@ -142,27 +290,107 @@ func (s *ChatService) SendMessage(ctx context.Context, chatID, senderID, usernam
 		return nil, fmt.Errorf("access denied: user is not a member of this chat")
 	}
 
-	if strings.TrimSpace(req.Text) == "" {
-		return nil, fmt.Errorf("message text cannot be empty")
-	}
-
-	message := models.Message{
+	return s.postMessage(ctx, models.Message{
+		ID:       messageID,
 		ChatID:   chatID,
 		SenderID: senderID,
 		Username: username,
-		Text:     strings.TrimSpace(req.Text),
-		Type:     models.MessageTypeText,
+		Bot:      bot,
+		Text:     req.Text,
 		ReplyTo:  req.ReplyTo,
+	})
+}
+
+// SendIncomingWebhookMessage posts a message on behalf of an incoming webhook.
+// The webhook is not a chat member, but blocks, filters and slow mode apply to
+// it like to anyone else. displayName and avatarURL override the webhook's own.
+func (s *ChatService) SendIncomingWebhookMessage(ctx context.Context, hook *models.IncomingWebhook, displayName, avatarURL, text string) (*models.Message, error) {
+	if displayName == "" {
+		displayName = hook.Name
+	}
+	if avatarURL == "" {
+		avatarURL = hook.AvatarURL
+	}
+
+	return s.postMessage(ctx, models.Message{
+		ChatID:    hook.ChatID,
+		SenderID:  hook.ID,
+		Username:  displayName,
+		WebhookID: hook.ID,
+		AvatarURL: avatarURL,
+		Text:      text,
+	})
+}
+
+// postMessage runs the checks shared by every kind of sender and stores the message.
+func (s *ChatService) postMessage(ctx context.Context, message models.Message) (*models.Message, error) {
+	if strings.TrimSpace(message.Text) == "" {
+		return nil, fmt.Errorf("message text cannot be empty")
+	}
+
+	chat, err := s.chatRepo.GetChatByID(ctx, message.ChatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat: %w", err)
 	}
 
+	if err := s.checkCanPost(ctx, chat, message.SenderID); err != nil {
+		return nil, err
+	}
+
+	text, err := s.filters.Apply(ctx, chat, message.SenderID, strings.TrimSpace(message.Text))
+	if err != nil {
+		return nil, err
+	}
+	message.Text = strings.TrimSpace(text)
+	if message.Text == "" {
+		return nil, fmt.Errorf("message text cannot be empty")
+	}
+	if message.Poll != nil {
+		if err := s.filterPoll(ctx, chat, message.SenderID, message.Text, message.Poll); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.checkSlowMode(ctx, chat, message.SenderID); err != nil {
+		return nil, err
+	}
+
+	if message.Type == "" {
+		message.Type = models.MessageTypeText
+	}
+	applyMessageTTL(chat, &message)
+
 	savedMessage, err := s.chatRepo.SaveMessage(ctx, message)
 	if err != nil {
 		return nil, fmt.Errorf("failed to save message: %w", err)
 	}
 
+	s.emit(ctx, models.ChatEvent{
+		Type:    models.ChatEventMessageCreated,
+		ChatID:  message.ChatID,
+		ActorID: message.SenderID,
+		Message: savedMessage,
+	})
 	return savedMessage, nil
 }
 
+// filterPoll runs the content filters over the poll options and stores the
+// already filtered question.
+func (s *ChatService) filterPoll(ctx context.Context, chat *models.Chat, senderID, question string, poll *models.Poll) error {
+	poll.Question = question
+	for i := range poll.Options {
+		text, err := s.filters.Apply(ctx, chat, senderID, poll.Options[i].Text)
+		if err != nil {
+			return err
+		}
+		poll.Options[i].Text = strings.TrimSpace(text)
+		if poll.Options[i].Text == "" {
+			return fmt.Errorf("poll options cannot be empty")
+		}
+	}
+	return nil
+}
+
 func (s *ChatService) GetChatMessages(ctx context.Context, chatID, userID string, limit int, lastMessageID string) (*models.ChatMessagesResponse, error) {
 	isMember, err := s.chatRepo.IsUserInChat(ctx, chatID, userID)
 	if err != nil {
@@ -176,20 +404,64 @@ func (s *ChatService) GetChatMessages(ctx context.Context, chatID, userID string
 		limit = 50
 	}
 
-	messages, err := s.chatRepo.GetChatMessages(ctx, chatID, limit+1, lastMessageID)
+	hidden, err := s.hiddenSenders(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	page, err := readVisibleMessages(limit, lastMessageID, hidden, func(cursor string) ([]models.Message, string, error) {
+		return s.chatRepo.GetChatMessages(ctx, chatID, limit+1, cursor)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get messages: %w", err)
 	}
 
-	hasMore := len(messages) > limit
-	if hasMore {
-		messages = messages[:limit]
+	refs := make([]*models.Message, len(page.Messages))
+	for i := range page.Messages {
+		refs[i] = &page.Messages[i]
 	}
+	s.resolveSenderNames(ctx, refs)
 
-	return &models.ChatMessagesResponse{
-		Messages: messages,
-		HasMore:  hasMore,
-	}, nil
+	return page, nil
+}
+
+// readVisibleMessages collects up to limit messages, oldest first, reading older
+// pages with fetch until enough of them are not hidden. fetch reads limit+1
+// messages before the cursor the way ChatRepo.GetChatMessages does.
+func readVisibleMessages(limit int, cursor string, hidden map[string]bool, fetch func(cursor string) ([]models.Message, string, error)) (*models.ChatMessagesResponse, error) {
+	messages := []models.Message{}
+	for pages := 1; ; pages++ {
+		batch, resumeAfter, err := fetch(cursor)
+		if err != nil {
+			return nil, err
+		}
+		// A scan that stopped at expired messages has more to read even if the
+		// batch is short.
+		more := len(batch) > limit || resumeAfter != ""
+		next := resumeAfter
+		if next == "" && len(batch) > 0 {
+			next = batch[0].ID
+		}
+
+		visible := make([]models.Message, 0, len(batch))
+		for _, message := range batch {
+			if !hidden[message.SenderID] {
+				visible = append(visible, message)
+			}
+		}
+		messages = append(visible, messages...)
+
+		if len(messages) > limit {
+			messages = messages[len(messages)-limit:]
+			return &models.ChatMessagesResponse{Messages: messages, HasMore: true, NextMessageID: messages[0].ID}, nil
+		}
+		if !more {
+			return &models.ChatMessagesResponse{Messages: messages}, nil
+		}
+		if pages == maxMessagePagesRead {
+			return &models.ChatMessagesResponse{Messages: messages, HasMore: true, NextMessageID: next}, nil
+		}
+		cursor = next
+	}
 }
 
 func (s *ChatService) AddMemberToChat(ctx context.Context, chatID, adminID string, req models.AddMemberRequest) error {
@@ -228,6 +500,7 @@ func (s *ChatService) AddMemberToChat(ctx context.Context, chatID, adminID strin
 		UserID:   user.ID,
 		Username: user.Username,
 		Role:     models.RoleMember,
+		Bot:      user.IsBot,
 	}
 
 	if err := s.chatRepo.AddChatMember(ctx, member); err != nil {
@@ -243,6 +516,12 @@ func (s *ChatService) AddMemberToChat(ctx context.Context, chatID, adminID strin
 	}
 	s.chatRepo.SaveMessage(ctx, systemMsg)
 
+	s.emit(ctx, models.ChatEvent{
+		Type:    models.ChatEventMemberJoined,
+		ChatID:  chatID,
+		ActorID: adminID,
+		Member:  &member,
+	})
 	return nil
 }
 
@@ -273,15 +552,15 @@ func (s *ChatService) RemoveMemberFromChat(ctx context.Context, chatID, adminID,
 		return fmt.Errorf("failed to get chat members: %w", err)
 	}
 
-	var targetUsername string
-	for _, member := range members {
-		if member.UserID == targetUserID {
-			targetUsername = member.Username
+	var target *models.ChatMember
+	for i := range members {
+		if members[i].UserID == targetUserID {
+			target = &members[i]
 			break
 		}
 	}
 
-	if targetUsername == "" {
+	if target == nil {
 		return fmt.Errorf("user is not a member of this chat")
 	}
 
@@ -293,11 +572,17 @@ func (s *ChatService) RemoveMemberFromChat(ctx context.Context, chatID, adminID,
 		ChatID:   chatID,
 		SenderID: "system",
 		Username: "System",
-		Text:     fmt.Sprintf("%s удален из чата", targetUsername),
+		Text:     fmt.Sprintf("%s удален из чата", target.Username),
 		Type:     models.MessageTypeSystem,
 	}
 	s.chatRepo.SaveMessage(ctx, systemMsg)
 
+	s.emit(ctx, models.ChatEvent{
+		Type:    models.ChatEventMemberLeft,
+		ChatID:  chatID,
+		ActorID: adminID,
+		Member:  target,
+	})
 	return nil
 }
 
@@ -316,15 +601,15 @@ func (s *ChatService) LeaveChat(ctx context.Context, chatID, userID string) erro
 		return fmt.Errorf("failed to get chat members: %w", err)
 	}
 
-	var username string
-	for _, member := range members {
-		if member.UserID == userID {
-			username = member.Username
+	var leaving *models.ChatMember
+	for i := range members {
+		if members[i].UserID == userID {
+			leaving = &members[i]
 			break
 		}
 	}
 
-	if username == "" {
+	if leaving == nil {
 		return fmt.Errorf("user is not a member of this chat")
 	}
 
@@ -337,12 +622,18 @@ func (s *ChatService) LeaveChat(ctx context.Context, chatID, userID string) erro
 			ChatID:   chatID,
 			SenderID: "system",
 			Username: "System",
-			Text:     fmt.Sprintf("%s покинул чат", username),
+			Text:     fmt.Sprintf("%s покинул чат", leaving.Username),
 			Type:     models.MessageTypeSystem,
 		}
 		s.chatRepo.SaveMessage(ctx, systemMsg)
 	}
 
+	s.emit(ctx, models.ChatEvent{
+		Type:    models.ChatEventMemberLeft,
+		ChatID:  chatID,
+		ActorID: userID,
+		Member:  leaving,
+	})
 	return nil
 }
 
@@ -359,6 +650,8 @@ func (s *ChatService) UpdateChat(ctx context.Context, chatID, userID string, upd
 		"name":        true,
 		"description": true,
 		"avatar":      true,
+		"messageTTL":  true,
+		"slowMode":    true,
 	}
 
 	filteredUpdates := make(map[string]interface{})
@@ -368,11 +661,175 @@ func (s *ChatService) UpdateChat(ctx context.Context, chatID, userID string, upd
 		}
 	}
 
+	if value, ok := filteredUpdates["messageTTL"]; ok {
+		ttl, err := parseMessageTTL(value)
+		if err != nil {
+			return err
+		}
+		filteredUpdates["messageTTL"] = ttl
+	}
+
+	if value, ok := filteredUpdates["slowMode"]; ok {
+		seconds, ok := value.(float64)
+		if !ok || seconds != float64(int64(seconds)) || seconds < 0 || seconds > maxSlowMode {
+			return fmt.Errorf("slowMode must be a whole number of seconds between 0 and %d", maxSlowMode)
+		}
+		filteredUpdates["slowMode"] = int64(seconds)
+	}
+
 	if len(filteredUpdates) == 0 {
 		return fmt.Errorf("no valid fields to update")
 	}
 
-	return s.chatRepo.UpdateChat(ctx, chatID, filteredUpdates)
+	if err := s.chatRepo.UpdateChat(ctx, chatID, filteredUpdates); err != nil {
+		return err
+	}
+
+	s.emit(ctx, models.ChatEvent{
+		Type:    models.ChatEventChatUpdated,
+		ChatID:  chatID,
+		ActorID: userID,
+		Changes: filteredUpdates,
+	})
+	return nil
+}
+
+// GetChatFilters returns the chat's content filter settings to any member.
+func (s *ChatService) GetChatFilters(ctx context.Context, chatID, userID string) (*models.ChatFilterSettings, error) {
+	isMember, err := s.chatRepo.IsUserInChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check chat membership: %w", err)
+	}
+	if !isMember {
+		return nil, fmt.Errorf("access denied: user is not a member of this chat")
+	}
+
+	chat, err := s.chatRepo.GetChatByID(ctx, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat: %w", err)
+	}
+
+	settings := models.ChatFilterSettings{BlockedWords: []string{}, AllowedDomains: []string{}}
+	if chat.Filters != nil {
+		settings = *chat.Filters
+	}
+	return &settings, nil
+}
+
+func (s *ChatService) UpdateChatFilters(ctx context.Context, chatID, userID string, settings models.ChatFilterSettings) (*models.ChatFilterSettings, error) {
+	isAdmin, err := s.isUserAdmin(ctx, chatID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check admin status: %w", err)
+	}
+	if !isAdmin {
+		return nil, fmt.Errorf("access denied: only admins can update chat filters")
+	}
+
+	if err := s.validateChatFilters(&settings); err != nil {
+		return nil, err
+	}
+
+	if err := s.chatRepo.UpdateChat(ctx, chatID, map[string]interface{}{"filters": settings}); err != nil {
+		return nil, fmt.Errorf("failed to update chat filters: %w", err)
+	}
+	return &settings, nil
+}
+
+func (s *ChatService) validateChatFilters(settings *models.ChatFilterSettings) error {
+	words, err := normalizeFilterList(settings.BlockedWords, maxChatBlockedWords, maxBlockedWordLength, "blocked words")
+	if err != nil {
+		return err
+	}
+	for _, word := range words {
+		for _, r := range word {
+			if !isWordRune(r) {
+				return fmt.Errorf("blocked word %q must be a single word", word)
+			}
+		}
+	}
+	settings.BlockedWords = words
+
+	domains, err := normalizeFilterList(settings.AllowedDomains, maxChatAllowedDomains, maxAllowedDomainLength, "allowed domains")
+	if err != nil {
+		return err
+	}
+	for _, domain := range domains {
+		if !domainPattern.MatchString(domain) {
+			return fmt.Errorf("invalid domain %q", domain)
+		}
+	}
+	settings.AllowedDomains = domains
+
+	if settings.MaxLength < 0 || (s.maxLength > 0 && settings.MaxLength > s.maxLength) {
+		return fmt.Errorf("maxLength must be between 0 and %d", s.maxLength)
+	}
+	if settings.MaxRepeatedChars < 0 || settings.MaxRepeatedChars == 1 {
+		return fmt.Errorf("maxRepeatedChars must be 0 or at least 2")
+	}
+	if s.maxRepeated > 0 && settings.MaxRepeatedChars > s.maxRepeated {
+		return fmt.Errorf("maxRepeatedChars cannot be more than %d", s.maxRepeated)
+	}
+	return nil
+}
+
+func normalizeFilterList(values []string, maxItems, maxItemLength int, name string) ([]string, error) {
+	if len(values) > maxItems {
+		return nil, fmt.Errorf("%s cannot contain more than %d entries", name, maxItems)
+	}
+
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" || seen[value] {
+			continue
+		}
+		if len([]rune(value)) > maxItemLength {
+			return nil, fmt.Errorf("%s entries cannot be longer than %d characters", name, maxItemLength)
+		}
+		seen[value] = true
+		normalized = append(normalized, value)
+	}
+	return normalized, nil
+}
+
+// MuteChat mutes or unmutes notifications of the chat for a member and returns
+// the end of the mute, which is nil for an open-ended mute.
+func (s *ChatService) MuteChat(ctx context.Context, chatID, userID string, req models.MuteChatRequest) (*time.Time, error) {
+	isMember, err := s.chatRepo.IsUserInChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check chat membership: %w", err)
+	}
+	if !isMember {
+		return nil, fmt.Errorf("access denied: user is not a member of this chat")
+	}
+
+	if req.Duration < 0 || req.Duration > maxMuteDuration {
+		return nil, fmt.Errorf("duration must be between 0 and %d seconds", maxMuteDuration)
+	}
+
+	var until *time.Time
+	if req.Muted && req.Duration > 0 {
+		t := time.Now().Add(time.Duration(req.Duration) * time.Second)
+		until = &t
+	}
+	if err := s.chatRepo.SetMemberMuted(ctx, chatID, userID, req.Muted, until); err != nil {
+		return nil, fmt.Errorf("failed to update chat notifications: %w", err)
+	}
+	return until, nil
+}
+
+// MarkChatRead records that the member has read everything in the chat so far.
+// Email digests only include messages newer than this.
+func (s *ChatService) MarkChatRead(ctx context.Context, chatID, userID string) (time.Time, error) {
+	now := time.Now()
+	if err := s.chatRepo.MarkChatRead(ctx, chatID, userID, now); err != nil {
+		if errors.Is(err, repository.ErrChatMemberNotFound) {
+			return now, fmt.Errorf("access denied: user is not a member of this chat")
+		}
+		return now, fmt.Errorf("failed to mark chat as read: %w", err)
+	}
+	return now, nil
 }
 
 func (s *ChatService) DeleteChat(ctx context.Context, chatID, userID string) error {
@@ -403,6 +860,216 @@ func (s *ChatService) isUserAdmin(ctx context.Context, chatID, userID string) (b
 	return false, nil
 }
 
+// attachProfiles replaces the username copied into each membership at join time
+// with the member's current profile.
+func (s *ChatService) attachProfiles(ctx context.Context, members []models.ChatMember) {
+	userIDs := make([]string, 0, len(members))
+	for _, member := range members {
+		userIDs = append(userIDs, member.UserID)
+	}
+
+	users, err := s.userRepo.GetUsersByIDs(ctx, userIDs)
+	if err != nil {
+		log.Printf("Failed to load member profiles: %v", err)
+		return
+	}
+
+	for i := range members {
+		user, ok := users[members[i].UserID]
+		if !ok {
+			continue
+		}
+		profile := user.Profile()
+		members[i].Username = profile.Username
+		members[i].Profile = &profile
+	}
+}
+
+// resolveSenderNames replaces the username stored with each message by the sender's
+// current username. The stored value is only a fallback for deleted accounts, and
+// is kept as is for incoming webhook messages.
+func (s *ChatService) resolveSenderNames(ctx context.Context, messages []*models.Message) {
+	var senderIDs []string
+	for _, message := range messages {
+		if message.Type != models.MessageTypeSystem && message.WebhookID == "" {
+			senderIDs = append(senderIDs, message.SenderID)
+		}
+	}
+	if len(senderIDs) == 0 {
+		return
+	}
+
+	users, err := s.userRepo.GetUsersByIDs(ctx, senderIDs)
+	if err != nil {
+		log.Printf("Failed to resolve sender names: %v", err)
+		return
+	}
+
+	for _, message := range messages {
+		if user, ok := users[message.SenderID]; ok {
+			message.Username = user.Username
+		}
+	}
+}
+
+// checkNotBlocked rejects interaction between two users when either has blocked
+// the other. The error does not reveal which side set the block.
+func (s *ChatService) checkNotBlocked(ctx context.Context, userID, otherID string) error {
+	if userID == otherID {
+		return nil
+	}
+
+	blocked, err := s.blockRepo.IsBlockedEitherWay(ctx, userID, otherID)
+	if err != nil {
+		return fmt.Errorf("failed to check blocks: %w", err)
+	}
+	if blocked {
+		return fmt.Errorf("access denied: you cannot message this user")
+	}
+	return nil
+}
+
+// checkCanPost enforces blocks in private chats. Group chats are unaffected; there
+// the blocker can only hide the blocked user's messages.
+func (s *ChatService) checkCanPost(ctx context.Context, chat *models.Chat, senderID string) error {
+	if chat.Type != models.ChatTypePrivate {
+		return nil
+	}
+
+	members, err := s.chatRepo.GetChatMembers(ctx, chat.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get chat members: %w", err)
+	}
+
+	for _, member := range members {
+		if err := s.checkNotBlocked(ctx, senderID, member.UserID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filterHiddenMessages drops group messages from users the reader blocked with
+// hideMessages enabled.
+func (s *ChatService) filterHiddenMessages(ctx context.Context, chatID, userID string, messages []models.Message) ([]models.Message, error) {
+	hidden, err := s.hiddenSenders(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(hidden) == 0 {
+		return messages, nil
+	}
+
+	visible := messages[:0]
+	for _, message := range messages {
+		if !hidden[message.SenderID] {
+			visible = append(visible, message)
+		}
+	}
+	return visible, nil
+}
+
+// hiddenSenders returns the users whose messages the reader hides in the chat:
+// those blocked with hideMessages enabled, and only in group chats.
+func (s *ChatService) hiddenSenders(ctx context.Context, chatID, userID string) (map[string]bool, error) {
+	blocks, err := s.blockRepo.GetBlocks(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	hidden := make(map[string]bool)
+	for _, block := range blocks {
+		if block.HideMessages {
+			hidden[block.BlockedID] = true
+		}
+	}
+	if len(hidden) == 0 {
+		return nil, nil
+	}
+
+	chat, err := s.chatRepo.GetChatByID(ctx, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chat: %w", err)
+	}
+	if chat.Type != models.ChatTypeGroup {
+		return nil, nil
+	}
+	return hidden, nil
+}
+
+// MessageRecipientsToSkip returns the users that should not receive real-time
+// copies of senderID's messages because they hid them.
+func (s *ChatService) MessageRecipientsToSkip(ctx context.Context, senderID string) map[string]bool {
+	hiders, err := s.blockRepo.GetMessageHiderIDs(ctx, senderID)
+	if err != nil {
+		log.Printf("Failed to get users hiding messages from %s: %v", senderID, err)
+		return nil
+	}
+	return hiders
+}
+
+// PresenceRecipientsToSkip returns the users that must not see userID's presence
+// events, i.e. everyone userID has blocked.
+func (s *ChatService) PresenceRecipientsToSkip(ctx context.Context, userID string) map[string]bool {
+	blocks, err := s.blockRepo.GetBlocks(ctx, userID)
+	if err != nil {
+		log.Printf("Failed to get blocks of %s: %v", userID, err)
+		return nil
+	}
+
+	blocked := make(map[string]bool, len(blocks))
+	for _, block := range blocks {
+		blocked[block.BlockedID] = true
+	}
+	return blocked
+}
+
 func (s *ChatService) IsUserInChat(ctx context.Context, chatID, userID string) (bool, error) {
 	return s.chatRepo.IsUserInChat(ctx, chatID, userID)
 }
+
+// checkSlowMode allows one message per slowMode seconds per member. Admins are
+// exempt.
+func (s *ChatService) checkSlowMode(ctx context.Context, chat *models.Chat, senderID string) error {
+	if chat.SlowMode <= 0 {
+		return nil
+	}
+
+	isAdmin, err := s.isUserAdmin(ctx, chat.ID, senderID)
+	if err != nil {
+		return fmt.Errorf("failed to check admin status: %w", err)
+	}
+	if isAdmin {
+		return nil
+	}
+
+	policy := ratelimit.Policy{Requests: 1, Per: time.Duration(chat.SlowMode) * time.Second}
+	if err := ratelimit.Take(ctx, s.rateStore, "slowmode:"+chat.ID+":"+senderID, policy); err != nil {
+		return fmt.Errorf("slow mode is enabled: %w", err)
+	}
+	return nil
+}
+
+func applyMessageTTL(chat *models.Chat, message *models.Message) {
+	if chat.MessageTTL > 0 {
+		expiresAt := time.Now().Add(time.Duration(chat.MessageTTL) * time.Second)
+		message.ExpiresAt = &expiresAt
+	}
+}
+
+func parseMessageTTL(value interface{}) (int64, error) {
+	seconds, ok := value.(float64)
+	if !ok || seconds != float64(int64(seconds)) {
+		return 0, fmt.Errorf("messageTTL must be a whole number of seconds")
+	}
+
+	ttl := int64(seconds)
+	if ttl == 0 {
+		return 0, nil
+	}
+	if ttl < minMessageTTL || ttl > maxMessageTTL {
+		return 0, fmt.Errorf("messageTTL must be 0 or between %d and %d seconds", minMessageTTL, maxMessageTTL)
+	}
+
+	return ttl, nil
+}
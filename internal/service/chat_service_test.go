@@ -0,0 +1,143 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"Flare-server/internal/models"
+)
+
+// chatHistory stands in for ChatRepo.GetChatMessages over messages stored newest
+// first. Messages in expired are skipped, and a scan stops after maxExpired of them.
+type chatHistory struct {
+	messages   []models.Message
+	expired    map[string]bool
+	maxExpired int
+	fetches    int
+}
+
+func newChatHistory(senders ...string) *chatHistory {
+	h := &chatHistory{expired: make(map[string]bool), maxExpired: 1000}
+	for i := len(senders) - 1; i >= 0; i-- {
+		h.messages = append(h.messages, models.Message{ID: fmt.Sprintf("m%d", i), SenderID: senders[i]})
+	}
+	return h
+}
+
+func (h *chatHistory) fetch(limit int) func(cursor string) ([]models.Message, string, error) {
+	return func(cursor string) ([]models.Message, string, error) {
+		h.fetches++
+		start := 0
+		for i, m := range h.messages {
+			if m.ID == cursor {
+				start = i + 1
+			}
+		}
+
+		var page []models.Message
+		skipped := 0
+		for _, m := range h.messages[start:] {
+			if len(page) == limit {
+				break
+			}
+			if h.expired[m.ID] {
+				if skipped++; skipped == h.maxExpired {
+					return reverseMessages(page), m.ID, nil
+				}
+				continue
+			}
+			page = append(page, m)
+		}
+		return reverseMessages(page), "", nil
+	}
+}
+
+func reverseMessages(messages []models.Message) []models.Message {
+	reversed := make([]models.Message, len(messages))
+	for i, m := range messages {
+		reversed[len(messages)-1-i] = m
+	}
+	return reversed
+}
+
+func messageIDs(messages []models.Message) string {
+	ids := ""
+	for _, m := range messages {
+		ids += m.ID + " "
+	}
+	return ids
+}
+
+func TestReadVisibleMessagesReturnsNewestPage(t *testing.T) {
+	h := newChatHistory("a", "a", "a", "a", "a")
+
+	page, err := readVisibleMessages(3, "", nil, h.fetch(4))
+	if err != nil {
+		t.Fatalf("readVisibleMessages failed: %v", err)
+	}
+	if got := messageIDs(page.Messages); got != "m2 m3 m4 " {
+		t.Errorf("messages = %q, want the newest three", got)
+	}
+	if !page.HasMore || page.NextMessageID != "m2" {
+		t.Errorf("hasMore %v, next %q", page.HasMore, page.NextMessageID)
+	}
+
+	page, _ = readVisibleMessages(3, page.NextMessageID, nil, h.fetch(4))
+	if got := messageIDs(page.Messages); got != "m0 m1 " || page.HasMore {
+		t.Errorf("last page = %q, hasMore %v", got, page.HasMore)
+	}
+}
+
+func TestReadVisibleMessagesSkipsHiddenSenders(t *testing.T) {
+	h := newChatHistory("a", "a", "b", "b", "b", "b", "b", "b", "a", "b")
+
+	page, err := readVisibleMessages(2, "", map[string]bool{"b": true}, h.fetch(3))
+	if err != nil {
+		t.Fatalf("readVisibleMessages failed: %v", err)
+	}
+	if got := messageIDs(page.Messages); got != "m1 m8 " {
+		t.Errorf("messages = %q, want two visible messages", got)
+	}
+	if !page.HasMore || page.NextMessageID != "m1" {
+		t.Errorf("hasMore %v, next %q", page.HasMore, page.NextMessageID)
+	}
+}
+
+func TestReadVisibleMessagesContinuesAfterExpiredRun(t *testing.T) {
+	h := newChatHistory("a", "a", "a", "a", "a", "a")
+	for _, id := range []string{"m2", "m3", "m4"} {
+		h.expired[id] = true
+	}
+	h.maxExpired = 2
+
+	page, err := readVisibleMessages(2, "", nil, h.fetch(3))
+	if err != nil {
+		t.Fatalf("readVisibleMessages failed: %v", err)
+	}
+	if got := messageIDs(page.Messages); got != "m1 m5 " || !page.HasMore || page.NextMessageID != "m1" {
+		t.Errorf("messages = %q, hasMore %v, next %q", got, page.HasMore, page.NextMessageID)
+	}
+}
+
+func TestReadVisibleMessagesBoundsPagesRead(t *testing.T) {
+	senders := make([]string, 100)
+	for i := range senders {
+		senders[i] = "b"
+	}
+	senders[0] = "a"
+	h := newChatHistory(senders...)
+
+	page, err := readVisibleMessages(2, "", map[string]bool{"b": true}, h.fetch(3))
+	if err != nil {
+		t.Fatalf("readVisibleMessages failed: %v", err)
+	}
+	if h.fetches != maxMessagePagesRead {
+		t.Errorf("read %d pages, want %d", h.fetches, maxMessagePagesRead)
+	}
+	if len(page.Messages) != 0 || !page.HasMore || page.NextMessageID != "m85" {
+		t.Errorf("messages = %q, hasMore %v, next %q", messageIDs(page.Messages), page.HasMore, page.NextMessageID)
+	}
+	if page.Messages == nil {
+		t.Error("an empty page should encode as an empty list")
+	}
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/repository"
+)
+
+const maxContactNicknameLength = 64
+
+type ContactService struct {
+	contactRepo *repository.ContactRepo
+	userRepo    *repository.UserRepo
+	blockRepo   *repository.BlockRepo
+	broadcaster Broadcaster
+}
+
+func NewContactService(contactRepo *repository.ContactRepo, userRepo *repository.UserRepo, blockRepo *repository.BlockRepo, broadcaster Broadcaster) *ContactService {
+	return &ContactService{
+		contactRepo: contactRepo,
+		userRepo:    userRepo,
+		blockRepo:   blockRepo,
+		broadcaster: broadcaster,
+	}
+}
+
+// SendFriendRequest creates a request to the given user. If that user already has a
+// pending request to the sender, it is accepted instead of creating a second one.
+func (s *ContactService) SendFriendRequest(ctx context.Context, fromUserID, fromUsername, toUsername string) (*models.FriendRequest, error) {
+	toUser, err := s.userRepo.GetUserByUsername(ctx, strings.TrimSpace(toUsername))
+	if err != nil {
+		return nil, fmt.Errorf("user %s not found", toUsername)
+	}
+	if toUser.ID == fromUserID {
+		return nil, fmt.Errorf("cannot send a friend request to yourself")
+	}
+
+	blocked, err := s.blockRepo.IsBlockedEitherWay(ctx, fromUserID, toUser.ID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, fmt.Errorf("cannot send a friend request to %s", toUser.Username)
+	}
+
+	isContact, err := s.contactRepo.IsContact(ctx, fromUserID, toUser.ID)
+	if err != nil {
+		return nil, err
+	}
+	if isContact {
+		return nil, fmt.Errorf("%s is already in your contacts", toUser.Username)
+	}
+
+	reverse, err := s.contactRepo.GetPendingFriendRequest(ctx, toUser.ID, fromUserID)
+	if err != nil {
+		return nil, err
+	}
+	if reverse != nil {
+		return s.AcceptFriendRequest(ctx, reverse.ID, fromUserID)
+	}
+
+	req, err := s.contactRepo.CreateFriendRequest(ctx, models.FriendRequest{
+		FromUserID:   fromUserID,
+		FromUsername: fromUsername,
+		ToUserID:     toUser.ID,
+		ToUsername:   toUser.Username,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.broadcaster.SendToUser(req.ToUserID, "friend_request_received", req)
+	return req, nil
+}
+
+func (s *ContactService) AcceptFriendRequest(ctx context.Context, requestID, userID string) (*models.FriendRequest, error) {
+	req, err := s.contactRepo.ResolveFriendRequest(ctx, requestID, models.FriendRequestAccepted, requireRecipient(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyBoth(req, "friend_request_accepted")
+	return req, nil
+}
+
+func (s *ContactService) DeclineFriendRequest(ctx context.Context, requestID, userID string) (*models.FriendRequest, error) {
+	req, err := s.contactRepo.ResolveFriendRequest(ctx, requestID, models.FriendRequestDeclined, requireRecipient(userID))
+	if err != nil {
+		return nil, err
+	}
+
+	s.broadcaster.SendToUser(req.FromUserID, "friend_request_declined", req)
+	return req, nil
+}
+
+func (s *ContactService) CancelFriendRequest(ctx context.Context, requestID, userID string) (*models.FriendRequest, error) {
+	req, err := s.contactRepo.ResolveFriendRequest(ctx, requestID, models.FriendRequestCancelled, func(req *models.FriendRequest) error {
+		if req.FromUserID != userID {
+			return fmt.Errorf("friend request not found")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.broadcaster.SendToUser(req.ToUserID, "friend_request_cancelled", req)
+	return req, nil
+}
+
+func (s *ContactService) GetFriendRequests(ctx context.Context, userID string, incoming bool) (*models.FriendRequestListResponse, error) {
+	requests, err := s.contactRepo.GetPendingFriendRequests(ctx, userID, incoming)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.FriendRequestListResponse{Requests: requests}, nil
+}
+
+func (s *ContactService) GetContacts(ctx context.Context, userID string) (*models.ContactListResponse, error) {
+	contacts, err := s.contactRepo.GetContacts(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(contacts))
+	for _, contact := range contacts {
+		ids = append(ids, contact.ContactID)
+	}
+
+	users, err := s.userRepo.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		log.Printf("Failed to load contact profiles for user %s: %v", userID, err)
+	}
+	for i := range contacts {
+		if user, ok := users[contacts[i].ContactID]; ok {
+			profile := user.Profile()
+			contacts[i].Profile = &profile
+		}
+	}
+
+	return &models.ContactListResponse{Contacts: contacts}, nil
+}
+
+func (s *ContactService) UpdateContact(ctx context.Context, userID, contactID string, req models.UpdateContactRequest) error {
+	nickname := strings.TrimSpace(req.Nickname)
+	if len([]rune(nickname)) > maxContactNicknameLength {
+		return fmt.Errorf("nickname cannot be longer than %d characters", maxContactNicknameLength)
+	}
+
+	return s.contactRepo.UpdateContactNickname(ctx, userID, contactID, nickname)
+}
+
+func (s *ContactService) RemoveContact(ctx context.Context, userID, contactID string) error {
+	isContact, err := s.contactRepo.IsContact(ctx, userID, contactID)
+	if err != nil {
+		return err
+	}
+	if !isContact {
+		return fmt.Errorf("contact not found")
+	}
+
+	if err := s.contactRepo.DeleteContact(ctx, userID, contactID); err != nil {
+		return fmt.Errorf("failed to remove contact: %w", err)
+	}
+
+	s.broadcaster.SendToUser(contactID, "contact_removed", map[string]string{"userId": userID})
+	return nil
+}
+
+func (s *ContactService) notifyBoth(req *models.FriendRequest, eventType string) {
+	s.broadcaster.SendToUser(req.FromUserID, eventType, req)
+	s.broadcaster.SendToUser(req.ToUserID, eventType, req)
+}
+
+func requireRecipient(userID string) func(req *models.FriendRequest) error {
+	return func(req *models.FriendRequest) error {
+		if req.ToUserID != userID {
+			return fmt.Errorf("friend request not found")
+		}
+		return nil
+	}
+}
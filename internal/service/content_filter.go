@@ -0,0 +1,300 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"Flare-server/internal/models"
+)
+
+var ErrMessageRejected = errors.New("message rejected")
+
+type FilterVerdict int
+
+const (
+	FilterAllow FilterVerdict = iota
+	FilterReject
+	FilterRewrite
+)
+
+type FilterResult struct {
+	Verdict FilterVerdict
+	Text    string // the rewritten text when Verdict is FilterRewrite
+	Reason  string // shown to the sender when Verdict is FilterReject
+}
+
+// FilterInput is what every filter sees: the current text plus the chat it is
+// being sent to and that chat's filter settings.
+type FilterInput struct {
+	ChatID   string
+	SenderID string
+	Text     string
+	Settings models.ChatFilterSettings
+}
+
+// MessageFilter inspects an outgoing message. Filters run in order and each sees
+// the text as rewritten by the filters before it.
+type MessageFilter interface {
+	Filter(ctx context.Context, input FilterInput) FilterResult
+}
+
+type FilterChain struct {
+	filters []MessageFilter
+}
+
+func NewFilterChain(filters ...MessageFilter) *FilterChain {
+	return &FilterChain{filters: filters}
+}
+
+// Apply runs the chain and returns the final text, or an error wrapping
+// ErrMessageRejected with the first rejection reason.
+func (c *FilterChain) Apply(ctx context.Context, chat *models.Chat, senderID, text string) (string, error) {
+	input := FilterInput{
+		ChatID:   chat.ID,
+		SenderID: senderID,
+		Text:     text,
+	}
+	if chat.Filters != nil {
+		input.Settings = *chat.Filters
+	}
+
+	for _, filter := range c.filters {
+		result := filter.Filter(ctx, input)
+		switch result.Verdict {
+		case FilterReject:
+			return "", fmt.Errorf("%w: %s", ErrMessageRejected, result.Reason)
+		case FilterRewrite:
+			input.Text = result.Text
+		}
+	}
+
+	return input.Text, nil
+}
+
+// ServerFilterOptions are the server-wide filter defaults.
+type ServerFilterOptions struct {
+	BlockedWords       []string
+	BlockLinks         bool
+	AllowedLinkDomains []string
+	MaxLength          int
+	MaxRepeatedChars   int
+}
+
+// NewDefaultFilterChain builds the built-in filters in the order they should run.
+func NewDefaultFilterChain(opts ServerFilterOptions) *FilterChain {
+	return NewFilterChain(
+		InvisibleCharFilter{},
+		MaxLengthFilter{MaxLength: opts.MaxLength},
+		FloodFilter{MaxRepeated: opts.MaxRepeatedChars},
+		NewWordBlocklistFilter(opts.BlockedWords),
+		LinkFilter{BlockLinks: opts.BlockLinks, AllowedDomains: opts.AllowedLinkDomains},
+	)
+}
+
+// InvisibleCharFilter strips zero-width and bidirectional control characters that
+// can hide or disguise text.
+type InvisibleCharFilter struct{}
+
+func (InvisibleCharFilter) Filter(ctx context.Context, input FilterInput) FilterResult {
+	cleaned := strings.Map(func(r rune) rune {
+		if isInvisibleRune(r) {
+			return -1
+		}
+		return r
+	}, input.Text)
+
+	if cleaned == input.Text {
+		return FilterResult{Verdict: FilterAllow}
+	}
+	return FilterResult{Verdict: FilterRewrite, Text: cleaned}
+}
+
+func isInvisibleRune(r rune) bool {
+	switch {
+	case r == '\u00ad', r == '\u180e', r == '\ufeff':
+		return true
+	case r >= '\u200b' && r <= '\u200c', r >= '\u200e' && r <= '\u200f':
+		// U+200D (zero-width joiner) is kept because emoji sequences need it.
+		return true
+	case r >= '\u202a' && r <= '\u202e':
+		return true
+	case r >= '\u2060' && r <= '\u2069':
+		return true
+	}
+	return false
+}
+
+// MaxLengthFilter rejects messages longer than the server limit or the chat's own,
+// lower limit.
+type MaxLengthFilter struct {
+	MaxLength int
+}
+
+func (f MaxLengthFilter) Filter(ctx context.Context, input FilterInput) FilterResult {
+	limit := f.MaxLength
+	if input.Settings.MaxLength > 0 && (limit <= 0 || input.Settings.MaxLength < limit) {
+		limit = input.Settings.MaxLength
+	}
+
+	if limit > 0 && len([]rune(input.Text)) > limit {
+		return FilterResult{Verdict: FilterReject, Reason: fmt.Sprintf("message cannot be longer than %d characters", limit)}
+	}
+	return FilterResult{Verdict: FilterAllow}
+}
+
+// FloodFilter collapses runs of the same character longer than the limit, so
+// "noooooooo" becomes "noooo" with a limit of 4. A chat can only lower the
+// server limit.
+type FloodFilter struct {
+	MaxRepeated int
+}
+
+func (f FloodFilter) Filter(ctx context.Context, input FilterInput) FilterResult {
+	limit := f.MaxRepeated
+	if input.Settings.MaxRepeatedChars > 0 && (limit <= 0 || input.Settings.MaxRepeatedChars < limit) {
+		limit = input.Settings.MaxRepeatedChars
+	}
+	if limit <= 0 {
+		return FilterResult{Verdict: FilterAllow}
+	}
+
+	var b strings.Builder
+	var prev rune
+	run := 0
+	changed := false
+	for i, r := range input.Text {
+		if i > 0 && r == prev {
+			run++
+		} else {
+			run = 1
+		}
+		prev = r
+
+		if run > limit {
+			changed = true
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	if !changed {
+		return FilterResult{Verdict: FilterAllow}
+	}
+	return FilterResult{Verdict: FilterRewrite, Text: b.String()}
+}
+
+// WordBlocklistFilter rejects messages containing a server-blocked word. Words
+// blocked by the chat are either rejected or masked, depending on the chat setting.
+type WordBlocklistFilter struct {
+	serverWords map[string]bool
+}
+
+func NewWordBlocklistFilter(words []string) WordBlocklistFilter {
+	return WordBlocklistFilter{serverWords: wordSet(words)}
+}
+
+func (f WordBlocklistFilter) Filter(ctx context.Context, input FilterInput) FilterResult {
+	chatWords := wordSet(input.Settings.BlockedWords)
+	if len(f.serverWords) == 0 && len(chatWords) == 0 {
+		return FilterResult{Verdict: FilterAllow}
+	}
+
+	runes := []rune(input.Text)
+	masked := false
+	for start := 0; start < len(runes); {
+		if !isWordRune(runes[start]) {
+			start++
+			continue
+		}
+
+		end := start
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+
+		word := strings.ToLower(string(runes[start:end]))
+		if f.serverWords[word] {
+			return FilterResult{Verdict: FilterReject, Reason: "message contains a blocked word"}
+		}
+		if chatWords[word] {
+			if !input.Settings.MaskBlockedWords {
+				return FilterResult{Verdict: FilterReject, Reason: "message contains a word blocked in this chat"}
+			}
+			for i := start; i < end; i++ {
+				runes[i] = '*'
+			}
+			masked = true
+		}
+
+		start = end
+	}
+
+	if !masked {
+		return FilterResult{Verdict: FilterAllow}
+	}
+	return FilterResult{Verdict: FilterRewrite, Text: string(runes)}
+}
+
+func wordSet(words []string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		if word = strings.ToLower(strings.TrimSpace(word)); word != "" {
+			set[word] = true
+		}
+	}
+	return set
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+// LinkFilter rejects links blocked by the server or by the chat. Each allowlist
+// only makes exceptions to its own block, so a chat can narrow the links the
+// server allows but never widen them.
+type LinkFilter struct {
+	BlockLinks     bool
+	AllowedDomains []string
+}
+
+func (f LinkFilter) Filter(ctx context.Context, input FilterInput) FilterResult {
+	if !f.BlockLinks && !input.Settings.BlockLinks {
+		return FilterResult{Verdict: FilterAllow}
+	}
+
+	for _, link := range linkPattern.FindAllString(input.Text, -1) {
+		if f.BlockLinks && !isAllowedLink(link, f.AllowedDomains) {
+			return FilterResult{Verdict: FilterReject, Reason: "links are not allowed"}
+		}
+		if input.Settings.BlockLinks && !isAllowedLink(link, input.Settings.AllowedDomains) {
+			return FilterResult{Verdict: FilterReject, Reason: "links are not allowed in this chat"}
+		}
+	}
+	return FilterResult{Verdict: FilterAllow}
+}
+
+func isAllowedLink(link string, allowedDomains []string) bool {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	for _, domain := range allowedDomains {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		if domain != "" && (host == domain || strings.HasSuffix(host, "."+domain)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,450 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/notify"
+	"Flare-server/internal/repository"
+)
+
+const (
+	digestBatch              = 100
+	maxDigestMessagesPerChat = 100
+	digestPreviewMessages    = 3
+	digestPreviewLength      = 300
+)
+
+var ErrInvalidUnsubscribeToken = errors.New("unsubscribe link is invalid")
+
+// DigestOptions configures email digests.
+type DigestOptions struct {
+	// Hour is the local hour of the user's timezone at which digests are sent.
+	Hour int
+	// Weekday is the day weekly digests are sent on.
+	Weekday  time.Weekday
+	MaxChats int
+	// BaseURL is prepended to unsubscribe links.
+	BaseURL string
+	// SigningKey signs unsubscribe links.
+	SigningKey []byte
+}
+
+// DigestService emails users who opted in a periodic summary of unread messages
+// and mentions per chat. A message counts as unread when it arrived after the
+// member last marked the chat as read and after the previous digest. Users that
+// are connected when their digest is due are skipped.
+type DigestService struct {
+	userRepo    *repository.UserRepo
+	chatRepo    *repository.ChatRepo
+	chatService *ChatService
+	notifier    notify.Notifier
+	presence    Presence
+	options     DigestOptions
+}
+
+func NewDigestService(userRepo *repository.UserRepo, chatRepo *repository.ChatRepo, chatService *ChatService, notifier notify.Notifier, presence Presence, options DigestOptions) *DigestService {
+	return &DigestService{
+		userRepo:    userRepo,
+		chatRepo:    chatRepo,
+		chatService: chatService,
+		notifier:    notifier,
+		presence:    presence,
+		options:     options,
+	}
+}
+
+func (s *DigestService) GetSettings(ctx context.Context, userID string) (*models.DigestSettings, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return digestSettings(user), nil
+}
+
+func (s *DigestService) UpdateSettings(ctx context.Context, userID string, req models.UpdateDigestSettingsRequest) (*models.DigestSettings, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Frequency {
+	case models.DigestOff:
+	case models.DigestDaily, models.DigestWeekly:
+		if user.IsBot {
+			return nil, fmt.Errorf("bots cannot receive digests")
+		}
+		if user.Email == "" {
+			return nil, fmt.Errorf("an email address is required to receive digests")
+		}
+	default:
+		return nil, fmt.Errorf("frequency must be off, daily or weekly")
+	}
+
+	nextAt := s.nextDigestAt(req.Frequency, user.Timezone, time.Now())
+	if err := s.userRepo.SetDigestSchedule(ctx, userID, req.Frequency, nextAt); err != nil {
+		return nil, err
+	}
+
+	user.DigestFrequency = req.Frequency
+	user.DigestNextAt = nextAt
+	return digestSettings(user), nil
+}
+
+// Unsubscribe turns digests off for the user the signed token was issued to.
+func (s *DigestService) Unsubscribe(ctx context.Context, token string) error {
+	userID, ok := s.verifyUnsubscribeToken(token)
+	if !ok {
+		return ErrInvalidUnsubscribeToken
+	}
+	if _, err := s.userRepo.GetUserByID(ctx, userID); err != nil {
+		return ErrInvalidUnsubscribeToken
+	}
+	return s.userRepo.SetDigestSchedule(ctx, userID, models.DigestOff, nil)
+}
+
+// UnsubscribeToken returns a token that unsubscribes the user from digests. It
+// does not expire, since old emails should keep working.
+func (s *DigestService) UnsubscribeToken(userID string) string {
+	return userID + "." + base64.RawURLEncoding.EncodeToString(s.sign(userID))
+}
+
+func (s *DigestService) unsubscribeURL(userID string) string {
+	return strings.TrimRight(s.options.BaseURL, "/") + "/api/digest/unsubscribe?token=" + url.QueryEscape(s.UnsubscribeToken(userID))
+}
+
+func (s *DigestService) verifyUnsubscribeToken(token string) (string, bool) {
+	i := strings.LastIndex(token, ".")
+	if i <= 0 {
+		return "", false
+	}
+	userID := token[:i]
+	signature, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || !hmac.Equal(signature, s.sign(userID)) {
+		return "", false
+	}
+	return userID, true
+}
+
+func (s *DigestService) sign(userID string) []byte {
+	mac := hmac.New(sha256.New, s.options.SigningKey)
+	mac.Write([]byte("digest-unsubscribe:" + userID))
+	return mac.Sum(nil)
+}
+
+// Start sends due digests every interval until ctx is cancelled.
+func (s *DigestService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.sendDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *DigestService) sendDue(ctx context.Context) {
+	now := time.Now()
+	due, err := s.userRepo.GetDueDigestUsers(ctx, now, digestBatch)
+	if err != nil {
+		log.Printf("Failed to load due digests: %v", err)
+		return
+	}
+
+	for _, candidate := range due {
+		user, err := s.userRepo.ClaimDigest(ctx, candidate.ID, now, func(user *repository.User) *time.Time {
+			return s.nextDigestAt(user.DigestFrequency, user.Timezone, now)
+		})
+		if err != nil {
+			log.Printf("Failed to claim digest of %s: %v", candidate.ID, err)
+			continue
+		}
+		if user == nil {
+			continue
+		}
+
+		if err := s.sendDigest(ctx, user, now); err != nil {
+			log.Printf("Failed to send digest to %s: %v", user.ID, err)
+			continue
+		}
+		if err := s.userRepo.FinishDigest(ctx, user.ID, now); err != nil {
+			log.Printf("Failed to record digest of %s: %v", user.ID, err)
+		}
+	}
+}
+
+// sendDigest mails the digest unless the user is online or has nothing unread.
+func (s *DigestService) sendDigest(ctx context.Context, user *repository.User, now time.Time) error {
+	if user.IsBot || user.Email == "" || user.IsSuspended(now) || s.presence.IsUserOnline(user.ID) {
+		return nil
+	}
+
+	email, err := s.buildDigest(ctx, user, now)
+	if err != nil {
+		return err
+	}
+	if email == nil {
+		return nil
+	}
+	return s.mailDigest(ctx, user.Email, email)
+}
+
+func (s *DigestService) mailDigest(ctx context.Context, to string, email *digestEmail) error {
+	var text, html bytes.Buffer
+	if err := digestTextTemplate.Execute(&text, email); err != nil {
+		return fmt.Errorf("failed to render digest: %w", err)
+	}
+	if err := digestHTMLTemplate.Execute(&html, email); err != nil {
+		return fmt.Errorf("failed to render digest: %w", err)
+	}
+
+	return s.notifier.Send(ctx, notify.Message{
+		To:      to,
+		Subject: "Flare: непрочитанные сообщения за " + email.Period,
+		Body:    text.String(),
+		HTML:    html.String(),
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + email.UnsubscribeURL + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	})
+}
+
+type digestEmail struct {
+	Username       string
+	Period         string
+	Chats          []digestChat
+	MoreChats      int
+	UnsubscribeURL string
+}
+
+type digestChat struct {
+	Name     string
+	Unread   string
+	Mentions int
+	Messages []digestMessage
+
+	latest time.Time
+}
+
+type digestMessage struct {
+	Time    string
+	Sender  string
+	Text    string
+	Mention bool
+}
+
+// buildDigest collects the unread messages of every chat the user has not
+// muted. It returns nil when there is nothing to report.
+func (s *DigestService) buildDigest(ctx context.Context, user *repository.User, now time.Time) (*digestEmail, error) {
+	memberships, err := s.chatRepo.GetUserMemberships(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	period, periodName := 24*time.Hour, "сутки"
+	if user.DigestFrequency == models.DigestWeekly {
+		period, periodName = 7*24*time.Hour, "неделю"
+	}
+	location := userLocation(user.Timezone)
+
+	var chats []digestChat
+	for _, member := range memberships {
+		if member.IsMuted(now) {
+			continue
+		}
+
+		since := now.Add(-period)
+		for _, t := range []*time.Time{user.DigestLastRunAt, member.LastReadAt} {
+			if t != nil && t.After(since) {
+				since = *t
+			}
+		}
+
+		messages, err := s.chatRepo.GetMessagesSince(ctx, member.ChatID, since, maxDigestMessagesPerChat+1)
+		if err != nil {
+			log.Printf("Failed to get unread messages of chat %s: %v", member.ChatID, err)
+			continue
+		}
+
+		unread := messages[:0]
+		for _, message := range messages {
+			if message.SenderID != user.ID && message.Type != models.MessageTypeSystem {
+				unread = append(unread, message)
+			}
+		}
+		if unread, err = s.chatService.filterHiddenMessages(ctx, member.ChatID, user.ID, unread); err != nil {
+			log.Printf("Failed to filter unread messages of chat %s: %v", member.ChatID, err)
+			continue
+		}
+		if len(unread) == 0 {
+			continue
+		}
+
+		chat, err := s.chatRepo.GetChatByID(ctx, member.ChatID)
+		if err != nil {
+			continue
+		}
+		chats = append(chats, buildDigestChat(chat, unread, user.Username, location))
+	}
+	if len(chats) == 0 {
+		return nil, nil
+	}
+
+	sort.SliceStable(chats, func(i, j int) bool {
+		if (chats[i].Mentions > 0) != (chats[j].Mentions > 0) {
+			return chats[i].Mentions > 0
+		}
+		return chats[i].latest.After(chats[j].latest)
+	})
+
+	email := &digestEmail{
+		Username:       user.Username,
+		Period:         periodName,
+		Chats:          chats,
+		UnsubscribeURL: s.unsubscribeURL(user.ID),
+	}
+	if s.options.MaxChats > 0 && len(chats) > s.options.MaxChats {
+		email.Chats = chats[:s.options.MaxChats]
+		email.MoreChats = len(chats) - s.options.MaxChats
+	}
+	return email, nil
+}
+
+// buildDigestChat summarizes one chat: mentions of the user are listed first,
+// then the newest messages, digestPreviewMessages in total.
+func buildDigestChat(chat *models.Chat, unread []models.Message, username string, location *time.Location) digestChat {
+	last := unread[len(unread)-1]
+
+	summary := digestChat{
+		Name:   chat.Name,
+		Unread: strconv.Itoa(len(unread)),
+		latest: last.Timestamp,
+	}
+	if chat.Type != models.ChatTypeGroup {
+		summary.Name = last.Username
+	}
+	if len(unread) > maxDigestMessagesPerChat {
+		summary.Unread = strconv.Itoa(maxDigestMessagesPerChat) + "+"
+	}
+
+	var mentions, others []digestMessage
+	for _, message := range unread {
+		preview := digestMessage{
+			Time:   message.Timestamp.In(location).Format("02.01 15:04"),
+			Sender: message.Username,
+			Text:   pushPreview(message),
+		}
+		if runes := []rune(preview.Text); len(runes) > digestPreviewLength {
+			preview.Text = string(runes[:digestPreviewLength]) + "…"
+		}
+
+		if mentionsUser(message.Text, username) {
+			preview.Mention = true
+			summary.Mentions++
+			mentions = append(mentions, preview)
+		} else {
+			others = append(others, preview)
+		}
+	}
+
+	if len(mentions) > digestPreviewMessages {
+		mentions = mentions[len(mentions)-digestPreviewMessages:]
+	}
+	if rest := digestPreviewMessages - len(mentions); len(others) > rest {
+		others = others[len(others)-rest:]
+	}
+	summary.Messages = append(mentions, others...)
+	return summary
+}
+
+// mentionsUser reports whether text contains @username as a whole word.
+func mentionsUser(text, username string) bool {
+	if username == "" {
+		return false
+	}
+	text = strings.ToLower(text)
+	mention := "@" + strings.ToLower(username)
+
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], mention)
+		if i < 0 {
+			return false
+		}
+		start := offset + i
+		end := start + len(mention)
+		// A dot ends the mention unless the username continues after it.
+		rest := strings.TrimPrefix(text[end:], ".")
+		if (start == 0 || !isUsernameByte(text[start-1])) && (rest == "" || !isUsernameByte(rest[0])) {
+			return true
+		}
+		offset = end
+	}
+}
+
+func isUsernameByte(b byte) bool {
+	return b == '_' || b == '.' || b >= 'a' && b <= 'z' || b >= '0' && b <= '9'
+}
+
+// nextDigestAt returns when the next digest of the given frequency is due: the
+// configured hour in the user's timezone, on the configured weekday for weekly
+// digests. It returns nil when digests are off.
+func (s *DigestService) nextDigestAt(frequency models.DigestFrequency, timezone string, now time.Time) *time.Time {
+	if frequency != models.DigestDaily && frequency != models.DigestWeekly {
+		return nil
+	}
+
+	local := now.In(userLocation(timezone))
+	next := time.Date(local.Year(), local.Month(), local.Day(), s.options.Hour, 0, 0, 0, local.Location())
+	if !next.After(local) {
+		next = next.AddDate(0, 0, 1)
+	}
+	if frequency == models.DigestWeekly {
+		for next.Weekday() != s.options.Weekday {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+
+	next = next.UTC()
+	return &next
+}
+
+func userLocation(timezone string) *time.Location {
+	if timezone == "" {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+func digestSettings(user *repository.User) *models.DigestSettings {
+	frequency := user.DigestFrequency
+	if frequency == "" {
+		frequency = models.DigestOff
+	}
+	return &models.DigestSettings{
+		Frequency: frequency,
+		NextAt:    user.DigestNextAt,
+		LastRunAt: user.DigestLastRunAt,
+	}
+}
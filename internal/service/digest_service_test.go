@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/notify"
+	"Flare-server/internal/notify/notifytest"
+)
+
+// digestTestMessages returns count messages oldest first, the way
+// GetMessagesSince returns the newest messages of a chat.
+func digestTestMessages(start time.Time, count int) []models.Message {
+	messages := make([]models.Message, count)
+	for i := range messages {
+		messages[i] = models.Message{
+			ID:        fmt.Sprintf("m%d", i),
+			SenderID:  "bob-id",
+			Username:  "bob",
+			Type:      models.MessageTypeText,
+			Text:      fmt.Sprintf("message %d", i),
+			Timestamp: start.Add(time.Duration(i) * time.Minute),
+		}
+	}
+	return messages
+}
+
+func TestBuildDigestChatShowsMentionsThenNewestMessages(t *testing.T) {
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	messages := digestTestMessages(start, maxDigestMessagesPerChat+1)
+	messages[10].Text = "@alice can you look at this?"
+	messages[20].Text = "ping @alicia"
+
+	chat := &models.Chat{Name: "Team", Type: models.ChatTypeGroup}
+	summary := buildDigestChat(chat, messages, "alice", time.UTC)
+
+	if summary.Name != "Team" || summary.Unread != "100+" || summary.Mentions != 1 {
+		t.Errorf("unexpected summary: name %q, unread %q, mentions %d", summary.Name, summary.Unread, summary.Mentions)
+	}
+	var texts []string
+	for _, m := range summary.Messages {
+		texts = append(texts, m.Text)
+	}
+	want := []string{"@alice can you look at this?", "message 99", "message 100"}
+	if strings.Join(texts, "|") != strings.Join(want, "|") {
+		t.Errorf("previews = %q, want %q", texts, want)
+	}
+	if !summary.Messages[0].Mention || summary.Messages[1].Mention {
+		t.Error("only the mention should be highlighted")
+	}
+	if !summary.latest.Equal(messages[len(messages)-1].Timestamp) {
+		t.Errorf("latest = %v, want the newest message", summary.latest)
+	}
+}
+
+func TestBuildDigestChatNamesPrivateChatAfterSender(t *testing.T) {
+	messages := digestTestMessages(time.Now(), 2)
+	summary := buildDigestChat(&models.Chat{Type: models.ChatTypePrivate}, messages, "alice", time.UTC)
+	if summary.Name != "bob" || summary.Unread != "2" {
+		t.Errorf("unexpected summary: name %q, unread %q", summary.Name, summary.Unread)
+	}
+}
+
+func TestDigestMailedThroughSMTP(t *testing.T) {
+	sink := notifytest.NewSMTPSink(t)
+	s := &DigestService{
+		notifier: notify.NewSMTPNotifier(sink.Config()),
+		options:  DigestOptions{BaseURL: "https://flare.test/", SigningKey: []byte("digest-key")},
+	}
+
+	messages := digestTestMessages(time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), maxDigestMessagesPerChat+1)
+	messages[len(messages)-1].Text = "<script>alert(1)</script> @alice"
+	email := &digestEmail{
+		Username:       "alice",
+		Period:         "сутки",
+		Chats:          []digestChat{buildDigestChat(&models.Chat{Name: "Team", Type: models.ChatTypeGroup}, messages, "alice", time.UTC)},
+		MoreChats:      2,
+		UnsubscribeURL: s.unsubscribeURL("alice-id"),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.mailDigest(ctx, "alice@example.com", email); err != nil {
+		t.Fatalf("mailDigest failed: %v", err)
+	}
+
+	got := sink.Receive(t)
+	if len(got.To) != 1 || got.To[0] != "alice@example.com" {
+		t.Errorf("RCPT TO = %v", got.To)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(got.Data))
+	if err != nil {
+		t.Fatalf("failed to parse message: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != "Flare: непрочитанные сообщения за сутки" {
+		t.Errorf("Subject = %q (%v)", subject, err)
+	}
+
+	unsubscribe := strings.Trim(parsed.Header.Get("List-Unsubscribe"), "<>")
+	if !strings.HasPrefix(unsubscribe, "https://flare.test/api/digest/unsubscribe?token=") || parsed.Header.Get("List-Unsubscribe-Post") != "List-Unsubscribe=One-Click" {
+		t.Errorf("unexpected unsubscribe headers: %v", parsed.Header)
+	}
+	link, _ := url.Parse(unsubscribe)
+	if userID, ok := s.verifyUnsubscribeToken(link.Query().Get("token")); !ok || userID != "alice-id" {
+		t.Errorf("unsubscribe token resolves to %q, %v", userID, ok)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q", parsed.Header.Get("Content-Type"))
+	}
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	bodies := make(map[string]string)
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("failed to read part: %v", err)
+		}
+		body, _ := io.ReadAll(part)
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		bodies[contentType] = string(body)
+	}
+
+	text := bodies["text/plain"]
+	for _, want := range []string{"Здравствуйте, alice!", "Team - непрочитанных: 100+, упоминаний: 1", "@ 02.03 10:40 bob: <script>alert(1)</script> @alice", "bob: message 99", "И еще чатов с непрочитанными сообщениями: 2", unsubscribe} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text part does not contain %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "message 0\r\n") {
+		t.Errorf("text part shows the oldest messages:\n%s", text)
+	}
+
+	html := bodies["text/html"]
+	if !strings.Contains(html, "&lt;script&gt;alert(1)&lt;/script&gt; @alice") || strings.Contains(html, "<script>") {
+		t.Errorf("HTML part does not escape message text:\n%s", html)
+	}
+	if !strings.Contains(html, "message 99") {
+		t.Errorf("HTML part does not contain the newest messages:\n%s", html)
+	}
+}
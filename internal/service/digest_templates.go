@@ -0,0 +1,53 @@
+package service
+
+import (
+	htmltemplate "html/template"
+	texttemplate "text/template"
+)
+
+var digestTextTemplate = texttemplate.Must(texttemplate.New("digest.txt").Parse(`Здравствуйте, {{.Username}}!
+
+Непрочитанные сообщения за {{.Period}}:
+{{range .Chats}}
+{{.Name}} - непрочитанных: {{.Unread}}{{if .Mentions}}, упоминаний: {{.Mentions}}{{end}}
+{{range .Messages}}  {{if .Mention}}@ {{end}}{{.Time}} {{.Sender}}: {{.Text}}
+{{end}}{{end}}{{if .MoreChats}}
+И еще чатов с непрочитанными сообщениями: {{.MoreChats}}
+{{end}}
+--
+Вы получаете это письмо, потому что включили дайджест в настройках Flare.
+Отписаться: {{.UnsubscribeURL}}
+`))
+
+var digestHTMLTemplate = htmltemplate.Must(htmltemplate.New("digest.html").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>Flare</title>
+</head>
+<body style="margin:0;padding:24px;background:#f4f5f7;font-family:Arial,sans-serif;color:#1f2328;">
+<div style="max-width:600px;margin:0 auto;background:#ffffff;border-radius:8px;padding:24px;">
+<p style="font-size:16px;">Здравствуйте, {{.Username}}!</p>
+<p style="font-size:14px;color:#57606a;">Непрочитанные сообщения за {{.Period}}:</p>
+{{range .Chats}}
+<div style="border-top:1px solid #d0d7de;padding:12px 0;">
+<div style="font-size:15px;font-weight:bold;">{{.Name}}</div>
+<div style="font-size:13px;color:#57606a;margin-bottom:8px;">непрочитанных: {{.Unread}}{{if .Mentions}}, упоминаний: <strong style="color:#cf222e;">{{.Mentions}}</strong>{{end}}</div>
+{{range .Messages}}
+<div style="font-size:14px;margin:4px 0;{{if .Mention}}background:#fff8c5;padding:4px;{{end}}">
+<span style="color:#57606a;">{{.Time}}</span> <strong>{{.Sender}}</strong>: {{.Text}}
+</div>
+{{end}}
+</div>
+{{end}}
+{{if .MoreChats}}
+<p style="font-size:14px;color:#57606a;">И еще чатов с непрочитанными сообщениями: {{.MoreChats}}</p>
+{{end}}
+<p style="font-size:12px;color:#8c959f;border-top:1px solid #d0d7de;padding-top:12px;">
+Вы получаете это письмо, потому что включили дайджест в настройках Flare.
+<a href="{{.UnsubscribeURL}}" style="color:#8c959f;">Отписаться</a>
+</p>
+</div>
+</body>
+</html>
+`))
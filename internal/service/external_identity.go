@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+
+	"Flare-server/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const maxUsernameAttempts = 5
+
+// ExternalIdentity is a user asserted by an external identity provider.
+type ExternalIdentity struct {
+	Provider          string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	DisplayName       string
+	// AuthTime is when the user signed in at the provider. Tokens refreshed from
+	// that sign-in keep it, so sessions revoked after it can be recognized.
+	AuthTime time.Time
+}
+
+// ErrExternalEmailInUse is returned when an unlinked identity carries the email
+// of an existing account. Flare does not verify emails at registration, so the
+// account may belong to someone else; its owner has to sign in and link the
+// identity instead.
+var ErrExternalEmailInUse = errors.New("an account with this email already exists; sign in and link this login method to it")
+
+// ResolveExternalUser returns the Flare user linked to an external identity. An
+// unknown identity gets a new user, and is never linked to an existing account by
+// email, see ErrExternalEmailInUse.
+func (s *AuthService) ResolveExternalUser(ctx context.Context, identity ExternalIdentity) (*repository.User, error) {
+	if identity.Subject == "" {
+		return nil, errors.New("identity has no subject")
+	}
+
+	user, err := s.userRepo.GetUserByIdentity(ctx, identity.Provider, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		return user, nil
+	}
+
+	email, _ := normalizeEmail(identity.Email)
+	if !identity.EmailVerified {
+		email = ""
+	}
+	if email != "" {
+		if _, err := s.userRepo.GetUserByEmail(ctx, email); err == nil {
+			return nil, ErrExternalEmailInUse
+		}
+	}
+
+	user, err = s.createExternalUser(ctx, identity, email)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("👤 Created user %s for %s identity %s", user.Username, identity.Provider, identity.Subject)
+
+	err = s.userRepo.LinkIdentity(ctx, repository.UserIdentity{
+		UserID:   user.ID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    email,
+	})
+	if errors.Is(err, repository.ErrIdentityLinked) {
+		// A concurrent login linked it first; use whatever it was linked to.
+		return s.userRepo.GetUserByIdentity(ctx, identity.Provider, identity.Subject)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// LinkExternalIdentity links an identity to a signed-in user. Linking an identity
+// the user already has is not an error.
+func (s *AuthService) LinkExternalIdentity(ctx context.Context, userID string, identity ExternalIdentity) error {
+	if identity.Subject == "" {
+		return errors.New("identity has no subject")
+	}
+
+	email, _ := normalizeEmail(identity.Email)
+	if !identity.EmailVerified {
+		email = ""
+	}
+
+	err := s.userRepo.LinkIdentity(ctx, repository.UserIdentity{
+		UserID:   userID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    email,
+	})
+	if !errors.Is(err, repository.ErrIdentityLinked) {
+		return err
+	}
+
+	linked, err := s.userRepo.GetUserByIdentity(ctx, identity.Provider, identity.Subject)
+	if err != nil {
+		return err
+	}
+	if linked == nil || linked.ID != userID {
+		return repository.ErrIdentityLinked
+	}
+	return nil
+}
+
+// createExternalUser creates a user with an unusable random password. It can
+// still set a password later through the reset flow if it has an email.
+func (s *AuthService) createExternalUser(ctx context.Context, identity ExternalIdentity, email string) (*repository.User, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(base64.RawURLEncoding.EncodeToString(secret)), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	base := usernameBase(identity)
+	candidate := base
+	for attempt := 0; attempt < maxUsernameAttempts; attempt++ {
+		if attempt > 0 {
+			suffix, err := rand.Int(rand.Reader, big.NewInt(10000))
+			if err != nil {
+				return nil, err
+			}
+			candidate = fmt.Sprintf("%s%04d", base, suffix.Int64())
+		}
+		if s.usernamePolicy.Validate(candidate) != nil {
+			continue
+		}
+
+		user, err := s.userRepo.SaveUser(ctx, repository.User{
+			Username:    candidate,
+			Password:    string(hashed),
+			Email:       email,
+			DisplayName: strings.TrimSpace(identity.DisplayName),
+		})
+		if errors.Is(err, repository.ErrUsernameTaken) {
+			continue
+		}
+		return user, err
+	}
+	return nil, fmt.Errorf("could not find a free username for %s", base)
+}
+
+// usernameBase derives a valid username stem from the identity claims, leaving
+// room for a numeric suffix.
+func usernameBase(identity ExternalIdentity) string {
+	for _, source := range []string{identity.PreferredUsername, strings.Split(identity.Email, "@")[0]} {
+		var b strings.Builder
+		for _, r := range source {
+			if r < 128 && (r == '_' || r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+				b.WriteRune(r)
+			}
+		}
+		name := b.String()
+		if len(name) > 28 {
+			name = name[:28]
+		}
+		if len(name) >= 3 {
+			return name
+		}
+	}
+	return "user"
+}
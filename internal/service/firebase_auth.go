@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"Flare-server/internal/repository"
+
+	"firebase.google.com/go/v4/auth"
+	"github.com/MicahParks/keyfunc"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const firebaseIssuerPrefix = "https://securetoken.google.com/"
+
+// FirebaseTokenVerifier verifies a Firebase Authentication ID token and returns
+// the identity it asserts.
+type FirebaseTokenVerifier interface {
+	VerifyIDToken(ctx context.Context, idToken string) (*ExternalIdentity, error)
+}
+
+// FirebaseSessionRevoker is implemented by verifiers that can sign a Firebase
+// user out of all devices.
+type FirebaseSessionRevoker interface {
+	RevokeSessions(ctx context.Context, uid string) error
+}
+
+// FirebaseAdminVerifier verifies tokens with the Admin SDK auth client.
+type FirebaseAdminVerifier struct {
+	client       *auth.Client
+	checkRevoked bool
+}
+
+func NewFirebaseAdminVerifier(client *auth.Client, checkRevoked bool) *FirebaseAdminVerifier {
+	return &FirebaseAdminVerifier{
+		client:       client,
+		checkRevoked: checkRevoked,
+	}
+}
+
+func (v *FirebaseAdminVerifier) VerifyIDToken(ctx context.Context, idToken string) (*ExternalIdentity, error) {
+	var token *auth.Token
+	var err error
+	if v.checkRevoked {
+		token, err = v.client.VerifyIDTokenAndCheckRevoked(ctx, idToken)
+	} else {
+		token, err = v.client.VerifyIDToken(ctx, idToken)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid Firebase ID token: %w", err)
+	}
+
+	email, _ := token.Claims["email"].(string)
+	emailVerified, _ := token.Claims["email_verified"].(bool)
+	name, _ := token.Claims["name"].(string)
+	authTime := token.IssuedAt
+	if value, ok := token.Claims["auth_time"].(float64); ok {
+		authTime = int64(value)
+	}
+	return &ExternalIdentity{
+		Provider:      "firebase",
+		Subject:       token.UID,
+		Email:         email,
+		EmailVerified: emailVerified,
+		DisplayName:   name,
+		AuthTime:      time.Unix(authTime, 0),
+	}, nil
+}
+
+// RevokeSessions revokes the refresh tokens of the Firebase user. ID tokens it
+// already holds are rejected by Flare's own session cutoff.
+func (v *FirebaseAdminVerifier) RevokeSessions(ctx context.Context, uid string) error {
+	return v.client.RevokeRefreshTokens(ctx, uid)
+}
+
+// FirebaseJWKSVerifier checks tokens against a JWKS endpoint with the issuer and
+// audience Firebase uses. It stands in for the Admin SDK when tokens come from a
+// local emulator or a test identity provider.
+type FirebaseJWKSVerifier struct {
+	jwks      *keyfunc.JWKS
+	projectID string
+}
+
+type firebaseClaims struct {
+	Email         string           `json:"email"`
+	EmailVerified bool             `json:"email_verified"`
+	Name          string           `json:"name"`
+	AuthTime      *jwt.NumericDate `json:"auth_time"`
+	jwt.RegisteredClaims
+}
+
+func NewFirebaseJWKSVerifier(jwksURL, projectID string) (*FirebaseJWKSVerifier, error) {
+	if projectID == "" {
+		return nil, errors.New("a Firebase project ID is required to verify ID tokens")
+	}
+
+	jwks, err := keyfunc.Get(jwksURL, keyfunc.Options{
+		RefreshInterval:   time.Hour,
+		RefreshRateLimit:  time.Minute,
+		RefreshUnknownKID: true,
+		RefreshErrorHandler: func(err error) {
+			log.Printf("Failed to refresh Firebase JWKS: %v", err)
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Firebase JWKS: %w", err)
+	}
+
+	return &FirebaseJWKSVerifier{jwks: jwks, projectID: projectID}, nil
+}
+
+func (v *FirebaseJWKSVerifier) VerifyIDToken(ctx context.Context, idToken string) (*ExternalIdentity, error) {
+	var claims firebaseClaims
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{"RS256"}))
+	token, err := parser.ParseWithClaims(idToken, &claims, v.jwks.Keyfunc)
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid Firebase ID token: %v", err)
+	}
+
+	if !claims.VerifyIssuer(firebaseIssuerPrefix+v.projectID, true) || !claims.VerifyAudience(v.projectID, true) {
+		return nil, errors.New("invalid Firebase ID token: wrong project")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid Firebase ID token: missing subject")
+	}
+
+	authTime := claims.AuthTime
+	if authTime == nil {
+		authTime = claims.IssuedAt
+	}
+	if authTime == nil {
+		return nil, errors.New("invalid Firebase ID token: missing auth_time")
+	}
+
+	return &ExternalIdentity{
+		Provider:      "firebase",
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified,
+		DisplayName:   claims.Name,
+		AuthTime:      authTime.Time,
+	}, nil
+}
+
+// SetFirebaseVerifier enables Firebase ID tokens next to Flare's own tokens.
+func (s *AuthService) SetFirebaseVerifier(verifier FirebaseTokenVerifier) {
+	s.firebaseVerifier = verifier
+}
+
+// IsFirebaseToken reports whether the token looks like a Firebase ID token and
+// Firebase tokens are enabled. The signature is not checked here.
+func (s *AuthService) IsFirebaseToken(tokenString string) bool {
+	if s.firebaseVerifier == nil {
+		return false
+	}
+
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return false
+	}
+	issuer, _ := claims["iss"].(string)
+	return strings.HasPrefix(issuer, firebaseIssuerPrefix)
+}
+
+// validateFirebaseToken maps a Firebase UID to its Flare user, provisioning the
+// user on first login. The session checks of Flare tokens apply, keyed on the
+// time the user signed in to Firebase. A Firebase token cannot satisfy Flare's
+// second factor, so users with 2FA have to exchange it with LoginWithFirebase.
+func (s *AuthService) validateFirebaseToken(ctx context.Context, tokenString string) (*repository.User, error) {
+	user, err := s.resolveFirebaseUser(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrTwoFactorRequired
+	}
+	return user, nil
+}
+
+// LoginWithFirebase exchanges a Firebase ID token for a Flare token, or for a
+// 2FA challenge when the user has two-factor authentication enabled.
+func (s *AuthService) LoginWithFirebase(ctx context.Context, idToken string) (*LoginResult, error) {
+	if !s.IsFirebaseToken(idToken) {
+		return nil, errors.New("invalid Firebase ID token")
+	}
+	if err := s.checkNotRevoked(ctx, firebaseTokenID(idToken)); err != nil {
+		return nil, err
+	}
+
+	user, err := s.resolveFirebaseUser(ctx, idToken)
+	if err != nil {
+		return nil, err
+	}
+	return s.beginLogin(ctx, user)
+}
+
+// LinkFirebaseIdentity links the Firebase account of the ID token to the
+// signed-in user. Existing accounts are never linked by email, so this is how a
+// user adds Firebase sign-in to them.
+func (s *AuthService) LinkFirebaseIdentity(ctx context.Context, userID, idToken string) error {
+	if !s.IsFirebaseToken(idToken) {
+		return errors.New("invalid Firebase ID token")
+	}
+
+	identity, err := s.firebaseVerifier.VerifyIDToken(ctx, idToken)
+	if err != nil {
+		return err
+	}
+	return s.LinkExternalIdentity(ctx, userID, *identity)
+}
+
+func (s *AuthService) resolveFirebaseUser(ctx context.Context, tokenString string) (*repository.User, error) {
+	identity, err := s.firebaseVerifier.VerifyIDToken(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.ResolveExternalUser(ctx, *identity)
+	if err != nil {
+		return nil, err
+	}
+	return s.checkSession(ctx, user.ID, identity.AuthTime)
+}
+
+// revokeFirebaseSessions signs the linked Firebase accounts of the user out of
+// Firebase. Failures are only logged: the session cutoff recorded by
+// RevokeAllTokens already rejects their current ID tokens.
+func (s *AuthService) revokeFirebaseSessions(ctx context.Context, userID string) {
+	revoker, ok := s.firebaseVerifier.(FirebaseSessionRevoker)
+	if !ok {
+		return
+	}
+
+	identities, err := s.userRepo.GetUserIdentities(ctx, userID, "firebase")
+	if err != nil {
+		log.Printf("Failed to get Firebase identities of %s: %v", userID, err)
+		return
+	}
+	for _, identity := range identities {
+		if err := revoker.RevokeSessions(ctx, identity.Subject); err != nil {
+			log.Printf("Failed to revoke Firebase sessions of %s: %v", userID, err)
+		}
+	}
+}
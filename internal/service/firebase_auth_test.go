@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	testFirebaseProject = "flare-test"
+	testFirebaseKeyID   = "firebase-key"
+)
+
+// newFirebaseJWKSStandIn serves a JWKS with one RSA key, the way a Firebase Auth
+// emulator or test identity provider would, and returns a verifier using it.
+func newFirebaseJWKSStandIn(t *testing.T) (*rsa.PrivateKey, *FirebaseJWKSVerifier) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": testFirebaseKeyID,
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	verifier, err := NewFirebaseJWKSVerifier(server.URL, testFirebaseProject)
+	if err != nil {
+		t.Fatalf("failed to create verifier: %v", err)
+	}
+	t.Cleanup(verifier.jwks.EndBackground)
+	return key, verifier
+}
+
+func firebaseTestClaims(now time.Time) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            firebaseIssuerPrefix + testFirebaseProject,
+		"aud":            testFirebaseProject,
+		"sub":            "firebase-uid",
+		"email":          "alice@example.com",
+		"email_verified": true,
+		"name":           "Alice",
+		"auth_time":      now.Add(-2 * time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+}
+
+func signFirebaseTestToken(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = testFirebaseKeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestFirebaseJWKSVerifierAcceptsValidToken(t *testing.T) {
+	key, verifier := newFirebaseJWKSStandIn(t)
+	now := time.Now()
+
+	identity, err := verifier.VerifyIDToken(context.Background(), signFirebaseTestToken(t, key, firebaseTestClaims(now)))
+	if err != nil {
+		t.Fatalf("VerifyIDToken failed: %v", err)
+	}
+	if identity.Provider != "firebase" || identity.Subject != "firebase-uid" || identity.Email != "alice@example.com" || !identity.EmailVerified || identity.DisplayName != "Alice" {
+		t.Errorf("unexpected identity: %+v", identity)
+	}
+	// Sessions are revoked by sign-in time, which survives token refreshes.
+	if want := now.Add(-2 * time.Hour).Unix(); identity.AuthTime.Unix() != want {
+		t.Errorf("AuthTime = %v, want %v", identity.AuthTime.Unix(), want)
+	}
+}
+
+func TestFirebaseJWKSVerifierFallsBackToIssuedAt(t *testing.T) {
+	key, verifier := newFirebaseJWKSStandIn(t)
+	now := time.Now()
+	claims := firebaseTestClaims(now)
+	delete(claims, "auth_time")
+
+	identity, err := verifier.VerifyIDToken(context.Background(), signFirebaseTestToken(t, key, claims))
+	if err != nil {
+		t.Fatalf("VerifyIDToken failed: %v", err)
+	}
+	if identity.AuthTime.Unix() != now.Unix() {
+		t.Errorf("AuthTime = %v, want iat %v", identity.AuthTime.Unix(), now.Unix())
+	}
+}
+
+func TestFirebaseJWKSVerifierRejectsInvalidTokens(t *testing.T) {
+	key, verifier := newFirebaseJWKSStandIn(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		want  string
+		token func(claims jwt.MapClaims) string
+	}{
+		{"other project", "wrong project", func(claims jwt.MapClaims) string {
+			claims["aud"] = "other-project"
+			return signFirebaseTestToken(t, key, claims)
+		}},
+		{"other issuer", "wrong project", func(claims jwt.MapClaims) string {
+			claims["iss"] = firebaseIssuerPrefix + "other-project"
+			return signFirebaseTestToken(t, key, claims)
+		}},
+		{"expired", "expired", func(claims jwt.MapClaims) string {
+			claims["exp"] = time.Now().Add(-time.Minute).Unix()
+			return signFirebaseTestToken(t, key, claims)
+		}},
+		{"missing subject", "missing subject", func(claims jwt.MapClaims) string {
+			delete(claims, "sub")
+			return signFirebaseTestToken(t, key, claims)
+		}},
+		{"unknown key", "verification error", func(claims jwt.MapClaims) string {
+			return signFirebaseTestToken(t, otherKey, claims)
+		}},
+		{"HMAC", "signing method HS256 is invalid", func(claims jwt.MapClaims) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+			token.Header["kid"] = testFirebaseKeyID
+			signed, _ := token.SignedString([]byte(testFirebaseProject))
+			return signed
+		}},
+		{"alg none", "signing method none is invalid", func(claims jwt.MapClaims) string {
+			signed, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return signed
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifier.VerifyIDToken(context.Background(), tt.token(firebaseTestClaims(time.Now())))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestIsFirebaseToken(t *testing.T) {
+	key, verifier := newFirebaseJWKSStandIn(t)
+	token := signFirebaseTestToken(t, key, firebaseTestClaims(time.Now()))
+
+	s := &AuthService{}
+	if s.IsFirebaseToken(token) {
+		t.Error("Firebase tokens must not be recognized while Firebase auth is disabled")
+	}
+
+	s.SetFirebaseVerifier(verifier)
+	if !s.IsFirebaseToken(token) {
+		t.Error("expected a Firebase token to be recognized")
+	}
+
+	flare := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iss": "flare"})
+	signed, _ := flare.SignedString([]byte("secret"))
+	if s.IsFirebaseToken(signed) {
+		t.Error("a Flare token must not be taken for a Firebase token")
+	}
+}
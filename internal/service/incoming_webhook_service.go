@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/ratelimit"
+	"Flare-server/internal/repository"
+)
+
+const maxIncomingWebhooksPerChat = 10
+
+var ErrInvalidIncomingWebhook = errors.New("incoming webhook not found")
+
+// IncomingWebhookService manages incoming webhooks and posts their messages
+// through ChatService, so chat filters and slow mode apply to them.
+type IncomingWebhookService struct {
+	hookRepo    *repository.IncomingWebhookRepo
+	chatService *ChatService
+	broadcaster Broadcaster
+	limiter     *ratelimit.Limiter
+	baseURL     string
+}
+
+// NewIncomingWebhookService creates the service. baseURL is prepended to the
+// secret path returned on creation and may be empty.
+func NewIncomingWebhookService(hookRepo *repository.IncomingWebhookRepo, chatService *ChatService, broadcaster Broadcaster, limiter *ratelimit.Limiter, baseURL string) *IncomingWebhookService {
+	return &IncomingWebhookService{
+		hookRepo:    hookRepo,
+		chatService: chatService,
+		broadcaster: broadcaster,
+		limiter:     limiter,
+		baseURL:     strings.TrimRight(baseURL, "/"),
+	}
+}
+
+func (s *IncomingWebhookService) CreateIncomingWebhook(ctx context.Context, chatID, userID string, req models.CreateIncomingWebhookRequest) (*models.IncomingWebhookCreatedResponse, error) {
+	if err := s.requireAdmin(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if err := validateSenderName(name, true); err != nil {
+		return nil, err
+	}
+	avatarURL := strings.TrimSpace(req.AvatarURL)
+	if err := validateAvatarURL(avatarURL); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.hookRepo.GetChatIncomingWebhooks(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxIncomingWebhooksPerChat {
+		return nil, fmt.Errorf("a chat can have at most %d incoming webhooks", maxIncomingWebhooksPerChat)
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	hook, err := s.hookRepo.CreateIncomingWebhook(ctx, models.IncomingWebhook{
+		ChatID:     chatID,
+		Name:       name,
+		AvatarURL:  avatarURL,
+		SecretHash: hashSecret(secret),
+		CreatedBy:  userID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.IncomingWebhookCreatedResponse{
+		Webhook: *hook,
+		URL:     s.baseURL + "/api/hooks/" + hook.ID + "/" + secret,
+	}, nil
+}
+
+func (s *IncomingWebhookService) GetIncomingWebhooks(ctx context.Context, chatID, userID string) ([]models.IncomingWebhook, error) {
+	if err := s.requireAdmin(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+	return s.hookRepo.GetChatIncomingWebhooks(ctx, chatID)
+}
+
+// DeleteIncomingWebhook revokes the webhook; its URL stops working at once.
+func (s *IncomingWebhookService) DeleteIncomingWebhook(ctx context.Context, chatID, hookID, userID string) error {
+	if err := s.requireAdmin(ctx, chatID, userID); err != nil {
+		return err
+	}
+
+	hook, err := s.hookRepo.GetIncomingWebhook(ctx, hookID)
+	if err != nil || hook.ChatID != chatID {
+		return ErrInvalidIncomingWebhook
+	}
+	return s.hookRepo.DeleteIncomingWebhook(ctx, hookID)
+}
+
+// Post authenticates the secret URL and posts the payload into the chat. Unknown
+// webhooks and wrong secrets get the same error.
+func (s *IncomingWebhookService) Post(ctx context.Context, hookID, secret string, req models.IncomingWebhookMessage) (*models.Message, error) {
+	hook, err := s.hookRepo.GetIncomingWebhook(ctx, hookID)
+	if err != nil {
+		return nil, ErrInvalidIncomingWebhook
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(hook.SecretHash)) != 1 {
+		return nil, ErrInvalidIncomingWebhook
+	}
+
+	if err := s.limiter.Allow(ctx, "hook:"+hook.ID); err != nil {
+		return nil, err
+	}
+
+	displayName := strings.TrimSpace(req.DisplayName)
+	if err := validateSenderName(displayName, false); err != nil {
+		return nil, err
+	}
+	avatarURL := strings.TrimSpace(req.AvatarURL)
+	if err := validateAvatarURL(avatarURL); err != nil {
+		return nil, err
+	}
+
+	message, err := s.chatService.SendIncomingWebhookMessage(ctx, hook, displayName, avatarURL, req.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.hookRepo.TouchIncomingWebhook(ctx, hook.ID); err != nil {
+		log.Printf("Failed to update last use of incoming webhook %s: %v", hook.ID, err)
+	}
+
+	s.broadcaster.BroadcastMessage(hook.ChatID, "new_message", message)
+	return message, nil
+}
+
+func (s *IncomingWebhookService) requireAdmin(ctx context.Context, chatID, userID string) error {
+	isAdmin, err := s.chatService.isUserAdmin(ctx, chatID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check admin rights: %w", err)
+	}
+	if !isAdmin {
+		return fmt.Errorf("access denied: only chat admins can manage incoming webhooks")
+	}
+	return nil
+}
+
+func validateSenderName(name string, required bool) error {
+	if name == "" {
+		if required {
+			return fmt.Errorf("name is required")
+		}
+		return nil
+	}
+	if len([]rune(name)) > maxDisplayNameLength {
+		return fmt.Errorf("display name cannot be longer than %d characters", maxDisplayNameLength)
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+package service
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"Flare-server/internal/repository"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// A verifier that sees an unknown kid reloads the keys, at most this often.
+const keyRingReloadInterval = 10 * time.Second
+
+// KeyRingOptions configures asymmetric token signing.
+type KeyRingOptions struct {
+	Algorithm        string // RS256 or EdDSA
+	RotationInterval time.Duration
+	// GracePeriod keeps a retired key published so tokens it signed stay valid.
+	// It should be longer than the token lifetime.
+	GracePeriod time.Duration
+	// EncryptionKey is the 32-byte AES key that encrypts private keys at rest.
+	EncryptionKey []byte
+}
+
+// signingKeyStore is the part of repository.SigningKeyRepo the ring uses.
+type signingKeyStore interface {
+	GetSigningKeys(ctx context.Context) ([]repository.SigningKey, error)
+	RotateSigningKey(ctx context.Context, newKey repository.SigningKey, rotateBefore time.Time, gracePeriod time.Duration) (bool, error)
+	SealSigningKey(ctx context.Context, id string, encrypted []byte) error
+	DeleteExpiredSigningKeys(ctx context.Context, now time.Time) (int, error)
+}
+
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	private   crypto.Signer
+	public    crypto.PublicKey
+	createdAt time.Time
+	retired   bool
+}
+
+// KeyRing holds the signing keys shared by all replicas through Firestore. The
+// newest active key signs; every unexpired key verifies.
+type KeyRing struct {
+	repo    signingKeyStore
+	options KeyRingOptions
+	aead    cipher.AEAD
+
+	mu       sync.RWMutex
+	keys     map[string]*signingKey
+	current  *signingKey
+	loadedAt time.Time
+}
+
+func NewKeyRing(repo *repository.SigningKeyRepo, options KeyRingOptions) (*KeyRing, error) {
+	return newKeyRing(repo, options)
+}
+
+func newKeyRing(repo signingKeyStore, options KeyRingOptions) (*KeyRing, error) {
+	if options.Algorithm != "RS256" && options.Algorithm != "EdDSA" {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", options.Algorithm)
+	}
+	if len(options.EncryptionKey) != 32 {
+		return nil, errors.New("a 32-byte key encryption key is required for asymmetric signing")
+	}
+	block, err := aes.NewCipher(options.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyRing{
+		repo:    repo,
+		options: options,
+		aead:    aead,
+		keys:    make(map[string]*signingKey),
+	}, nil
+}
+
+// Start rotates and reloads the keys every interval until ctx is cancelled.
+func (k *KeyRing) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := k.Refresh(ctx); err != nil {
+			log.Printf("Failed to refresh signing keys: %v", err)
+		}
+	}
+}
+
+// Refresh rotates the signing key when it is due, purges expired keys and
+// reloads the ring. It is also called once at startup.
+func (k *KeyRing) Refresh(ctx context.Context) error {
+	if err := k.rotateIfDue(ctx); err != nil {
+		return err
+	}
+	if n, err := k.repo.DeleteExpiredSigningKeys(ctx, time.Now()); err != nil {
+		log.Printf("Failed to delete expired signing keys: %v", err)
+	} else if n > 0 {
+		log.Printf("🔑 Deleted %d expired signing keys", n)
+	}
+	return k.load(ctx)
+}
+
+func (k *KeyRing) rotateIfDue(ctx context.Context) error {
+	k.mu.RLock()
+	current := k.current
+	k.mu.RUnlock()
+
+	if current != nil && time.Since(current.createdAt) < k.options.RotationInterval {
+		return nil
+	}
+
+	key, err := k.generateSigningKey()
+	if err != nil {
+		return err
+	}
+	rotated, err := k.repo.RotateSigningKey(ctx, *key, time.Now().Add(-k.options.RotationInterval), k.options.GracePeriod)
+	if err != nil {
+		return err
+	}
+	if rotated {
+		log.Printf("🔑 Rotated token signing key, new kid %s", key.ID)
+	}
+	return nil
+}
+
+func (k *KeyRing) load(ctx context.Context) error {
+	stored, err := k.repo.GetSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	keys := make(map[string]*signingKey, len(stored))
+	var current *signingKey
+	for _, s := range stored {
+		if s.ExpiresAt != nil && now.After(*s.ExpiresAt) {
+			continue
+		}
+		key, err := k.openSigningKey(ctx, s)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", s.ID, err)
+			continue
+		}
+		keys[key.id] = key
+		if !key.retired && key.method.Alg() == k.options.Algorithm && (current == nil || key.createdAt.After(current.createdAt)) {
+			current = key
+		}
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.current = current
+	k.loadedAt = now
+	k.mu.Unlock()
+	return nil
+}
+
+// Sign signs the claims with the current key and sets the kid header.
+func (k *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	k.mu.RLock()
+	current := k.current
+	k.mu.RUnlock()
+
+	if current == nil {
+		return "", errors.New("no active signing key")
+	}
+
+	token := jwt.NewWithClaims(current.method, claims)
+	token.Header["kid"] = current.id
+	return token.SignedString(current.private)
+}
+
+// Keyfunc returns the public key for the token's kid, reloading the ring once if
+// the kid is unknown because another replica has just rotated.
+func (k *KeyRing) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, errors.New("token has no kid")
+	}
+
+	key := k.lookup(kid)
+	if key == nil {
+		k.mu.RLock()
+		stale := time.Since(k.loadedAt) > keyRingReloadInterval
+		k.mu.RUnlock()
+		if stale {
+			if err := k.load(context.Background()); err != nil {
+				return nil, err
+			}
+			key = k.lookup(kid)
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+	}
+	return key.public, nil
+}
+
+func (k *KeyRing) lookup(kid string) *signingKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys[kid]
+}
+
+// JWKS returns the public keys in JSON Web Key Set form.
+func (k *KeyRing) JWKS() map[string]interface{} {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	keys := make([]map[string]string, 0, len(k.keys))
+	for _, key := range k.keys {
+		jwk := map[string]string{
+			"kid": key.id,
+			"alg": key.method.Alg(),
+			"use": "sig",
+		}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk["kty"] = "RSA"
+			jwk["n"] = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk["e"] = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk["kty"] = "OKP"
+			jwk["crv"] = "Ed25519"
+			jwk["x"] = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		keys = append(keys, jwk)
+	}
+	return map[string]interface{}{"keys": keys}
+}
+
+// generateSigningKey creates a key for the configured algorithm, encrypted for
+// storage.
+func (k *KeyRing) generateSigningKey() (*repository.SigningKey, error) {
+	var private crypto.Signer
+	var err error
+	switch k.options.Algorithm {
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "EdDSA":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", k.options.Algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+
+	key := &repository.SigningKey{
+		ID:        hex.EncodeToString(id),
+		Algorithm: k.options.Algorithm,
+		CreatedAt: time.Now(),
+	}
+	if key.EncryptedPrivateKey, err = k.seal(key, der); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// openSigningKey decrypts a stored key. Keys stored in plaintext by earlier
+// versions are encrypted in place.
+func (k *KeyRing) openSigningKey(ctx context.Context, stored repository.SigningKey) (*signingKey, error) {
+	der := stored.PrivateKey
+	if len(stored.EncryptedPrivateKey) > 0 {
+		var err error
+		if der, err = k.open(&stored); err != nil {
+			return nil, err
+		}
+	} else if len(der) > 0 {
+		encrypted, err := k.seal(&stored, der)
+		if err != nil {
+			return nil, err
+		}
+		if err := k.repo.SealSigningKey(ctx, stored.ID, encrypted); err != nil {
+			log.Printf("Failed to encrypt signing key %s: %v", stored.ID, err)
+		} else {
+			log.Printf("🔑 Encrypted plaintext signing key %s", stored.ID)
+		}
+	}
+	return parseSigningKey(stored, der)
+}
+
+// seal encrypts a private key with AES-GCM. The key ID and algorithm are
+// authenticated, so a ciphertext cannot be moved to another key document.
+func (k *KeyRing) seal(key *repository.SigningKey, der []byte) ([]byte, error) {
+	nonce := make([]byte, k.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return k.aead.Seal(nonce, nonce, der, signingKeyAAD(key)), nil
+}
+
+func (k *KeyRing) open(key *repository.SigningKey) ([]byte, error) {
+	size := k.aead.NonceSize()
+	if len(key.EncryptedPrivateKey) < size {
+		return nil, errors.New("encrypted key is too short")
+	}
+	nonce, ciphertext := key.EncryptedPrivateKey[:size], key.EncryptedPrivateKey[size:]
+	der, err := k.aead.Open(nil, nonce, ciphertext, signingKeyAAD(key))
+	if err != nil {
+		return nil, errors.New("failed to decrypt key: wrong key encryption key or corrupted data")
+	}
+	return der, nil
+}
+
+func signingKeyAAD(key *repository.SigningKey) []byte {
+	return []byte("flare-signing-key:" + key.ID + ":" + key.Algorithm)
+}
+
+func parseSigningKey(stored repository.SigningKey, der []byte) (*signingKey, error) {
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("key cannot sign")
+	}
+
+	method := jwt.GetSigningMethod(stored.Algorithm)
+	if method == nil {
+		return nil, fmt.Errorf("unknown algorithm %q", stored.Algorithm)
+	}
+
+	return &signingKey{
+		id:        stored.ID,
+		method:    method,
+		private:   private,
+		public:    private.Public(),
+		createdAt: stored.CreatedAt,
+		retired:   stored.RetiredAt != nil,
+	}, nil
+}
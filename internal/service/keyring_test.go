@@ -0,0 +1,283 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"Flare-server/internal/repository"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// memorySigningKeyStore follows the rotation rules of repository.SigningKeyRepo.
+type memorySigningKeyStore struct {
+	mu   sync.Mutex
+	keys []repository.SigningKey
+}
+
+func (m *memorySigningKeyStore) GetSigningKeys(ctx context.Context) ([]repository.SigningKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]repository.SigningKey(nil), m.keys...), nil
+}
+
+func (m *memorySigningKeyStore) RotateSigningKey(ctx context.Context, newKey repository.SigningKey, rotateBefore time.Time, gracePeriod time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range m.keys {
+		if key.RetiredAt == nil && key.Algorithm == newKey.Algorithm && key.CreatedAt.After(rotateBefore) {
+			return false, nil
+		}
+	}
+	now := time.Now()
+	expiresAt := now.Add(gracePeriod)
+	for i := range m.keys {
+		if m.keys[i].RetiredAt == nil {
+			m.keys[i].RetiredAt = &now
+			m.keys[i].ExpiresAt = &expiresAt
+		}
+	}
+	m.keys = append(m.keys, newKey)
+	return true, nil
+}
+
+func (m *memorySigningKeyStore) SealSigningKey(ctx context.Context, id string, encrypted []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.keys {
+		if m.keys[i].ID == id {
+			m.keys[i].EncryptedPrivateKey = encrypted
+			m.keys[i].PrivateKey = nil
+		}
+	}
+	return nil
+}
+
+func (m *memorySigningKeyStore) DeleteExpiredSigningKeys(ctx context.Context, now time.Time) (int, error) {
+	return 0, nil
+}
+
+func (m *memorySigningKeyStore) stored() []repository.SigningKey {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]repository.SigningKey(nil), m.keys...)
+}
+
+func testKeyEncryptionKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return key
+}
+
+func newTestKeyRing(t *testing.T, store *memorySigningKeyStore, algorithm string, encryptionKey []byte) *KeyRing {
+	t.Helper()
+	keyRing, err := newKeyRing(store, KeyRingOptions{
+		Algorithm:        algorithm,
+		RotationInterval: 24 * time.Hour,
+		GracePeriod:      48 * time.Hour,
+		EncryptionKey:    encryptionKey,
+	})
+	if err != nil {
+		t.Fatalf("newKeyRing failed: %v", err)
+	}
+	if err := keyRing.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	return keyRing
+}
+
+func newKeyRingAuthService(keyRing *KeyRing) *AuthService {
+	s := &AuthService{
+		tokenOptions: TokenOptions{Issuer: "flare", Audience: "flare-api", TTL: time.Hour},
+		JWTKey:       []byte("hs256-secret"),
+	}
+	s.SetKeyRing(keyRing)
+	return s
+}
+
+func TestKeyRingIssuesVerifiableTokens(t *testing.T) {
+	for _, algorithm := range []string{"RS256", "EdDSA"} {
+		t.Run(algorithm, func(t *testing.T) {
+			store := &memorySigningKeyStore{}
+			keyRing := newTestKeyRing(t, store, algorithm, testKeyEncryptionKey(t))
+			s := newKeyRingAuthService(keyRing)
+
+			token, err := s.IssueToken(&repository.User{ID: "alice-id", Username: "alice", TokenVersion: 3})
+			if err != nil {
+				t.Fatalf("IssueToken failed: %v", err)
+			}
+			claims, err := s.parseAccessToken(token)
+			if err != nil {
+				t.Fatalf("parseAccessToken failed: %v", err)
+			}
+			if claims.UserID != "alice-id" || claims.Version != 3 {
+				t.Errorf("unexpected claims: %+v", claims)
+			}
+
+			parsed, _, _ := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+			if parsed.Header["alg"] != algorithm || parsed.Header["kid"] != store.stored()[0].ID {
+				t.Errorf("unexpected header: %v", parsed.Header)
+			}
+
+			jwks := keyRing.JWKS()["keys"].([]map[string]string)
+			if len(jwks) != 1 || jwks[0]["kid"] != store.stored()[0].ID || jwks[0]["alg"] != algorithm {
+				t.Errorf("unexpected JWKS: %v", jwks)
+			}
+			for _, private := range []string{"d", "p", "q"} {
+				if _, ok := jwks[0][private]; ok {
+					t.Errorf("JWKS exposes private parameter %q", private)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyRingRejectsOtherSigningMethods(t *testing.T) {
+	s := newKeyRingAuthService(newTestKeyRing(t, &memorySigningKeyStore{}, "RS256", testKeyEncryptionKey(t)))
+	claims, _ := s.newAccessClaims(&repository.User{ID: "alice-id", Username: "alice"})
+
+	hs256, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.JWTKey)
+	if _, err := s.parseAccessToken(hs256); err == nil {
+		t.Error("an HS256 token was accepted while asymmetric signing is enabled")
+	}
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if _, err := s.parseAccessToken(none); err == nil {
+		t.Error("an unsigned token was accepted")
+	}
+
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	forged := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	forged.Header["kid"] = "unknown"
+	signed, _ := forged.SignedString(otherKey)
+	if _, err := s.parseAccessToken(signed); err == nil {
+		t.Error("a token signed with an unknown key was accepted")
+	}
+}
+
+func TestKeyRingStoresPrivateKeysEncrypted(t *testing.T) {
+	store := &memorySigningKeyStore{}
+	encryptionKey := testKeyEncryptionKey(t)
+	keyRing := newTestKeyRing(t, store, "RS256", encryptionKey)
+
+	stored := store.stored()
+	if len(stored) != 1 {
+		t.Fatalf("expected 1 stored key, got %d", len(stored))
+	}
+	if len(stored[0].PrivateKey) != 0 || len(stored[0].EncryptedPrivateKey) == 0 {
+		t.Fatal("private key was not stored encrypted")
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(keyRing.current.private)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	if bytes.Contains(stored[0].EncryptedPrivateKey, der[len(der)-64:]) {
+		t.Error("stored key contains plaintext key material")
+	}
+
+	// A replica with another key encryption key cannot use the key.
+	other, err := newKeyRing(store, KeyRingOptions{Algorithm: "RS256", RotationInterval: 24 * time.Hour, EncryptionKey: testKeyEncryptionKey(t)})
+	if err != nil {
+		t.Fatalf("newKeyRing failed: %v", err)
+	}
+	if err := other.load(context.Background()); err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if other.current != nil || len(other.keys) != 0 {
+		t.Error("a key was decrypted with the wrong key encryption key")
+	}
+
+	// Nor can the ciphertext be moved to another key document.
+	moved := stored[0]
+	moved.ID = "other-id"
+	if _, err := keyRing.open(&moved); err == nil {
+		t.Error("a ciphertext was accepted for another key ID")
+	}
+}
+
+func TestKeyRingEncryptsPlaintextKeys(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(private)
+	store := &memorySigningKeyStore{keys: []repository.SigningKey{{
+		ID:         "legacy",
+		Algorithm:  "EdDSA",
+		PrivateKey: der,
+		CreatedAt:  time.Now().Add(-time.Hour),
+	}}}
+
+	// A token signed before the upgrade stays valid.
+	legacy := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"sub": "alice-id"})
+	legacy.Header["kid"] = "legacy"
+	signed, _ := legacy.SignedString(private)
+
+	keyRing := newTestKeyRing(t, store, "EdDSA", testKeyEncryptionKey(t))
+
+	stored := store.stored()
+	if len(stored) != 1 || len(stored[0].PrivateKey) != 0 || len(stored[0].EncryptedPrivateKey) == 0 {
+		t.Fatalf("plaintext key was not encrypted: %+v", stored)
+	}
+	if keyRing.current == nil || keyRing.current.id != "legacy" {
+		t.Fatal("the encrypted key should keep signing until it is due for rotation")
+	}
+	if _, err := jwt.Parse(signed, keyRing.Keyfunc, jwt.WithValidMethods([]string{"EdDSA"})); err != nil {
+		t.Errorf("token signed with the legacy key was rejected: %v", err)
+	}
+
+	// The sealed key loads from its ciphertext.
+	if err := keyRing.load(context.Background()); err != nil || keyRing.lookup("legacy") == nil {
+		t.Errorf("sealed key did not load: %v", err)
+	}
+}
+
+func TestKeyRingRotationKeepsRetiredKeysVerifying(t *testing.T) {
+	store := &memorySigningKeyStore{}
+	keyRing := newTestKeyRing(t, store, "RS256", testKeyEncryptionKey(t))
+	s := newKeyRingAuthService(keyRing)
+
+	oldToken, err := s.IssueToken(&repository.User{ID: "alice-id", Username: "alice"})
+	if err != nil {
+		t.Fatalf("IssueToken failed: %v", err)
+	}
+	oldKID := keyRing.current.id
+
+	// Make the key due for rotation.
+	store.mu.Lock()
+	store.keys[0].CreatedAt = time.Now().Add(-25 * time.Hour)
+	store.mu.Unlock()
+	keyRing.current.createdAt = time.Now().Add(-25 * time.Hour)
+	if err := keyRing.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+
+	if keyRing.current == nil || keyRing.current.id == oldKID {
+		t.Fatal("signing key was not rotated")
+	}
+	if len(store.stored()) != 2 || store.stored()[0].RetiredAt == nil {
+		t.Fatal("previous key was not retired")
+	}
+	if _, err := s.parseAccessToken(oldToken); err != nil {
+		t.Errorf("token of the retired key was rejected: %v", err)
+	}
+	newToken, _ := s.IssueToken(&repository.User{ID: "alice-id", Username: "alice"})
+	if parsed, _, _ := jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{}); parsed.Header["kid"] != keyRing.current.id {
+		t.Error("new tokens are not signed with the new key")
+	}
+}
+
+func TestNewKeyRingRequiresEncryptionKey(t *testing.T) {
+	for _, key := range [][]byte{nil, make([]byte, 16)} {
+		_, err := newKeyRing(&memorySigningKeyStore{}, KeyRingOptions{Algorithm: "RS256", EncryptionKey: key})
+		if err == nil || !strings.Contains(err.Error(), "key encryption key") {
+			t.Errorf("expected a key encryption key error for a %d-byte key, got %v", len(key), err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"Flare-server/internal/ratelimit"
+	"Flare-server/internal/repository"
+)
+
+const maxLoginDelay = 30 * time.Second
+
+// LoginProtection configures brute-force protection for Login. Failures are counted
+// per username and per client IP; a key is locked for LockoutDuration once it
+// reaches its threshold, and failures older than LockoutDuration are forgotten.
+type LoginProtection struct {
+	MaxFailures     int
+	IPMaxFailures   int
+	LockoutDuration time.Duration
+}
+
+func loginAttemptsUserKey(username string) string {
+	return "user:" + strings.ToLower(strings.TrimSpace(username))
+}
+
+func loginAttemptsIPKey(ip string) string {
+	return "ip:" + ip
+}
+
+// loginDelay is the wait enforced after the given number of consecutive failures:
+// nothing after the first, then 1s, 2s, 4s... up to maxLoginDelay.
+func loginDelay(failures int) time.Duration {
+	if failures < 2 {
+		return 0
+	}
+	if failures > 7 {
+		return maxLoginDelay
+	}
+	delay := time.Second << (failures - 2)
+	if delay > maxLoginDelay {
+		return maxLoginDelay
+	}
+	return delay
+}
+
+// checkLoginAllowed rejects the attempt without checking the password while a key
+// is locked or still inside its progressive delay.
+func (s *AuthService) checkLoginAllowed(ctx context.Context, keys ...string) error {
+	now := time.Now()
+	for _, key := range keys {
+		attempts, err := s.userRepo.GetLoginAttempts(ctx, key)
+		if err != nil {
+			log.Printf("Failed to check login attempts for %s: %v", key, err)
+			continue
+		}
+
+		if attempts.LockedUntil != nil && attempts.LockedUntil.After(now) {
+			return fmt.Errorf("too many failed login attempts, try again later: %w",
+				&ratelimit.LimitError{RetryAfter: attempts.LockedUntil.Sub(now)})
+		}
+
+		if now.Sub(attempts.LastFailureAt) < s.loginProtection.LockoutDuration {
+			if next := attempts.LastFailureAt.Add(loginDelay(attempts.Failures)); next.After(now) {
+				return fmt.Errorf("too many failed login attempts: %w", &ratelimit.LimitError{RetryAfter: next.Sub(now)})
+			}
+		}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failure against key and reports the lockout end if
+// this failure locked it.
+func (s *AuthService) recordLoginFailure(ctx context.Context, key string, maxFailures int) *time.Time {
+	if maxFailures <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var lockedUntil *time.Time
+	_, err := s.userRepo.UpdateLoginAttempts(ctx, key, func(attempts *repository.LoginAttempts) error {
+		lockedUntil = nil
+		if now.Sub(attempts.LastFailureAt) >= s.loginProtection.LockoutDuration {
+			attempts.Failures = 0
+		}
+
+		attempts.Failures++
+		attempts.LastFailureAt = now
+
+		if attempts.Failures >= maxFailures {
+			until := now.Add(s.loginProtection.LockoutDuration)
+			attempts.LockedUntil = &until
+			attempts.Failures = 0
+			lockedUntil = &until
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to record login failure for %s: %v", key, err)
+		return nil
+	}
+	return lockedUntil
+}
+
+func (s *AuthService) handleLoginFailure(ctx context.Context, username, ip string, user *repository.User) {
+	if until := s.recordLoginFailure(ctx, loginAttemptsIPKey(ip), s.loginProtection.IPMaxFailures); until != nil {
+		log.Printf("🔒 Login from %s locked until %s after repeated failures", ip, until.Format(time.RFC3339))
+	}
+
+	until := s.recordLoginFailure(ctx, loginAttemptsUserKey(username), s.loginProtection.MaxFailures)
+	if until == nil {
+		return
+	}
+
+	log.Printf("🔒 Login for %q locked until %s after repeated failures", username, until.Format(time.RFC3339))
+	if user != nil && s.broadcaster != nil {
+		s.broadcaster.SendToUser(user.ID, "account_locked", map[string]interface{}{
+			"until": until,
+			"ip":    ip,
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"Flare-server/internal/repository"
+)
+
+const reaperBatchSize = 200
+
+type MessageReaper struct {
+	chatRepo    *repository.ChatRepo
+	broadcaster Broadcaster
+}
+
+func NewMessageReaper(chatRepo *repository.ChatRepo, broadcaster Broadcaster) *MessageReaper {
+	return &MessageReaper{
+		chatRepo:    chatRepo,
+		broadcaster: broadcaster,
+	}
+}
+
+// Start deletes expired messages every interval until ctx is cancelled. Readers
+// already hide expired messages, so the reaper only has to catch up eventually.
+func (r *MessageReaper) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.reap(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *MessageReaper) reap(ctx context.Context) {
+	for ctx.Err() == nil {
+		deleted, err := r.chatRepo.DeleteExpiredMessages(ctx, time.Now(), reaperBatchSize)
+		if err != nil {
+			log.Printf("Failed to delete expired messages: %v", err)
+			return
+		}
+
+		for _, message := range deleted {
+			r.broadcaster.BroadcastMessage(message.ChatID, "message_deleted", map[string]interface{}{
+				"messageId": message.ID,
+				"chatId":    message.ChatID,
+			})
+		}
+
+		if len(deleted) < reaperBatchSize {
+			return
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/repository"
+
+	"cloud.google.com/go/firestore"
+)
+
+const (
+	maxReportReasonLength  = 200
+	maxReportDetailsLength = 2000
+	maxSuspension          = 10 * 365 * 24 * time.Hour
+)
+
+type ModerationService struct {
+	moderationRepo *repository.ModerationRepo
+	userRepo       *repository.UserRepo
+	chatRepo       *repository.ChatRepo
+	broadcaster    Broadcaster
+}
+
+func NewModerationService(moderationRepo *repository.ModerationRepo, userRepo *repository.UserRepo, chatRepo *repository.ChatRepo, broadcaster Broadcaster) *ModerationService {
+	return &ModerationService{
+		moderationRepo: moderationRepo,
+		userRepo:       userRepo,
+		chatRepo:       chatRepo,
+		broadcaster:    broadcaster,
+	}
+}
+
+// CreateReport files a report. Reporters can only report messages and chats they
+// can see, so a report cannot be used to probe other chats.
+func (s *ModerationService) CreateReport(ctx context.Context, reporterID string, req models.CreateReportRequest) (*models.Report, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, fmt.Errorf("report reason is required")
+	}
+	if len([]rune(reason)) > maxReportReasonLength {
+		return nil, fmt.Errorf("report reason cannot be longer than %d characters", maxReportReasonLength)
+	}
+	details := strings.TrimSpace(req.Details)
+	if len([]rune(details)) > maxReportDetailsLength {
+		return nil, fmt.Errorf("report details cannot be longer than %d characters", maxReportDetailsLength)
+	}
+
+	report := models.Report{
+		ReporterID: reporterID,
+		TargetType: req.TargetType,
+		TargetID:   req.TargetID,
+		Reason:     reason,
+		Details:    details,
+	}
+
+	switch req.TargetType {
+	case models.ReportTargetMessage:
+		message, err := s.chatRepo.GetMessage(ctx, req.TargetID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.requireMember(ctx, message.ChatID, reporterID); err != nil {
+			return nil, err
+		}
+		report.ChatID = message.ChatID
+	case models.ReportTargetChat:
+		if err := s.requireMember(ctx, req.TargetID, reporterID); err != nil {
+			return nil, err
+		}
+		report.ChatID = req.TargetID
+	case models.ReportTargetUser:
+		if req.TargetID == reporterID {
+			return nil, fmt.Errorf("cannot report yourself")
+		}
+		if _, err := s.userRepo.GetUserByID(ctx, req.TargetID); err != nil {
+			return nil, fmt.Errorf("user not found")
+		}
+	default:
+		return nil, fmt.Errorf("targetType must be message, user or chat")
+	}
+
+	return s.moderationRepo.CreateReport(ctx, report)
+}
+
+func (s *ModerationService) GetReports(ctx context.Context, moderatorID string, status models.ReportStatus, limit int) (*models.ReportListResponse, error) {
+	if _, err := s.requireModerator(ctx, moderatorID); err != nil {
+		return nil, err
+	}
+
+	if status == "" {
+		status = models.ReportStatusOpen
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	reports, err := s.moderationRepo.GetReports(ctx, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ReportListResponse{Reports: reports}, nil
+}
+
+func (s *ModerationService) GetAuditLog(ctx context.Context, moderatorID, targetID string, limit int) (*models.ModerationAuditResponse, error) {
+	if _, err := s.requireModerator(ctx, moderatorID); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	actions, err := s.moderationRepo.GetAuditLog(ctx, targetID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ModerationAuditResponse{Actions: actions}, nil
+}
+
+// TakeAction checks a moderation action, records it in the audit trail, applies
+// it and resolves the related report if one is given. An action is never applied
+// without its audit record; if applying fails the record is discarded again.
+func (s *ModerationService) TakeAction(ctx context.Context, moderatorID string, req models.ModerationActionRequest) (*models.ModerationAction, error) {
+	moderator, err := s.requireModerator(ctx, moderatorID)
+	if err != nil {
+		return nil, err
+	}
+
+	action := models.ModerationAction{
+		ModeratorID: moderatorID,
+		Action:      req.Action,
+		TargetID:    req.TargetID,
+		ChatID:      req.ChatID,
+		ReportID:    req.ReportID,
+		Reason:      strings.TrimSpace(req.Reason),
+	}
+
+	if req.TargetID == "" && req.Action != models.ModerationDismissReport {
+		return nil, fmt.Errorf("targetId is required")
+	}
+
+	// Each helper checks the action, fills in its audit fields and returns the
+	// function that applies it.
+	var apply func() error
+	switch req.Action {
+	case models.ModerationDeleteMessage:
+		apply, err = s.deleteMessage(ctx, &action)
+	case models.ModerationSuspendUser:
+		apply, err = s.suspendUser(ctx, moderator, req.Duration, &action)
+	case models.ModerationUnsuspendUser:
+		apply, err = s.unsuspendUser(ctx, moderator, &action)
+	case models.ModerationDeleteChat:
+		apply, err = s.deleteChat(ctx, &action)
+	case models.ModerationSetRole:
+		apply, err = s.setRole(ctx, moderator, req.Role, &action)
+	case models.ModerationUnlockAccount:
+		apply, err = s.unlockAccount(ctx, &action)
+	case models.ModerationDismissReport:
+		apply, err = s.dismissReport(ctx, &action)
+	default:
+		return nil, fmt.Errorf("unknown moderation action %q", req.Action)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	logged, err := s.moderationRepo.LogAction(ctx, action)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := apply(); err != nil {
+		if discardErr := s.moderationRepo.DeleteAction(ctx, logged.ID); discardErr != nil {
+			log.Printf("❌ Failed to discard audit record %s of a failed moderation action: %v", logged.ID, discardErr)
+		}
+		return nil, err
+	}
+
+	if req.ReportID != "" && req.Action != models.ModerationDismissReport {
+		if err := s.moderationRepo.ResolveReport(ctx, req.ReportID, models.ReportStatusResolved, moderatorID); err != nil {
+			log.Printf("Failed to resolve report %s: %v", req.ReportID, err)
+		}
+	}
+	return logged, nil
+}
+
+func (s *ModerationService) deleteMessage(ctx context.Context, action *models.ModerationAction) (func() error, error) {
+	message, err := s.chatRepo.GetMessage(ctx, action.TargetID)
+	if err != nil {
+		return nil, err
+	}
+
+	action.ChatID = message.ChatID
+	return func() error {
+		if err := s.chatRepo.DeleteMessage(ctx, message.ID); err != nil {
+			return fmt.Errorf("failed to delete message: %w", err)
+		}
+
+		s.broadcaster.BroadcastMessage(message.ChatID, "message_deleted", map[string]interface{}{
+			"messageId": message.ID,
+			"chatId":    message.ChatID,
+		})
+		return nil
+	}, nil
+}
+
+func (s *ModerationService) suspendUser(ctx context.Context, moderator *repository.User, duration string, action *models.ModerationAction) (func() error, error) {
+	d, err := time.ParseDuration(duration)
+	if err != nil || d <= 0 || d > maxSuspension {
+		return nil, fmt.Errorf("duration must be a positive duration such as \"24h\"")
+	}
+
+	target, err := s.userRepo.GetUserByID(ctx, action.TargetID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if target.IsModerator() && moderator.GlobalRole != models.GlobalRoleAdmin {
+		return nil, fmt.Errorf("access denied: only admins can suspend moderators")
+	}
+
+	until := time.Now().Add(d)
+	action.Until = &until
+	return func() error {
+		err := s.userRepo.UpdateUser(ctx, target.ID, []firestore.Update{
+			{Path: "suspendedUntil", Value: until},
+			{Path: "suspensionReason", Value: action.Reason},
+		})
+		if err != nil {
+			return err
+		}
+
+		s.broadcaster.SendToUser(target.ID, "account_suspended", map[string]interface{}{
+			"until":  until,
+			"reason": action.Reason,
+		})
+		s.broadcaster.DisconnectUser(target.ID)
+		return nil
+	}, nil
+}
+
+// unsuspendUser lifts a suspension. Like suspensions, only admins may lift those
+// of moderators.
+func (s *ModerationService) unsuspendUser(ctx context.Context, moderator *repository.User, action *models.ModerationAction) (func() error, error) {
+	target, err := s.userRepo.GetUserByID(ctx, action.TargetID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if target.IsModerator() && moderator.GlobalRole != models.GlobalRoleAdmin {
+		return nil, fmt.Errorf("access denied: only admins can unsuspend moderators")
+	}
+
+	return func() error {
+		return s.userRepo.UpdateUser(ctx, target.ID, []firestore.Update{
+			{Path: "suspendedUntil", Value: nil},
+			{Path: "suspensionReason", Value: ""},
+		})
+	}, nil
+}
+
+func (s *ModerationService) deleteChat(ctx context.Context, action *models.ModerationAction) (func() error, error) {
+	if _, err := s.chatRepo.GetChatByID(ctx, action.TargetID); err != nil {
+		return nil, fmt.Errorf("chat not found")
+	}
+
+	action.ChatID = action.TargetID
+	return func() error {
+		if err := s.chatRepo.DeleteChat(ctx, action.TargetID); err != nil {
+			return fmt.Errorf("failed to delete chat: %w", err)
+		}
+
+		s.broadcaster.BroadcastMessage(action.TargetID, "chat_deleted", map[string]string{"chatId": action.TargetID})
+		return nil
+	}, nil
+}
+
+// unlockAccount lifts a login lockout and clears the failed attempts counted
+// against the username. Lockouts per client IP are left to expire.
+func (s *ModerationService) unlockAccount(ctx context.Context, action *models.ModerationAction) (func() error, error) {
+	target, err := s.userRepo.GetUserByID(ctx, action.TargetID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+
+	return func() error {
+		if err := s.userRepo.ResetLoginAttempts(ctx, loginAttemptsUserKey(target.Username)); err != nil {
+			return fmt.Errorf("failed to unlock account: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// setRole grants or revokes a global role. Only admins may do this.
+func (s *ModerationService) setRole(ctx context.Context, moderator *repository.User, role models.GlobalRole, action *models.ModerationAction) (func() error, error) {
+	if moderator.GlobalRole != models.GlobalRoleAdmin {
+		return nil, fmt.Errorf("access denied: only admins can change global roles")
+	}
+	if role != models.GlobalRoleUser && role != models.GlobalRoleModerator && role != models.GlobalRoleAdmin {
+		return nil, fmt.Errorf("invalid global role %q", role)
+	}
+	if action.TargetID == moderator.ID {
+		return nil, fmt.Errorf("cannot change your own global role")
+	}
+
+	action.Role = role
+	return func() error {
+		return s.userRepo.UpdateUser(ctx, action.TargetID, []firestore.Update{{Path: "globalRole", Value: role}})
+	}, nil
+}
+
+// dismissReport closes a report without acting on its target.
+func (s *ModerationService) dismissReport(ctx context.Context, action *models.ModerationAction) (func() error, error) {
+	if action.ReportID == "" {
+		return nil, fmt.Errorf("reportId is required")
+	}
+
+	return func() error {
+		return s.moderationRepo.ResolveReport(ctx, action.ReportID, models.ReportStatusDismissed, action.ModeratorID)
+	}, nil
+}
+
+func (s *ModerationService) requireModerator(ctx context.Context, userID string) (*repository.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if !user.IsModerator() {
+		return nil, fmt.Errorf("access denied: moderator role required")
+	}
+	return user, nil
+}
+
+func (s *ModerationService) requireMember(ctx context.Context, chatID, userID string) error {
+	isMember, err := s.chatRepo.IsUserInChat(ctx, chatID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check chat membership: %w", err)
+	}
+	if !isMember {
+		return fmt.Errorf("access denied: user is not a member of this chat")
+	}
+	return nil
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"Flare-server/internal/repository"
+
+	"github.com/MicahParks/keyfunc"
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/oauth2"
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+// ID tokens must be signed asymmetrically; "none" and HMAC are never accepted.
+var oidcSigningMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+var ErrUnknownOIDCProvider = errors.New("unknown OIDC provider")
+
+type OIDCProviderConfig struct {
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// oidcProvider discovers its endpoints and keys on first use, so the server still
+// starts when a provider is unreachable.
+type oidcProvider struct {
+	cfg OIDCProviderConfig
+
+	mu    sync.Mutex
+	oauth *oauth2.Config
+	jwks  *keyfunc.JWKS
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcClaims struct {
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	Name              string `json:"name"`
+	Nonce             string `json:"nonce"`
+	jwt.RegisteredClaims
+}
+
+type OIDCService struct {
+	userRepo    *repository.UserRepo
+	authService *AuthService
+	httpClient  *http.Client
+	providers   map[string]*oidcProvider
+}
+
+func NewOIDCService(userRepo *repository.UserRepo, authService *AuthService, providers []OIDCProviderConfig) *OIDCService {
+	s := &OIDCService{
+		userRepo:    userRepo,
+		authService: authService,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+		providers:   make(map[string]*oidcProvider),
+	}
+	for _, cfg := range providers {
+		if len(cfg.Scopes) == 0 {
+			cfg.Scopes = []string{"openid", "profile", "email"}
+		}
+		s.providers[cfg.Name] = &oidcProvider{cfg: cfg}
+	}
+	return s
+}
+
+func (s *OIDCService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OIDCCallbackResult is the outcome of a callback. A login yields a token or, for
+// users with 2FA, a challenge; a link flow only reports the linked user.
+type OIDCCallbackResult struct {
+	LoginResult
+	LinkedUserID string
+}
+
+// AuthCodeURL starts an authorization code flow with PKCE and returns the URL the
+// browser should be sent to.
+func (s *OIDCService) AuthCodeURL(ctx context.Context, providerName string) (string, error) {
+	return s.authCodeURL(ctx, providerName, "")
+}
+
+// LinkURL starts a flow that links the provider identity to the signed-in user.
+// This is the only way to attach an identity to an existing account.
+func (s *OIDCService) LinkURL(ctx context.Context, providerName, userID string) (string, error) {
+	return s.authCodeURL(ctx, providerName, userID)
+}
+
+func (s *OIDCService) authCodeURL(ctx context.Context, providerName, linkUserID string) (string, error) {
+	provider, err := s.provider(ctx, providerName)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	err = s.userRepo.SaveOIDCState(ctx, state, repository.OIDCState{
+		Provider:   providerName,
+		Verifier:   verifier,
+		Nonce:      nonce,
+		ExpiresAt:  time.Now().Add(oidcStateTTL),
+		LinkUserID: linkUserID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return provider.oauthURL(state, verifier, nonce), nil
+}
+
+func (p *oidcProvider) oauthURL(state, verifier, nonce string) string {
+	return p.oauth.AuthCodeURL(state,
+		oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("nonce", nonce),
+	)
+}
+
+// HandleCallback finishes the flow: it exchanges the code and verifies the ID
+// token against the provider's JWKS. A login continues like a password login, so
+// users with 2FA get a challenge instead of a token.
+func (s *OIDCService) HandleCallback(ctx context.Context, providerName, code, state string) (*OIDCCallbackResult, error) {
+	provider, err := s.provider(ctx, providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	saved, err := s.userRepo.ConsumeOIDCState(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	if saved.Provider != providerName {
+		return nil, errors.New("invalid login state")
+	}
+
+	claims, err := provider.exchange(ctx, s.httpClient, code, saved.Verifier, saved.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	identity := ExternalIdentity{
+		Provider:          "oidc:" + providerName,
+		Subject:           claims.Subject,
+		Email:             claims.Email,
+		EmailVerified:     claims.EmailVerified,
+		PreferredUsername: claims.PreferredUsername,
+		DisplayName:       claims.Name,
+	}
+
+	if saved.LinkUserID != "" {
+		if err := s.authService.LinkExternalIdentity(ctx, saved.LinkUserID, identity); err != nil {
+			return nil, err
+		}
+		return &OIDCCallbackResult{LinkedUserID: saved.LinkUserID}, nil
+	}
+
+	user, err := s.authService.ResolveExternalUser(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNotSuspended(user); err != nil {
+		return nil, err
+	}
+
+	result, err := s.authService.beginLogin(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCCallbackResult{LoginResult: *result}, nil
+}
+
+func (s *OIDCService) provider(ctx context.Context, name string) (*oidcProvider, error) {
+	provider, ok := s.providers[name]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+	if err := provider.discover(ctx, s.httpClient); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+func (p *oidcProvider) discover(ctx context.Context, client *http.Client) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth != nil {
+		return nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to discover OIDC provider %s: %w", p.cfg.Name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to discover OIDC provider %s: status %d", p.cfg.Name, resp.StatusCode)
+	}
+
+	var discovery oidcDiscovery
+	if err := json.NewDecoder(resp.Body).Decode(&discovery); err != nil {
+		return fmt.Errorf("invalid discovery document from %s: %w", p.cfg.Name, err)
+	}
+	if discovery.Issuer != p.cfg.Issuer {
+		return fmt.Errorf("OIDC provider %s reports issuer %q, expected %q", p.cfg.Name, discovery.Issuer, p.cfg.Issuer)
+	}
+
+	jwks, err := keyfunc.Get(discovery.JWKSURI, keyfunc.Options{
+		Client:            client,
+		RefreshInterval:   time.Hour,
+		RefreshRateLimit:  time.Minute,
+		RefreshUnknownKID: true,
+		RefreshErrorHandler: func(err error) {
+			log.Printf("Failed to refresh JWKS for %s: %v", p.cfg.Name, err)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to load JWKS for %s: %w", p.cfg.Name, err)
+	}
+
+	p.jwks = jwks
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       p.cfg.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  discovery.AuthorizationEndpoint,
+			TokenURL: discovery.TokenEndpoint,
+		},
+	}
+	return nil
+}
+
+// exchange redeems the authorization code with the PKCE verifier and returns the
+// verified claims of the ID token.
+func (p *oidcProvider) exchange(ctx context.Context, client *http.Client, code, verifier, nonce string) (*oidcClaims, error) {
+	token, err := p.oauth.Exchange(context.WithValue(ctx, oauth2.HTTPClient, client), code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("provider did not return an ID token")
+	}
+	return p.verifyIDToken(rawIDToken, nonce)
+}
+
+func (p *oidcProvider) verifyIDToken(rawIDToken, nonce string) (*oidcClaims, error) {
+	var claims oidcClaims
+	parser := jwt.NewParser(jwt.WithValidMethods(oidcSigningMethods))
+	token, err := parser.ParseWithClaims(rawIDToken, &claims, p.jwks.Keyfunc)
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid ID token: %v", err)
+	}
+
+	if !claims.VerifyIssuer(p.cfg.Issuer, true) {
+		return nil, errors.New("invalid ID token: wrong issuer")
+	}
+	if !claims.VerifyAudience(p.cfg.ClientID, true) {
+		return nil, errors.New("invalid ID token: wrong audience")
+	}
+	if claims.Nonce != nonce {
+		return nil, errors.New("invalid ID token: nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid ID token: missing subject")
+	}
+	return &claims, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
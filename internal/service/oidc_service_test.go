@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// mockOIDCProvider is a local OpenID provider: discovery, JWKS and a token
+// endpoint that checks the PKCE verifier of the code it issued.
+type mockOIDCProvider struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]mockAuthorization
+	// idToken overrides the claims or signing of the issued ID token.
+	idToken func(claims jwt.MapClaims) (string, error)
+}
+
+type mockAuthorization struct {
+	challenge string
+	nonce     string
+}
+
+const (
+	mockClientID = "flare-client"
+	mockKeyID    = "test-key"
+)
+
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	p := &mockOIDCProvider{t: t, key: key, codes: make(map[string]mockAuthorization)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": mockKeyID,
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", p.handleToken)
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// authorize plays the user approving the login: it records the PKCE challenge
+// and nonce of the authorization URL and returns the code for the callback.
+func (p *mockOIDCProvider) authorize(authURL string) string {
+	p.t.Helper()
+
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		p.t.Fatalf("invalid auth URL: %v", err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		p.t.Fatalf("auth URL has no S256 PKCE challenge: %s", authURL)
+	}
+
+	code := "code-" + query.Get("state")
+	p.mu.Lock()
+	p.codes[code] = mockAuthorization{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	p.mu.Unlock()
+	return code
+}
+
+func (p *mockOIDCProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	p.mu.Lock()
+	auth, ok := p.codes[r.Form.Get("code")]
+	delete(p.codes, r.Form.Get("code"))
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != auth.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":                p.server.URL,
+		"aud":                mockClientID,
+		"sub":                "subject-1",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"preferred_username": "alice",
+		"name":               "Alice",
+		"nonce":              auth.nonce,
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Hour).Unix(),
+	}
+
+	sign := p.idToken
+	if sign == nil {
+		sign = p.sign
+	}
+	idToken, err := sign(claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func (p *mockOIDCProvider) sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = mockKeyID
+	return token.SignedString(p.key)
+}
+
+func (p *mockOIDCProvider) provider(t *testing.T) *oidcProvider {
+	t.Helper()
+
+	provider := &oidcProvider{cfg: OIDCProviderConfig{
+		Name:        "mock",
+		Issuer:      p.server.URL,
+		ClientID:    mockClientID,
+		RedirectURL: "http://flare.test/api/auth/oidc/mock/callback",
+		Scopes:      []string{"openid", "email"},
+	}}
+	if err := provider.discover(context.Background(), p.server.Client()); err != nil {
+		t.Fatalf("discovery failed: %v", err)
+	}
+	t.Cleanup(provider.jwks.EndBackground)
+	return provider
+}
+
+// login runs the flow the way OIDCService does and returns the exchange result.
+func (p *mockOIDCProvider) login(t *testing.T, provider *oidcProvider, tamperVerifier bool) (*oidcClaims, error) {
+	t.Helper()
+
+	verifier, nonce := "verifier-0123456789-0123456789-0123456789-0123", "nonce-1"
+	authURL := provider.oauthURL("state-1", verifier, nonce)
+	code := p.authorize(authURL)
+	if tamperVerifier {
+		verifier += "x"
+	}
+	return provider.exchange(context.Background(), p.server.Client(), code, verifier, nonce)
+}
+
+func TestOIDCLoginWithMockProvider(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	provider := mock.provider(t)
+
+	claims, err := mock.login(t, provider, false)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if claims.Subject != "subject-1" || claims.Email != "alice@example.com" || !claims.EmailVerified || claims.PreferredUsername != "alice" {
+		t.Errorf("unexpected claims: %+v", claims)
+	}
+}
+
+func TestOIDCLoginRejectsWrongPKCEVerifier(t *testing.T) {
+	mock := newMockOIDCProvider(t)
+	provider := mock.provider(t)
+
+	if _, err := mock.login(t, provider, true); err == nil || !strings.Contains(err.Error(), "exchange") {
+		t.Fatalf("expected the code exchange to fail, got %v", err)
+	}
+}
+
+func TestOIDCLoginRejectsInvalidIDTokens(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		want    string
+		idToken func(p *mockOIDCProvider, claims jwt.MapClaims) (string, error)
+	}{
+		{"wrong nonce", "nonce mismatch", func(p *mockOIDCProvider, claims jwt.MapClaims) (string, error) {
+			claims["nonce"] = "other"
+			return p.sign(claims)
+		}},
+		{"wrong audience", "wrong audience", func(p *mockOIDCProvider, claims jwt.MapClaims) (string, error) {
+			claims["aud"] = "someone-else"
+			return p.sign(claims)
+		}},
+		{"wrong issuer", "wrong issuer", func(p *mockOIDCProvider, claims jwt.MapClaims) (string, error) {
+			claims["iss"] = "https://evil.example.com"
+			return p.sign(claims)
+		}},
+		{"expired", "expired", func(p *mockOIDCProvider, claims jwt.MapClaims) (string, error) {
+			claims["exp"] = time.Now().Add(-time.Minute).Unix()
+			return p.sign(claims)
+		}},
+		{"missing subject", "missing subject", func(p *mockOIDCProvider, claims jwt.MapClaims) (string, error) {
+			delete(claims, "sub")
+			return p.sign(claims)
+		}},
+		{"unknown key", "verification error", func(p *mockOIDCProvider, claims jwt.MapClaims) (string, error) {
+			token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+			token.Header["kid"] = mockKeyID
+			return token.SignedString(otherKey)
+		}},
+		{"HMAC", "signing method HS256 is invalid", func(p *mockOIDCProvider, claims jwt.MapClaims) (string, error) {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+			token.Header["kid"] = mockKeyID
+			return token.SignedString([]byte(mockClientID))
+		}},
+		{"alg none", "signing method none is invalid", func(p *mockOIDCProvider, claims jwt.MapClaims) (string, error) {
+			return jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := newMockOIDCProvider(t)
+			mock.idToken = func(claims jwt.MapClaims) (string, error) { return tt.idToken(mock, claims) }
+			provider := mock.provider(t)
+
+			_, err := mock.login(t, provider, false)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
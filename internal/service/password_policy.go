@@ -0,0 +1,59 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// bcrypt ignores everything after the first 72 bytes.
+const maxPasswordBytes = 72
+
+type PasswordPolicy struct {
+	MinLength        int
+	RequireMixedCase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+}
+
+// Validate checks password strength. The username is passed so a password equal
+// to it can be rejected.
+func (p PasswordPolicy) Validate(username, password string) error {
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters long", p.MinLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("password cannot be longer than %d bytes", maxPasswordBytes)
+	}
+	if strings.TrimSpace(password) == "" {
+		return fmt.Errorf("password cannot be blank")
+	}
+	if username != "" && strings.EqualFold(password, username) {
+		return fmt.Errorf("password cannot be the same as the username")
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireMixedCase && !(hasUpper && hasLower) {
+		return fmt.Errorf("password must contain both upper and lower case letters")
+	}
+	if p.RequireDigit && !hasDigit {
+		return fmt.Errorf("password must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		return fmt.Errorf("password must contain a symbol")
+	}
+	return nil
+}
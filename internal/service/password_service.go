@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"strings"
+	"time"
+
+	"Flare-server/internal/notify"
+	"Flare-server/internal/repository"
+
+	"cloud.google.com/go/firestore"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrInvalidPassword = errors.New("current password is incorrect")
+
+const passwordResetSendTimeout = 30 * time.Second
+
+type PasswordResetOptions struct {
+	TokenTTL time.Duration
+	// URL is the reset page link; the token is appended to it. When empty the
+	// bare token is sent.
+	URL string
+}
+
+type PasswordService struct {
+	userRepo     *repository.UserRepo
+	authService  *AuthService
+	notifier     notify.Notifier
+	resetOptions PasswordResetOptions
+}
+
+func NewPasswordService(userRepo *repository.UserRepo, authService *AuthService, notifier notify.Notifier, resetOptions PasswordResetOptions) *PasswordService {
+	return &PasswordService{
+		userRepo:     userRepo,
+		authService:  authService,
+		notifier:     notifier,
+		resetOptions: resetOptions,
+	}
+}
+
+// ChangePassword replaces the password and returns a fresh token. Every session
+// started before the change, including the caller's current one, is revoked and
+// open WebSocket connections are closed.
+func (s *PasswordService) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) (string, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("user not found")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(currentPassword)); err != nil {
+		return "", ErrInvalidPassword
+	}
+	if currentPassword == newPassword {
+		return "", fmt.Errorf("new password must be different from the current one")
+	}
+	if err := s.authService.passwordPolicy.Validate(user.Username, newPassword); err != nil {
+		return "", err
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = s.userRepo.UpdateUser(ctx, user.ID, []firestore.Update{
+		{Path: "password", Value: string(hashed)},
+		{Path: "passwordChangedAt", Value: now},
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := s.authService.RevokeAllTokens(ctx, user.ID); err != nil {
+		return "", err
+	}
+
+	// Re-read the user so the new token carries the bumped token version.
+	user, err = s.userRepo.GetUserByID(ctx, user.ID)
+	if err != nil {
+		return "", err
+	}
+	return s.authService.IssueToken(user)
+}
+
+// RequestReset sends a single-use reset link to the user's email in the
+// background and returns at once. Unknown users and users without an email are
+// not reported, and since the response does not wait for the lookup or the mail,
+// its timing cannot be used to discover accounts either.
+func (s *PasswordService) RequestReset(ctx context.Context, username string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), passwordResetSendTimeout)
+	go func() {
+		defer cancel()
+		if err := s.sendReset(ctx, strings.TrimSpace(username)); err != nil {
+			log.Printf("Failed to send password reset: %v", err)
+		}
+	}()
+}
+
+func (s *PasswordService) sendReset(ctx context.Context, username string) error {
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil
+	}
+	if user.Email == "" {
+		log.Printf("Password reset requested for %s, but no email is set", user.ID)
+		return nil
+	}
+
+	token, err := generateResetToken()
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.CreatePasswordReset(ctx, token, user.ID, s.resetOptions.TokenTTL); err != nil {
+		return err
+	}
+
+	link := token
+	if s.resetOptions.URL != "" {
+		link = s.resetOptions.URL + token
+	}
+
+	err = s.notifier.Send(ctx, notify.Message{
+		To:      user.Email,
+		Subject: "Flare password reset",
+		Body: fmt.Sprintf("Hi %s,\n\nUse the link below to reset your password. It expires in %s and can only be used once.\n\n%s\n\nIf you did not request a reset, ignore this message.\n",
+			user.Username, s.resetOptions.TokenTTL, link),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send reset email to %s: %w", user.ID, err)
+	}
+	return nil
+}
+
+// ConfirmReset sets a new password using a reset token. All existing sessions are
+// revoked, open WebSocket connections are closed and any login lockout for the
+// account is lifted.
+func (s *PasswordService) ConfirmReset(ctx context.Context, token, newPassword string) error {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return repository.ErrInvalidResetToken
+	}
+	if err := s.authService.passwordPolicy.Validate("", newPassword); err != nil {
+		return err
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.ResetPassword(ctx, token, string(hashed))
+	if err != nil {
+		return err
+	}
+	if err := s.authService.RevokeAllTokens(ctx, user.ID); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.ResetLoginAttempts(ctx, loginAttemptsUserKey(user.Username)); err != nil {
+		log.Printf("Failed to reset login attempts for %s: %v", user.ID, err)
+	}
+	return nil
+}
+
+func generateResetToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", nil
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", fmt.Errorf("invalid email address %q", email)
+	}
+	return strings.ToLower(email), nil
+}
@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/repository"
+)
+
+const (
+	maxPollQuestionLength = 300
+	maxPollOptionLength   = 100
+	minPollOptions        = 2
+	maxPollOptions        = 10
+)
+
+type PollService struct {
+	chatRepo    *repository.ChatRepo
+	pollRepo    *repository.PollRepo
+	chatService *ChatService
+}
+
+func NewPollService(chatRepo *repository.ChatRepo, pollRepo *repository.PollRepo, chatService *ChatService) *PollService {
+	return &PollService{
+		chatRepo:    chatRepo,
+		pollRepo:    pollRepo,
+		chatService: chatService,
+	}
+}
+
+func (s *PollService) CreatePoll(ctx context.Context, chatID, senderID, username string, req models.CreatePollRequest) (*models.Message, error) {
+	if err := s.requireMember(ctx, chatID, senderID); err != nil {
+		return nil, err
+	}
+
+	question := strings.TrimSpace(req.Question)
+	if question == "" {
+		return nil, fmt.Errorf("poll question cannot be empty")
+	}
+	if len([]rune(question)) > maxPollQuestionLength {
+		return nil, fmt.Errorf("poll question cannot be longer than %d characters", maxPollQuestionLength)
+	}
+
+	if len(req.Options) < minPollOptions || len(req.Options) > maxPollOptions {
+		return nil, fmt.Errorf("poll must have between %d and %d options", minPollOptions, maxPollOptions)
+	}
+
+	seen := make(map[string]bool)
+	options := make([]models.PollOption, 0, len(req.Options))
+	for i, text := range req.Options {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			return nil, fmt.Errorf("poll options cannot be empty")
+		}
+		if len([]rune(text)) > maxPollOptionLength {
+			return nil, fmt.Errorf("poll options cannot be longer than %d characters", maxPollOptionLength)
+		}
+		if seen[strings.ToLower(text)] {
+			return nil, fmt.Errorf("poll options must be unique")
+		}
+		seen[strings.ToLower(text)] = true
+
+		options = append(options, models.PollOption{ID: i, Text: text})
+	}
+
+	if req.ClosesAt != nil && !req.ClosesAt.After(time.Now()) {
+		return nil, fmt.Errorf("closesAt must be in the future")
+	}
+
+	// Polls go through the same posting checks, content filters and slow mode
+	// as any other message.
+	return s.chatService.postMessage(ctx, models.Message{
+		ChatID:   chatID,
+		SenderID: senderID,
+		Username: username,
+		Text:     question,
+		Type:     models.MessageTypePoll,
+		Poll: &models.Poll{
+			Question:       question,
+			Options:        options,
+			MultipleChoice: req.MultipleChoice,
+			Anonymous:      req.Anonymous,
+			ClosesAt:       req.ClosesAt,
+		},
+	})
+}
+
+func (s *PollService) Vote(ctx context.Context, chatID, messageID, userID string, optionIDs []int) (*models.PollUpdate, error) {
+	if err := s.requireMember(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	if len(optionIDs) == 0 {
+		return nil, fmt.Errorf("at least one option must be selected")
+	}
+
+	msg, err := s.pollRepo.ApplyPollVote(ctx, messageID, userID, func(msg *models.Message, previous *models.PollVote) (*models.PollVote, error) {
+		poll := msg.Poll
+		if msg.ChatID != chatID {
+			return nil, fmt.Errorf("poll not found")
+		}
+		if poll.IsClosed(time.Now()) {
+			return nil, fmt.Errorf("poll is closed")
+		}
+		if !poll.MultipleChoice && len(optionIDs) > 1 {
+			return nil, fmt.Errorf("poll allows only one option")
+		}
+
+		selected := make(map[int]bool)
+		for _, id := range optionIDs {
+			if id < 0 || id >= len(poll.Options) {
+				return nil, fmt.Errorf("invalid poll option %d", id)
+			}
+			if selected[id] {
+				return nil, fmt.Errorf("duplicate poll option %d", id)
+			}
+			selected[id] = true
+		}
+
+		if previous != nil {
+			removeVote(poll, previous, userID)
+		}
+		for _, id := range optionIDs {
+			poll.Options[id].VoteCount++
+			if !poll.Anonymous {
+				poll.Options[id].Voters = append(poll.Options[id].Voters, userID)
+			}
+		}
+		poll.TotalVoters++
+
+		return &models.PollVote{
+			ChatID:    chatID,
+			MessageID: messageID,
+			UserID:    userID,
+			OptionIDs: optionIDs,
+			VotedAt:   time.Now(),
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pollUpdate(msg), nil
+}
+
+func (s *PollService) RetractVote(ctx context.Context, chatID, messageID, userID string) (*models.PollUpdate, error) {
+	if err := s.requireMember(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	msg, err := s.pollRepo.ApplyPollVote(ctx, messageID, userID, func(msg *models.Message, previous *models.PollVote) (*models.PollVote, error) {
+		if msg.ChatID != chatID {
+			return nil, fmt.Errorf("poll not found")
+		}
+		if msg.Poll.IsClosed(time.Now()) {
+			return nil, fmt.Errorf("poll is closed")
+		}
+		if previous == nil {
+			return nil, fmt.Errorf("you have not voted in this poll")
+		}
+
+		removeVote(msg.Poll, previous, userID)
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pollUpdate(msg), nil
+}
+
+func (s *PollService) ClosePoll(ctx context.Context, chatID, messageID, userID string) (*models.PollUpdate, error) {
+	if err := s.requireMember(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	isAdmin, err := s.chatService.isUserAdmin(ctx, chatID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check admin status: %w", err)
+	}
+
+	msg, err := s.pollRepo.UpdatePoll(ctx, messageID, func(msg *models.Message) error {
+		if msg.ChatID != chatID {
+			return fmt.Errorf("poll not found")
+		}
+		if msg.SenderID != userID && !isAdmin {
+			return fmt.Errorf("access denied: only the poll author or chat admins can close the poll")
+		}
+		if msg.Poll.ClosedAt != nil {
+			return fmt.Errorf("poll is already closed")
+		}
+
+		now := time.Now()
+		msg.Poll.ClosedAt = &now
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pollUpdate(msg), nil
+}
+
+func (s *PollService) requireMember(ctx context.Context, chatID, userID string) error {
+	isMember, err := s.chatRepo.IsUserInChat(ctx, chatID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check chat membership: %w", err)
+	}
+	if !isMember {
+		return fmt.Errorf("access denied: user is not a member of this chat")
+	}
+	return nil
+}
+
+func removeVote(poll *models.Poll, vote *models.PollVote, userID string) {
+	for _, id := range vote.OptionIDs {
+		if id < 0 || id >= len(poll.Options) {
+			continue
+		}
+
+		option := &poll.Options[id]
+		if option.VoteCount > 0 {
+			option.VoteCount--
+		}
+		for i, voter := range option.Voters {
+			if voter == userID {
+				option.Voters = append(option.Voters[:i], option.Voters[i+1:]...)
+				break
+			}
+		}
+	}
+
+	if poll.TotalVoters > 0 {
+		poll.TotalVoters--
+	}
+}
+
+func pollUpdate(msg *models.Message) *models.PollUpdate {
+	return &models.PollUpdate{
+		ChatID:    msg.ChatID,
+		MessageID: msg.ID,
+		Poll:      *msg.Poll,
+	}
+}
@@ -0,0 +1,287 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/push"
+	"Flare-server/internal/repository"
+)
+
+const (
+	maxDevicesPerUser    = 10
+	maxPushTokenLength   = 4096
+	maxPushPreviewLength = 200
+)
+
+// Presence reports whether a user currently has a live connection. It is
+// implemented by the WebSocket hub.
+type Presence interface {
+	IsUserOnline(userID string) bool
+}
+
+// PushOptions configures push notifications.
+type PushOptions struct {
+	// CollapseWindow is how long messages of a chat are collected before one
+	// notification is sent for all of them.
+	CollapseWindow time.Duration
+	Timeout        time.Duration
+}
+
+// PushService manages device tokens and notifies chat members who are offline
+// about new messages. Messages arriving in a chat within CollapseWindow are
+// sent as a single notification, and every notification of a chat uses the
+// chat ID as collapse key, so a device shows at most one per chat.
+type PushService struct {
+	deviceRepo *repository.DeviceRepo
+	chatRepo   *repository.ChatRepo
+	blockRepo  *repository.BlockRepo
+	provider   push.Provider
+	presence   Presence
+	options    PushOptions
+
+	mutex   sync.Mutex
+	pending map[string][]models.Message
+}
+
+func NewPushService(deviceRepo *repository.DeviceRepo, chatRepo *repository.ChatRepo, blockRepo *repository.BlockRepo, provider push.Provider, presence Presence, options PushOptions) *PushService {
+	return &PushService{
+		deviceRepo: deviceRepo,
+		chatRepo:   chatRepo,
+		blockRepo:  blockRepo,
+		provider:   provider,
+		presence:   presence,
+		options:    options,
+		pending:    make(map[string][]models.Message),
+	}
+}
+
+// RegisterDevice stores a push token for the user. Only the most recently seen
+// maxDevicesPerUser devices are kept.
+func (s *PushService) RegisterDevice(ctx context.Context, userID string, req models.RegisterDeviceRequest) (*models.Device, error) {
+	token := strings.TrimSpace(req.Token)
+	if token == "" {
+		return nil, fmt.Errorf("token is required")
+	}
+	if len(token) > maxPushTokenLength {
+		return nil, fmt.Errorf("token cannot be longer than %d characters", maxPushTokenLength)
+	}
+
+	switch req.Platform {
+	case models.DevicePlatformAndroid, models.DevicePlatformIOS, models.DevicePlatformWeb:
+	default:
+		return nil, fmt.Errorf("platform must be android, ios or web")
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if len([]rune(name)) > maxDisplayNameLength {
+		return nil, fmt.Errorf("device name cannot be longer than %d characters", maxDisplayNameLength)
+	}
+
+	device, err := s.deviceRepo.RegisterDevice(ctx, models.Device{
+		UserID:   userID,
+		Token:    token,
+		Platform: req.Platform,
+		Name:     name,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.pruneDevices(ctx, userID)
+	return device, nil
+}
+
+func (s *PushService) GetDevices(ctx context.Context, userID string) ([]models.Device, error) {
+	devices, err := s.deviceRepo.GetUserDevices(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if devices == nil {
+		devices = []models.Device{}
+	}
+	return devices, nil
+}
+
+func (s *PushService) DeleteDevice(ctx context.Context, userID, deviceID string) error {
+	return s.deviceRepo.DeleteDevice(ctx, userID, deviceID)
+}
+
+func (s *PushService) pruneDevices(ctx context.Context, userID string) {
+	devices, err := s.deviceRepo.GetUserDevices(ctx, userID)
+	if err != nil || len(devices) <= maxDevicesPerUser {
+		return
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].LastSeenAt.After(devices[j].LastSeenAt)
+	})
+	for _, device := range devices[maxDevicesPerUser:] {
+		if err := s.deviceRepo.DeleteDevice(ctx, userID, device.ID); err != nil {
+			log.Printf("Failed to remove old device %s of %s: %v", device.ID, userID, err)
+		}
+	}
+}
+
+// HandleChatEvent collects new messages of the chat; the first one starts the
+// collapse window after which they are sent.
+func (s *PushService) HandleChatEvent(ctx context.Context, event models.ChatEvent) {
+	if event.Type != models.ChatEventMessageCreated || event.Message == nil || event.Message.Type == models.MessageTypeSystem {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, waiting := s.pending[event.ChatID]; !waiting {
+		chatID := event.ChatID
+		time.AfterFunc(s.options.CollapseWindow, func() { s.flush(chatID) })
+	}
+	s.pending[event.ChatID] = append(s.pending[event.ChatID], *event.Message)
+}
+
+// flush notifies the members of the chat about the collected messages. Members
+// are skipped while they are online, have muted the chat or hid the senders.
+func (s *PushService) flush(chatID string) {
+	s.mutex.Lock()
+	messages := s.pending[chatID]
+	delete(s.pending, chatID)
+	s.mutex.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.options.Timeout)
+	defer cancel()
+
+	chat, err := s.chatRepo.GetChatByID(ctx, chatID)
+	if err != nil {
+		log.Printf("Failed to get chat %s for push notifications: %v", chatID, err)
+		return
+	}
+	members, err := s.chatRepo.GetChatMembers(ctx, chatID)
+	if err != nil {
+		log.Printf("Failed to get members of chat %s for push notifications: %v", chatID, err)
+		return
+	}
+
+	hiders := make(map[string]map[string]bool)
+	for _, message := range messages {
+		if _, ok := hiders[message.SenderID]; ok {
+			continue
+		}
+		ids, err := s.blockRepo.GetMessageHiderIDs(ctx, message.SenderID)
+		if err != nil {
+			log.Printf("Failed to get users hiding messages from %s: %v", message.SenderID, err)
+		}
+		hiders[message.SenderID] = ids
+	}
+
+	now := time.Now()
+	unread := make(map[string][]models.Message)
+	var recipientIDs []string
+	for _, member := range members {
+		if member.Bot || member.IsMuted(now) || s.presence.IsUserOnline(member.UserID) {
+			continue
+		}
+		for _, message := range messages {
+			if message.SenderID == member.UserID || hiders[message.SenderID][member.UserID] {
+				continue
+			}
+			unread[member.UserID] = append(unread[member.UserID], message)
+		}
+		if len(unread[member.UserID]) > 0 {
+			recipientIDs = append(recipientIDs, member.UserID)
+		}
+	}
+	if len(recipientIDs) == 0 {
+		return
+	}
+
+	devices, err := s.deviceRepo.GetUsersDevices(ctx, recipientIDs)
+	if err != nil {
+		log.Printf("Failed to get devices for push notifications: %v", err)
+		return
+	}
+
+	var notifications []push.Notification
+	for _, userID := range recipientIDs {
+		tokens := make([]string, 0, len(devices[userID]))
+		for _, device := range devices[userID] {
+			tokens = append(tokens, device.Token)
+		}
+		if len(tokens) > 0 {
+			notifications = append(notifications, buildPushNotification(chat, unread[userID], tokens))
+		}
+	}
+	if len(notifications) == 0 {
+		return
+	}
+
+	result, err := s.provider.Send(ctx, notifications)
+	if err != nil {
+		log.Printf("Failed to send push notifications for chat %s: %v", chatID, err)
+	}
+	if result == nil || len(result.InvalidTokens) == 0 {
+		return
+	}
+	if err := s.deviceRepo.DeleteTokens(ctx, result.InvalidTokens); err != nil {
+		log.Printf("Failed to remove %d unregistered devices: %v", len(result.InvalidTokens), err)
+	}
+}
+
+// buildPushNotification summarizes the unread messages of one recipient: the
+// newest message is shown and the title carries the count.
+func buildPushNotification(chat *models.Chat, messages []models.Message, tokens []string) push.Notification {
+	last := messages[len(messages)-1]
+
+	title := last.Username
+	if chat.Type == models.ChatTypeGroup {
+		title = chat.Name
+	}
+
+	body := pushPreview(last)
+	switch {
+	case last.Type == models.MessageTypeAction:
+		body = last.Username + " " + body
+	case chat.Type == models.ChatTypeGroup:
+		body = last.Username + ": " + body
+	}
+	if len(messages) > 1 {
+		title = fmt.Sprintf("%s (%d)", title, len(messages))
+	}
+
+	return push.Notification{
+		Tokens: tokens,
+		Title:  title,
+		Body:   body,
+		Data: map[string]string{
+			"type":      "new_message",
+			"chatId":    chat.ID,
+			"messageId": last.ID,
+			"count":     strconv.Itoa(len(messages)),
+		},
+		CollapseKey: chat.ID,
+	}
+}
+
+func pushPreview(message models.Message) string {
+	text := strings.TrimSpace(message.Text)
+	switch {
+	case message.Type == models.MessageTypePoll && message.Poll != nil:
+		text = "📊 " + message.Poll.Question
+	case text == "" && message.Type == models.MessageTypeImage:
+		text = "🖼 Изображение"
+	case text == "" && message.Type == models.MessageTypeFile:
+		text = "📎 Файл"
+	}
+
+	if runes := []rune(text); len(runes) > maxPushPreviewLength {
+		text = string(runes[:maxPushPreviewLength]) + "…"
+	}
+	return text
+}
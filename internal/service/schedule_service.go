@@ -0,0 +1,235 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/repository"
+
+	"cloud.google.com/go/firestore"
+)
+
+const (
+	maxScheduleAhead       = 365 * 24 * time.Hour
+	scheduledDispatchBatch = 50
+	scheduledClaimLease    = time.Minute
+	scheduledMaxAttempts   = 3
+)
+
+type ScheduleService struct {
+	scheduledRepo *repository.ScheduledMessageRepo
+	chatService   *ChatService
+	broadcaster   Broadcaster
+	workerID      string
+}
+
+func NewScheduleService(scheduledRepo *repository.ScheduledMessageRepo, chatService *ChatService, broadcaster Broadcaster) *ScheduleService {
+	return &ScheduleService{
+		scheduledRepo: scheduledRepo,
+		chatService:   chatService,
+		broadcaster:   broadcaster,
+		workerID:      newWorkerID(),
+	}
+}
+
+func (s *ScheduleService) ScheduleMessage(ctx context.Context, chatID, senderID, username string, req models.ScheduleMessageRequest) (*models.ScheduledMessage, error) {
+	isMember, err := s.chatService.IsUserInChat(ctx, chatID, senderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check chat membership: %w", err)
+	}
+	if !isMember {
+		return nil, fmt.Errorf("access denied: user is not a member of this chat")
+	}
+
+	text := strings.TrimSpace(req.Text)
+	if text == "" {
+		return nil, fmt.Errorf("message text cannot be empty")
+	}
+
+	if err := validateSendAt(req.SendAt); err != nil {
+		return nil, err
+	}
+
+	msg := models.ScheduledMessage{
+		ChatID:   chatID,
+		SenderID: senderID,
+		Username: username,
+		Text:     text,
+		ReplyTo:  req.ReplyTo,
+		SendAt:   req.SendAt.UTC(),
+	}
+
+	return s.scheduledRepo.CreateScheduledMessage(ctx, msg)
+}
+
+func (s *ScheduleService) GetScheduledMessages(ctx context.Context, chatID, userID string) ([]models.ScheduledMessage, error) {
+	isMember, err := s.chatService.IsUserInChat(ctx, chatID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check chat membership: %w", err)
+	}
+	if !isMember {
+		return nil, fmt.Errorf("access denied: user is not a member of this chat")
+	}
+
+	return s.scheduledRepo.GetPendingScheduledMessages(ctx, chatID, userID)
+}
+
+func (s *ScheduleService) UpdateScheduledMessage(ctx context.Context, chatID, scheduledID, userID string, req models.UpdateScheduledMessageRequest) (*models.ScheduledMessage, error) {
+	if _, err := s.getOwnScheduledMessage(ctx, chatID, scheduledID, userID); err != nil {
+		return nil, err
+	}
+
+	var updates []firestore.Update
+	if req.Text != nil {
+		text := strings.TrimSpace(*req.Text)
+		if text == "" {
+			return nil, fmt.Errorf("message text cannot be empty")
+		}
+		updates = append(updates, firestore.Update{Path: "text", Value: text})
+	}
+	if req.SendAt != nil {
+		if err := validateSendAt(*req.SendAt); err != nil {
+			return nil, err
+		}
+		updates = append(updates, firestore.Update{Path: "sendAt", Value: req.SendAt.UTC()})
+	}
+
+	if len(updates) == 0 {
+		return nil, fmt.Errorf("no valid fields to update")
+	}
+
+	return s.scheduledRepo.UpdatePendingScheduledMessage(ctx, scheduledID, updates)
+}
+
+func (s *ScheduleService) CancelScheduledMessage(ctx context.Context, chatID, scheduledID, userID string) error {
+	if _, err := s.getOwnScheduledMessage(ctx, chatID, scheduledID, userID); err != nil {
+		return err
+	}
+
+	_, err := s.scheduledRepo.UpdatePendingScheduledMessage(ctx, scheduledID, []firestore.Update{
+		{Path: "status", Value: models.ScheduledStatusCancelled},
+	})
+	return err
+}
+
+// Start runs the dispatcher until ctx is cancelled. Every replica may run it: each
+// due message is claimed in a transaction before sending, so only one replica sends it.
+func (s *ScheduleService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Scheduled message dispatcher %s started", s.workerID)
+	for {
+		s.dispatchDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *ScheduleService) dispatchDue(ctx context.Context) {
+	due, err := s.scheduledRepo.GetDueScheduledMessages(ctx, time.Now(), scheduledDispatchBatch)
+	if err != nil {
+		log.Printf("Failed to load due scheduled messages: %v", err)
+		return
+	}
+
+	for _, candidate := range due {
+		msg, err := s.scheduledRepo.ClaimScheduledMessage(ctx, candidate.ID, s.workerID, scheduledClaimLease)
+		if err != nil {
+			log.Printf("Failed to claim scheduled message %s: %v", candidate.ID, err)
+			continue
+		}
+		if msg == nil {
+			continue
+		}
+
+		s.dispatch(ctx, msg)
+	}
+}
+
+func (s *ScheduleService) dispatch(ctx context.Context, msg *models.ScheduledMessage) {
+	isMember, err := s.chatService.IsUserInChat(ctx, msg.ChatID, msg.SenderID)
+	if err == nil && !isMember {
+		if err := s.scheduledRepo.MarkScheduledMessageFailed(ctx, msg.ID, "sender is no longer a member of this chat"); err != nil {
+			log.Printf("Failed to mark scheduled message %s as failed: %v", msg.ID, err)
+		}
+		return
+	}
+
+	message, posted, err := s.chatService.SendScheduledMessage(ctx, msg)
+	if err != nil {
+		log.Printf("Failed to send scheduled message %s (attempt %d): %v", msg.ID, msg.Attempts, err)
+		if msg.Attempts >= scheduledMaxAttempts {
+			err = s.scheduledRepo.MarkScheduledMessageFailed(ctx, msg.ID, err.Error())
+		} else {
+			err = s.scheduledRepo.ReleaseScheduledMessage(ctx, msg.ID, err.Error())
+		}
+		if err != nil {
+			log.Printf("Failed to update scheduled message %s: %v", msg.ID, err)
+		}
+		return
+	}
+
+	// A slash command that posts nothing still counts as sent. If marking fails,
+	// the next dispatch finds the message already posted and only marks it.
+	messageID := ""
+	if message != nil {
+		messageID = message.ID
+	}
+	if err := s.scheduledRepo.MarkScheduledMessageSent(ctx, msg.ID, messageID); err != nil {
+		log.Printf("Failed to mark scheduled message %s as sent: %v", msg.ID, err)
+	}
+	if !posted {
+		return
+	}
+
+	s.broadcaster.BroadcastMessageExcept(msg.ChatID, "new_message", message, s.chatService.MessageRecipientsToSkip(ctx, msg.SenderID))
+}
+
+func (s *ScheduleService) getOwnScheduledMessage(ctx context.Context, chatID, scheduledID, userID string) (*models.ScheduledMessage, error) {
+	msg, err := s.scheduledRepo.GetScheduledMessage(ctx, scheduledID)
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.ChatID != chatID || msg.SenderID != userID {
+		return nil, fmt.Errorf("scheduled message not found")
+	}
+
+	return msg, nil
+}
+
+func validateSendAt(sendAt time.Time) error {
+	if sendAt.IsZero() {
+		return fmt.Errorf("sendAt is required")
+	}
+	if !sendAt.After(time.Now()) {
+		return fmt.Errorf("sendAt must be in the future")
+	}
+	if sendAt.After(time.Now().Add(maxScheduleAhead)) {
+		return fmt.Errorf("sendAt cannot be more than a year ahead")
+	}
+	return nil
+}
+
+func newWorkerID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "flare"
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return hostname + "-" + hex.EncodeToString(suffix)
+}
@@ -0,0 +1,400 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"Flare-server/internal/models"
+)
+
+// commandPattern matches "/name", "/name@bot" and "/name args". Names are
+// lowercase; anything else starting with a slash, like a path, is plain text.
+var (
+	commandPattern     = regexp.MustCompile(`^/([a-z0-9_]{1,32})(?:@([A-Za-z0-9_]+))?(?:\s+([\s\S]*))?$`)
+	commandNamePattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+)
+
+// CommandCall is one invocation of a slash command.
+type CommandCall struct {
+	ChatID   string
+	UserID   string
+	Username string
+	Command  string
+	Args     string
+}
+
+// CommandResult is what a command produced. Message, if set, is posted into the
+// chat on behalf of the invoker; Reply, if set, is shown only to the invoker.
+type CommandResult struct {
+	Message *models.Message
+	Reply   string
+}
+
+type CommandHandler func(ctx context.Context, call CommandCall) (*CommandResult, error)
+
+// SlashCommand is a command users can run by sending "/<Name> ..." as a message.
+type SlashCommand struct {
+	Name        string
+	Description string
+	Usage       string
+	// AdminOnly restricts the command to chat admins.
+	AdminOnly bool
+	// Bot is the username of the bot that provides the command, if any. Users
+	// can address it as "/<Name>@<Bot>" when several bots use the same name.
+	Bot     string
+	Handler CommandHandler
+}
+
+// ChatCommandSource supplies commands that only exist in some chats, such as
+// the ones bots registered there.
+type ChatCommandSource interface {
+	ChatCommands(ctx context.Context, chatID string) ([]SlashCommand, error)
+}
+
+// RegisterCommand adds a command to every chat. It replaces a command with the
+// same name.
+func (s *ChatService) RegisterCommand(cmd SlashCommand) {
+	s.commands[cmd.Name] = cmd
+}
+
+// AddCommandSource registers a source of per-chat commands. Commands registered
+// with RegisterCommand take precedence over them.
+func (s *ChatService) AddCommandSource(source ChatCommandSource) {
+	s.commandSources = append(s.commandSources, source)
+}
+
+// SetBroadcaster wires ephemeral command replies. It is set after construction
+// because the WebSocket hub itself depends on ChatService.
+func (s *ChatService) SetBroadcaster(broadcaster Broadcaster) {
+	s.broadcaster = broadcaster
+}
+
+// GetChatCommands lists the commands a member can use in the chat.
+func (s *ChatService) GetChatCommands(ctx context.Context, chatID, userID string) ([]models.CommandInfo, error) {
+	member, err := s.getMember(ctx, chatID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	commands := s.availableCommands(ctx, chatID)
+	infos := make([]models.CommandInfo, 0, len(commands))
+	for _, cmd := range commands {
+		if cmd.AdminOnly && member.Role != models.RoleAdmin {
+			continue
+		}
+		infos = append(infos, models.CommandInfo{
+			Command:     cmd.Name,
+			Description: cmd.Description,
+			Usage:       cmd.Usage,
+			AdminOnly:   cmd.AdminOnly,
+			Bot:         cmd.Bot,
+		})
+	}
+	return infos, nil
+}
+
+// SendEphemeral shows text only to userID, who must be a member of the chat.
+func (s *ChatService) SendEphemeral(ctx context.Context, chatID, userID string, response models.CommandResponse) error {
+	isMember, err := s.chatRepo.IsUserInChat(ctx, chatID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check chat membership: %w", err)
+	}
+	if !isMember {
+		return fmt.Errorf("user is not a member of this chat")
+	}
+	if strings.TrimSpace(response.Text) == "" {
+		return fmt.Errorf("text cannot be empty")
+	}
+
+	response.ChatID = chatID
+	if s.broadcaster != nil {
+		s.broadcaster.SendToUser(userID, "command_response", response)
+	}
+	return nil
+}
+
+// parseCommand splits a message into a command call. ok is false for ordinary text.
+func parseCommand(text string) (name, bot, args string, ok bool) {
+	match := commandPattern.FindStringSubmatch(text)
+	if match == nil {
+		return "", "", "", false
+	}
+	return match[1], match[2], strings.TrimSpace(match[3]), true
+}
+
+// runCommand executes a slash command sent as a message. It returns the message
+// the command posted, or nil if it only replied to the invoker. A posted message
+// is stored under messageID when it is not empty.
+func (s *ChatService) runCommand(ctx context.Context, messageID, chatID, senderID, username, name, bot, args string) (*models.Message, error) {
+	member, err := s.getMember(ctx, chatID, senderID)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd, err := s.findCommand(ctx, chatID, name, bot)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.AdminOnly && member.Role != models.RoleAdmin {
+		return nil, fmt.Errorf("access denied: /%s is only available to chat admins", cmd.Name)
+	}
+
+	result, err := cmd.Handler(ctx, CommandCall{
+		ChatID:   chatID,
+		UserID:   senderID,
+		Username: username,
+		Command:  cmd.Name,
+		Args:     args,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, nil
+	}
+
+	if result.Reply != "" && s.broadcaster != nil {
+		s.broadcaster.SendToUser(senderID, "command_response", models.CommandResponse{
+			ChatID:  chatID,
+			Command: cmd.Name,
+			Text:    result.Reply,
+		})
+	}
+
+	if result.Message == nil {
+		return nil, nil
+	}
+	message := *result.Message
+	message.ID = messageID
+	message.ChatID = chatID
+	message.SenderID = senderID
+	message.Username = username
+	return s.postMessage(ctx, message)
+}
+
+func (s *ChatService) findCommand(ctx context.Context, chatID, name, bot string) (*SlashCommand, error) {
+	if bot == "" {
+		if cmd, ok := s.commands[name]; ok {
+			return &cmd, nil
+		}
+	}
+
+	var matches []SlashCommand
+	for _, cmd := range s.chatCommands(ctx, chatID) {
+		if cmd.Name == name && (bot == "" || strings.EqualFold(cmd.Bot, bot)) {
+			matches = append(matches, cmd)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("unknown command /%s; send //%s to post it as text", name, name)
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, fmt.Errorf("several bots handle /%s; use /%s@<bot>", name, name)
+	}
+}
+
+// availableCommands returns the built-in and per-chat commands sorted by name.
+// Per-chat commands shadowed by a built-in one are left out.
+func (s *ChatService) availableCommands(ctx context.Context, chatID string) []SlashCommand {
+	commands := make([]SlashCommand, 0, len(s.commands))
+	for _, cmd := range s.commands {
+		commands = append(commands, cmd)
+	}
+	for _, cmd := range s.chatCommands(ctx, chatID) {
+		if _, ok := s.commands[cmd.Name]; !ok {
+			commands = append(commands, cmd)
+		}
+	}
+
+	sort.Slice(commands, func(i, j int) bool {
+		if commands[i].Name != commands[j].Name {
+			return commands[i].Name < commands[j].Name
+		}
+		return commands[i].Bot < commands[j].Bot
+	})
+	return commands
+}
+
+func (s *ChatService) chatCommands(ctx context.Context, chatID string) []SlashCommand {
+	var commands []SlashCommand
+	for _, source := range s.commandSources {
+		found, err := source.ChatCommands(ctx, chatID)
+		if err != nil {
+			log.Printf("Failed to get commands of chat %s: %v", chatID, err)
+			continue
+		}
+		commands = append(commands, found...)
+	}
+	return commands
+}
+
+func (s *ChatService) isBuiltinCommand(name string) bool {
+	_, ok := s.commands[name]
+	return ok
+}
+
+func (s *ChatService) getMember(ctx context.Context, chatID, userID string) (*models.ChatMember, error) {
+	members, err := s.chatRepo.GetChatMembers(ctx, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check chat membership: %w", err)
+	}
+	for i := range members {
+		if members[i].UserID == userID {
+			return &members[i], nil
+		}
+	}
+	return nil, fmt.Errorf("access denied: user is not a member of this chat")
+}
+
+func (s *ChatService) registerBuiltinCommands() {
+	s.RegisterCommand(SlashCommand{
+		Name:        "help",
+		Description: "Список команд чата",
+		Usage:       "/help",
+		Handler:     s.helpCommand,
+	})
+	s.RegisterCommand(SlashCommand{
+		Name:        "me",
+		Description: "Написать о себе в третьем лице",
+		Usage:       "/me <действие>",
+		Handler:     meCommand,
+	})
+	s.RegisterCommand(SlashCommand{
+		Name:        "topic",
+		Description: "Изменить описание чата",
+		Usage:       "/topic <текст>",
+		AdminOnly:   true,
+		Handler:     s.topicCommand,
+	})
+	s.RegisterCommand(SlashCommand{
+		Name:        "invite",
+		Description: "Добавить пользователя в чат",
+		Usage:       "/invite @username",
+		AdminOnly:   true,
+		Handler:     s.inviteCommand,
+	})
+	s.RegisterCommand(SlashCommand{
+		Name:        "kick",
+		Description: "Удалить пользователя из чата",
+		Usage:       "/kick @username",
+		AdminOnly:   true,
+		Handler:     s.kickCommand,
+	})
+	s.RegisterCommand(SlashCommand{
+		Name:        "mute",
+		Description: "Отключить уведомления чата для себя",
+		Usage:       "/mute [длительность, например 8h | off]",
+		Handler:     s.muteCommand,
+	})
+}
+
+func (s *ChatService) helpCommand(ctx context.Context, call CommandCall) (*CommandResult, error) {
+	commands, err := s.GetChatCommands(ctx, call.ChatID, call.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := make([]string, 0, len(commands))
+	for _, cmd := range commands {
+		line := cmd.Usage
+		if line == "" {
+			line = "/" + cmd.Command
+		}
+		if cmd.Bot != "" {
+			line += " (@" + cmd.Bot + ")"
+		}
+		if cmd.Description != "" {
+			line += " - " + cmd.Description
+		}
+		lines = append(lines, line)
+	}
+	return &CommandResult{Reply: strings.Join(lines, "\n")}, nil
+}
+
+func meCommand(ctx context.Context, call CommandCall) (*CommandResult, error) {
+	if call.Args == "" {
+		return nil, fmt.Errorf("usage: /me <action>")
+	}
+	return &CommandResult{Message: &models.Message{Text: call.Args, Type: models.MessageTypeAction}}, nil
+}
+
+func (s *ChatService) topicCommand(ctx context.Context, call CommandCall) (*CommandResult, error) {
+	if call.Args == "" {
+		return nil, fmt.Errorf("usage: /topic <text>")
+	}
+	if err := s.UpdateChat(ctx, call.ChatID, call.UserID, map[string]interface{}{"description": call.Args}); err != nil {
+		return nil, err
+	}
+	return &CommandResult{Reply: "Описание чата обновлено"}, nil
+}
+
+func (s *ChatService) inviteCommand(ctx context.Context, call CommandCall) (*CommandResult, error) {
+	username := commandUsername(call.Args)
+	if username == "" {
+		return nil, fmt.Errorf("usage: /invite @username")
+	}
+	if err := s.AddMemberToChat(ctx, call.ChatID, call.UserID, models.AddMemberRequest{Username: username}); err != nil {
+		return nil, err
+	}
+	return &CommandResult{Reply: fmt.Sprintf("%s добавлен в чат", username)}, nil
+}
+
+func (s *ChatService) kickCommand(ctx context.Context, call CommandCall) (*CommandResult, error) {
+	username := commandUsername(call.Args)
+	if username == "" {
+		return nil, fmt.Errorf("usage: /kick @username")
+	}
+	user, err := s.userRepo.GetUserByUsername(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err := s.RemoveMemberFromChat(ctx, call.ChatID, call.UserID, user.ID); err != nil {
+		return nil, err
+	}
+	return &CommandResult{Reply: fmt.Sprintf("%s удален из чата", user.Username)}, nil
+}
+
+// muteCommand mutes the chat for the invoker: without arguments until "/mute off",
+// otherwise for the given duration.
+func (s *ChatService) muteCommand(ctx context.Context, call CommandCall) (*CommandResult, error) {
+	if call.Args == "off" {
+		if err := s.chatRepo.SetMemberMuted(ctx, call.ChatID, call.UserID, false, nil); err != nil {
+			return nil, fmt.Errorf("failed to unmute chat: %w", err)
+		}
+		return &CommandResult{Reply: "Уведомления чата включены"}, nil
+	}
+
+	var until *time.Time
+	if call.Args != "" {
+		d, err := time.ParseDuration(call.Args)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("usage: /mute [duration like 30m or 8h | off]")
+		}
+		t := time.Now().Add(d)
+		until = &t
+	}
+
+	if err := s.chatRepo.SetMemberMuted(ctx, call.ChatID, call.UserID, true, until); err != nil {
+		return nil, fmt.Errorf("failed to mute chat: %w", err)
+	}
+	if until == nil {
+		return &CommandResult{Reply: "Уведомления чата отключены. /mute off - включить"}, nil
+	}
+	return &CommandResult{Reply: fmt.Sprintf("Уведомления чата отключены до %s UTC", until.UTC().Format("2006-01-02 15:04"))}, nil
+}
+
+func commandUsername(args string) string {
+	fields := strings.Fields(args)
+	if len(fields) != 1 {
+		return ""
+	}
+	return strings.TrimPrefix(fields[0], "@")
+}
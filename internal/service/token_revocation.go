@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"Flare-server/internal/repository"
+)
+
+// Revocations from other replicas are fetched with this much overlap so entries
+// written by a replica with a slightly late clock are not missed.
+const denylistSyncOverlap = 30 * time.Second
+
+var ErrRevocationCheckFailed = errors.New("failed to check token revocation")
+
+// TokenDenylist caches the revoked token IDs of all replicas in memory, so
+// checking a token does not cost a Firestore read. Each replica polls for new
+// revocations; if polling keeps failing for longer than maxStaleness the
+// denylist falls back to reading Firestore directly.
+type TokenDenylist struct {
+	repo         *repository.RevokedTokenRepo
+	maxStaleness time.Duration
+
+	mu       sync.RWMutex
+	entries  map[string]time.Time // token ID -> token expiry
+	syncedAt time.Time
+	cursor   time.Time
+}
+
+func NewTokenDenylist(repo *repository.RevokedTokenRepo, maxStaleness time.Duration) *TokenDenylist {
+	return &TokenDenylist{
+		repo:         repo,
+		maxStaleness: maxStaleness,
+		entries:      make(map[string]time.Time),
+	}
+}
+
+// Start syncs the denylist every interval and purges expired entries every
+// purgeInterval until ctx is cancelled.
+func (d *TokenDenylist) Start(ctx context.Context, interval, purgeInterval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	purged := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := d.Sync(ctx); err != nil {
+			log.Printf("Failed to sync token denylist: %v", err)
+		}
+		if time.Since(purged) >= purgeInterval {
+			purged = time.Now()
+			if n, err := d.repo.DeleteExpiredRevokedTokens(ctx, purged); err != nil {
+				log.Printf("Failed to delete expired revoked tokens: %v", err)
+			} else if n > 0 {
+				log.Printf("🔑 Deleted %d expired revoked tokens", n)
+			}
+		}
+	}
+}
+
+// Sync loads the revocations made since the last sync and drops expired entries
+// from memory. It is also called once at startup to load the whole denylist.
+func (d *TokenDenylist) Sync(ctx context.Context) error {
+	d.mu.RLock()
+	since := d.cursor
+	d.mu.RUnlock()
+	if !since.IsZero() {
+		since = since.Add(-denylistSyncOverlap)
+	}
+
+	tokens, err := d.repo.GetRevokedTokensSince(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, token := range tokens {
+		if token.ExpiresAt.After(now) {
+			d.entries[token.ID] = token.ExpiresAt
+		}
+		if token.RevokedAt.After(d.cursor) {
+			d.cursor = token.RevokedAt
+		}
+	}
+	for id, expiresAt := range d.entries {
+		if !expiresAt.After(now) {
+			delete(d.entries, id)
+		}
+	}
+	d.syncedAt = now
+	return nil
+}
+
+// Revoke denylists a token until it expires. The entry is visible on this replica
+// at once and on the others after their next sync.
+func (d *TokenDenylist) Revoke(ctx context.Context, id, userID string, expiresAt time.Time) error {
+	if !expiresAt.After(time.Now()) {
+		return nil
+	}
+
+	err := d.repo.RevokeToken(ctx, repository.RevokedToken{
+		ID:        id,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		RevokedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	d.entries[id] = expiresAt
+	d.mu.Unlock()
+	return nil
+}
+
+func (d *TokenDenylist) IsRevoked(ctx context.Context, id string) (bool, error) {
+	d.mu.RLock()
+	_, revoked := d.entries[id]
+	fresh := time.Since(d.syncedAt) <= d.maxStaleness
+	d.mu.RUnlock()
+
+	if revoked || fresh {
+		return revoked, nil
+	}
+
+	revoked, err := d.repo.IsTokenRevoked(ctx, id)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrRevocationCheckFailed, err)
+	}
+	return revoked, nil
+}
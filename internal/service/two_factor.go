@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/repository"
+
+	"cloud.google.com/go/firestore"
+	"github.com/golang-jwt/jwt/v4"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	loginChallengeTTL     = 5 * time.Minute
+	loginChallengePurpose = "login_2fa"
+	recoveryCodeCount     = 10
+)
+
+var ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+type loginChallengeClaims struct {
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// EnrollTwoFactor generates a new secret and stores it as pending. It replaces any
+// earlier unconfirmed enrollment.
+func (s *AuthService) EnrollTwoFactor(ctx context.Context, userID string) (*models.TwoFactorEnrollment, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if user.TOTPEnabled {
+		return nil, fmt.Errorf("two-factor authentication is already enabled")
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.UpdateUser(ctx, user.ID, []firestore.Update{{Path: "totpPendingSecret", Value: secret}}); err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    totpURI(secret, user.Username),
+	}, nil
+}
+
+// ActivateTwoFactor confirms the pending secret with a code from the authenticator
+// and returns freshly generated recovery codes.
+func (s *AuthService) ActivateTwoFactor(ctx context.Context, userID, code string) (*models.RecoveryCodesResponse, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("user not found")
+	}
+	if user.TOTPEnabled {
+		return nil, fmt.Errorf("two-factor authentication is already enabled")
+	}
+	if user.TOTPPendingSecret == "" {
+		return nil, fmt.Errorf("two-factor enrollment has not been started")
+	}
+
+	step, ok := verifyTOTP(user.TOTPPendingSecret, strings.TrimSpace(code), time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.userRepo.UpdateUser(ctx, user.ID, []firestore.Update{
+		{Path: "totpEnabled", Value: true},
+		{Path: "totpSecret", Value: user.TOTPPendingSecret},
+		{Path: "totpPendingSecret", Value: ""},
+		{Path: "totpLastStep", Value: step},
+		{Path: "recoveryCodes", Value: hashes},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// DisableTwoFactor turns 2FA off. Both the password and a current TOTP or
+// recovery code are required, so a stolen session alone is not enough.
+func (s *AuthService) DisableTwoFactor(ctx context.Context, userID, password, code string) error {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("user not found")
+	}
+	if !user.TOTPEnabled {
+		return fmt.Errorf("two-factor authentication is not enabled")
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		return ErrInvalidPassword
+	}
+	if err := s.verifySecondFactor(ctx, user, code); err != nil {
+		return err
+	}
+
+	return s.userRepo.UpdateUser(ctx, user.ID, []firestore.Update{
+		{Path: "totpEnabled", Value: false},
+		{Path: "totpSecret", Value: ""},
+		{Path: "totpPendingSecret", Value: ""},
+		{Path: "totpLastStep", Value: 0},
+		{Path: "recoveryCodes", Value: []string{}},
+	})
+}
+
+// CompleteTwoFactorLogin exchanges a login challenge and a TOTP or recovery code
+// for an access token. Wrong codes count as failed logins.
+func (s *AuthService) CompleteTwoFactorLogin(ctx context.Context, challengeToken, code, clientIP string) (string, error) {
+	userID, err := s.parseLoginChallenge(challengeToken)
+	if err != nil {
+		return "", err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil || !user.TOTPEnabled {
+		return "", errors.New("invalid challenge token")
+	}
+
+	if err := s.checkLoginAllowed(ctx, loginAttemptsUserKey(user.Username), loginAttemptsIPKey(clientIP)); err != nil {
+		return "", err
+	}
+
+	if err := s.verifySecondFactor(ctx, user, code); err != nil {
+		s.handleLoginFailure(ctx, user.Username, clientIP, user)
+		return "", err
+	}
+
+	if err := checkNotSuspended(user); err != nil {
+		return "", err
+	}
+	return s.completeLogin(ctx, user)
+}
+
+// verifySecondFactor accepts either a 6-digit TOTP code or a recovery code. Both
+// are consumed so they cannot be replayed.
+func (s *AuthService) verifySecondFactor(ctx context.Context, user *repository.User, code string) error {
+	code = strings.TrimSpace(code)
+
+	if len(code) == totpDigits {
+		step, ok := verifyTOTP(user.TOTPSecret, code, time.Now())
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		if err := s.userRepo.UseTOTPStep(ctx, user.ID, step); err != nil {
+			if errors.Is(err, repository.ErrCodeAlreadyUsed) {
+				return ErrInvalidTwoFactorCode
+			}
+			return err
+		}
+		return nil
+	}
+
+	if err := s.userRepo.UseRecoveryCode(ctx, user.ID, hashRecoveryCode(code)); err != nil {
+		if errors.Is(err, repository.ErrCodeAlreadyUsed) {
+			return ErrInvalidTwoFactorCode
+		}
+		return err
+	}
+	return nil
+}
+
+// issueLoginChallenge signs a short-lived token that only proves the password was
+// correct. It has no userID, jti or audience, so it is never accepted as an
+// access token.
+func (s *AuthService) issueLoginChallenge(user *repository.User) (string, error) {
+	now := time.Now()
+	claims := &loginChallengeClaims{
+		Purpose: loginChallengePurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.tokenOptions.Issuer,
+			Subject:   user.ID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(loginChallengeTTL)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(s.JWTKey)
+}
+
+func (s *AuthService) parseLoginChallenge(tokenString string) (string, error) {
+	var claims loginChallengeClaims
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	token, err := parser.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		return s.JWTKey, nil
+	})
+	if err != nil || !token.Valid {
+		return "", errors.New("invalid challenge token")
+	}
+
+	if claims.Purpose != loginChallengePurpose || !claims.VerifyIssuer(s.tokenOptions.Issuer, true) || claims.Subject == "" {
+		return "", errors.New("invalid challenge token")
+	}
+	return claims.Subject, nil
+}
+
+// generateRecoveryCodes returns codes formatted as XXXXX-XXXXX together with the
+// hashes that are stored. The codes are random enough that SHA-256 is sufficient.
+func generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, fmt.Errorf("failed to generate recovery codes: %w", err)
+		}
+		raw := totpEncoding.EncodeToString(b)[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hashRecoveryCode(raw))
+	}
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/repository"
+)
+
+const (
+	minSearchQueryLength = 2
+	maxSearchQueryLength = 64
+	defaultSearchLimit   = 20
+	maxSearchLimit       = 50
+	minFuzzyScore        = 0.3
+)
+
+type scoredUser struct {
+	user  repository.User
+	score float64
+}
+
+// SearchUsers finds users by username or display name. Exact and prefix matches rank
+// above fuzzy (trigram/edit distance) matches. The caller, users who blocked the
+// caller and users who opted out of discovery are never returned.
+func (s *UserService) SearchUsers(ctx context.Context, callerID, query string, limit, offset int) (*models.UserSearchResponse, error) {
+	query = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(query), "@")))
+	if len([]rune(query)) < minSearchQueryLength {
+		return nil, fmt.Errorf("search query must be at least %d characters", minSearchQueryLength)
+	}
+	if len([]rune(query)) > maxSearchQueryLength {
+		return nil, fmt.Errorf("search query cannot be longer than %d characters", maxSearchQueryLength)
+	}
+
+	if limit <= 0 || limit > maxSearchLimit {
+		limit = defaultSearchLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	candidates, err := s.userRepo.SearchUsers(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	blockers, err := s.blockRepo.GetBlockerIDs(ctx, callerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []scoredUser
+	for _, user := range candidates {
+		if user.ID == callerID || user.HiddenFromSearch || blockers[user.ID] {
+			continue
+		}
+
+		score := scoreUserMatch(user, query)
+		if score < minFuzzyScore {
+			continue
+		}
+		matches = append(matches, scoredUser{user: user, score: score})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].user.UsernameLower < matches[j].user.UsernameLower
+	})
+
+	response := &models.UserSearchResponse{Users: []models.UserProfile{}}
+	if offset >= len(matches) {
+		return response, nil
+	}
+
+	end := offset + limit
+	if end < len(matches) {
+		response.HasMore = true
+		response.NextOffset = end
+	} else {
+		end = len(matches)
+	}
+
+	for _, match := range matches[offset:end] {
+		response.Users = append(response.Users, match.user.Profile())
+	}
+
+	return response, nil
+}
+
+func scoreUserMatch(user repository.User, query string) float64 {
+	username := strings.ToLower(user.Username)
+	displayName := strings.ToLower(user.DisplayName)
+
+	switch {
+	case username == query:
+		return 1
+	case strings.HasPrefix(username, query):
+		return 0.9
+	case displayName == query:
+		return 0.85
+	case strings.HasPrefix(displayName, query):
+		return 0.8
+	}
+
+	for _, word := range strings.Fields(displayName) {
+		if strings.HasPrefix(word, query) {
+			return 0.75
+		}
+	}
+
+	best := 0.0
+	for _, value := range []string{username, displayName} {
+		if value == "" {
+			continue
+		}
+		if score := trigramSimilarity(query, value); score > best {
+			best = score
+		}
+		if score := editSimilarity(query, value); score > best {
+			best = score
+		}
+	}
+	return best * 0.7
+}
+
+func trigramSimilarity(a, b string) float64 {
+	ta := repository.SearchTrigrams(a)
+	tb := repository.SearchTrigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	set := make(map[string]bool, len(ta))
+	for _, t := range ta {
+		set[t] = true
+	}
+
+	common := 0
+	for _, t := range tb {
+		if set[t] {
+			common++
+		}
+	}
+
+	return float64(common) / float64(len(ta)+len(tb)-common)
+}
+
+func editSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 0
+	}
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
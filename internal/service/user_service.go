@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/repository"
+
+	"cloud.google.com/go/firestore"
+)
+
+const (
+	maxDisplayNameLength = 64
+	maxBioLength         = 500
+	maxAvatarURLLength   = 2048
+	MaxAvatarSize        = 512 * 1024
+)
+
+var (
+	localePattern = regexp.MustCompile(`^[a-zA-Z]{2,3}(-[a-zA-Z0-9]{2,8})*$`)
+
+	allowedAvatarTypes = map[string]bool{
+		"image/png":  true,
+		"image/jpeg": true,
+		"image/gif":  true,
+		"image/webp": true,
+	}
+)
+
+type UserService struct {
+	userRepo         *repository.UserRepo
+	chatRepo         *repository.ChatRepo
+	blockRepo        *repository.BlockRepo
+	usernamePolicy   *UsernamePolicy
+	usernameCooldown time.Duration
+}
+
+func NewUserService(userRepo *repository.UserRepo, chatRepo *repository.ChatRepo, blockRepo *repository.BlockRepo, usernamePolicy *UsernamePolicy, usernameCooldown time.Duration) *UserService {
+	return &UserService{
+		userRepo:         userRepo,
+		chatRepo:         chatRepo,
+		blockRepo:        blockRepo,
+		usernamePolicy:   usernamePolicy,
+		usernameCooldown: usernameCooldown,
+	}
+}
+
+func (s *UserService) GetProfile(ctx context.Context, userID string) (*models.UserProfile, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := user.Profile()
+	return &profile, nil
+}
+
+// GetOwnProfile is GetProfile plus the private settings only the owner may see.
+func (s *UserService) GetOwnProfile(ctx context.Context, userID string) (*models.UserProfile, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := user.Profile()
+	settings := user.Settings()
+	profile.Settings = &settings
+	return &profile, nil
+}
+
+func (s *UserService) UpdateProfile(ctx context.Context, userID string, req models.UpdateProfileRequest) (*models.UserProfile, error) {
+	var updates []firestore.Update
+
+	if req.DisplayName != nil {
+		displayName := strings.TrimSpace(*req.DisplayName)
+		if len([]rune(displayName)) > maxDisplayNameLength {
+			return nil, fmt.Errorf("display name cannot be longer than %d characters", maxDisplayNameLength)
+		}
+		updates = append(updates, firestore.Update{Path: "displayName", Value: displayName})
+	}
+
+	if req.AvatarURL != nil {
+		avatarURL := strings.TrimSpace(*req.AvatarURL)
+		if err := validateAvatarURL(avatarURL); err != nil {
+			return nil, err
+		}
+		updates = append(updates, firestore.Update{Path: "avatarUrl", Value: avatarURL})
+	}
+
+	if req.Bio != nil {
+		bio := strings.TrimSpace(*req.Bio)
+		if len([]rune(bio)) > maxBioLength {
+			return nil, fmt.Errorf("bio cannot be longer than %d characters", maxBioLength)
+		}
+		updates = append(updates, firestore.Update{Path: "bio", Value: bio})
+	}
+
+	if req.Locale != nil {
+		locale := strings.TrimSpace(*req.Locale)
+		if locale != "" && !localePattern.MatchString(locale) {
+			return nil, fmt.Errorf("invalid locale %q", locale)
+		}
+		updates = append(updates, firestore.Update{Path: "locale", Value: locale})
+	}
+
+	if req.Timezone != nil {
+		timezone := strings.TrimSpace(*req.Timezone)
+		if timezone != "" {
+			if _, err := time.LoadLocation(timezone); err != nil || timezone == "Local" {
+				return nil, fmt.Errorf("invalid timezone %q", timezone)
+			}
+		}
+		updates = append(updates, firestore.Update{Path: "timezone", Value: timezone})
+	}
+
+	if req.Discoverable != nil {
+		updates = append(updates, firestore.Update{Path: "hiddenFromSearch", Value: !*req.Discoverable})
+	}
+
+	if req.ContactsOnlyPrivateChats != nil {
+		updates = append(updates, firestore.Update{Path: "contactsOnlyPrivateChats", Value: *req.ContactsOnlyPrivateChats})
+	}
+
+	if req.Email != nil {
+		email, err := normalizeEmail(*req.Email)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, firestore.Update{Path: "email", Value: email})
+	}
+
+	if len(updates) == 0 {
+		return nil, fmt.Errorf("no valid fields to update")
+	}
+
+	if err := s.userRepo.UpdateUser(ctx, userID, updates); err != nil {
+		return nil, err
+	}
+
+	if req.DisplayName != nil {
+		if err := s.userRepo.RefreshSearchIndex(ctx, userID); err != nil {
+			log.Printf("Failed to refresh search index for user %s: %v", userID, err)
+		}
+	}
+
+	return s.GetOwnProfile(ctx, userID)
+}
+
+// ChangeUsername renames the user and propagates the new name to their chat
+// memberships. Messages keep the username they were sent with; readers resolve the
+// current name by SenderID instead (see ChatService.resolveSenderNames).
+func (s *UserService) ChangeUsername(ctx context.Context, userID, newUsername string) (*repository.User, error) {
+	newUsername = strings.TrimSpace(newUsername)
+	if err := s.usernamePolicy.Validate(newUsername); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.ChangeUsername(ctx, userID, newUsername, time.Now().Add(-s.usernameCooldown))
+	if errors.Is(err, repository.ErrUsernameChangeTooSoon) {
+		return nil, fmt.Errorf("username can only be changed once every %s", s.usernameCooldown)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.chatRepo.UpdateMemberUsernames(ctx, userID, user.Username); err != nil {
+		log.Printf("Failed to propagate username change for user %s: %v", userID, err)
+	}
+
+	return user, nil
+}
+
+// SetAvatar stores an uploaded image and points the profile's avatar URL at it. The
+// version query parameter lets clients cache the image until it changes.
+func (s *UserService) SetAvatar(ctx context.Context, userID string, data []byte) (*models.UserProfile, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("avatar image is empty")
+	}
+	if len(data) > MaxAvatarSize {
+		return nil, fmt.Errorf("avatar image cannot be larger than %d KB", MaxAvatarSize/1024)
+	}
+
+	contentType := http.DetectContentType(data)
+	if !allowedAvatarTypes[contentType] {
+		return nil, fmt.Errorf("unsupported avatar image type %s", contentType)
+	}
+
+	if err := s.userRepo.SaveAvatar(ctx, userID, repository.UserAvatar{ContentType: contentType, Data: data}); err != nil {
+		return nil, err
+	}
+
+	avatarURL := fmt.Sprintf("/api/users/%s/avatar?v=%d", userID, time.Now().Unix())
+	if err := s.userRepo.UpdateUser(ctx, userID, []firestore.Update{{Path: "avatarUrl", Value: avatarURL}}); err != nil {
+		return nil, err
+	}
+
+	return s.GetProfile(ctx, userID)
+}
+
+func (s *UserService) GetAvatar(ctx context.Context, userID string) (*repository.UserAvatar, error) {
+	return s.userRepo.GetAvatar(ctx, userID)
+}
+
+func (s *UserService) DeleteAvatar(ctx context.Context, userID string) error {
+	if err := s.userRepo.DeleteAvatar(ctx, userID); err != nil {
+		return fmt.Errorf("failed to delete avatar: %w", err)
+	}
+
+	return s.userRepo.UpdateUser(ctx, userID, []firestore.Update{{Path: "avatarUrl", Value: ""}})
+}
+
+func validateAvatarURL(avatarURL string) error {
+	if avatarURL == "" {
+		return nil
+	}
+	if len(avatarURL) > maxAvatarURLLength {
+		return fmt.Errorf("avatar URL is too long")
+	}
+
+	parsed, err := url.Parse(avatarURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("avatar URL must be an http or https URL")
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.]{3,32}$`)
+
+var defaultReservedUsernames = []string{
+	"admin", "administrator", "root", "system", "flare", "support",
+	"moderator", "help", "security", "me", "bot", "null", "undefined",
+}
+
+type UsernamePolicy struct {
+	reserved map[string]bool
+}
+
+// NewUsernamePolicy builds a policy that rejects the built-in reserved names plus
+// any extra names from configuration. Reserved names are compared case-insensitively.
+func NewUsernamePolicy(extraReserved []string) *UsernamePolicy {
+	reserved := make(map[string]bool)
+	for _, name := range append(defaultReservedUsernames, extraReserved...) {
+		if name = strings.TrimSpace(name); name != "" {
+			reserved[strings.ToLower(name)] = true
+		}
+	}
+	return &UsernamePolicy{reserved: reserved}
+}
+
+func (p *UsernamePolicy) Validate(username string) error {
+	if !usernamePattern.MatchString(username) {
+		return fmt.Errorf("username must be 3-32 characters long and contain only letters, digits, '_' and '.'")
+	}
+	if p.reserved[strings.ToLower(username)] {
+		return fmt.Errorf("username %q is reserved", username)
+	}
+	return nil
+}
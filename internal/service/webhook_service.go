@@ -0,0 +1,457 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	mathrand "math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/repository"
+)
+
+const (
+	webhookDeliveryBatch  = 50
+	webhookClaimLease     = 2 * time.Minute
+	maxWebhookDeliveryLog = 100
+)
+
+// WebhookOptions configures outgoing chat webhooks.
+type WebhookOptions struct {
+	MaxPerChat int
+	// MaxAttempts is how often a delivery is tried before it is dead-lettered.
+	MaxAttempts int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// DisableAfter consecutive failed attempts turn the webhook off.
+	DisableAfter int
+	LogRetention time.Duration
+	Timeout      time.Duration
+	// AllowPrivate permits http and private network URLs, for development.
+	AllowPrivate bool
+}
+
+// webhookStore is the part of repository.WebhookRepo the service uses.
+type webhookStore interface {
+	CreateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error)
+	GetWebhook(ctx context.Context, id string) (*models.Webhook, error)
+	GetChatWebhooks(ctx context.Context, chatID string) ([]models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	SetWebhookDisabled(ctx context.Context, id string, disabled bool, reason string) error
+	RecordWebhookResult(ctx context.Context, id string, success bool, disableAfter int) (bool, error)
+	EnqueueDelivery(ctx context.Context, delivery models.WebhookDelivery) error
+	GetDueDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error)
+	ClaimDelivery(ctx context.Context, id, workerID string, lease time.Duration) (*models.WebhookDelivery, error)
+	FinishDeliveryAttempt(ctx context.Context, id string, attempt models.WebhookAttempt, status models.WebhookDeliveryStatus, nextAttemptAt time.Time) error
+	ReleaseDelivery(ctx context.Context, id string, nextAttemptAt time.Time) error
+	GetDeliveries(ctx context.Context, webhookID string, status models.WebhookDeliveryStatus, limit int) ([]models.WebhookDelivery, error)
+	GetDelivery(ctx context.Context, id string) (*models.WebhookDelivery, error)
+	RequeueDelivery(ctx context.Context, id string) error
+	DeleteOldDeliveries(ctx context.Context, before time.Time) (int, error)
+}
+
+// WebhookService lets chat admins subscribe URLs to chat events. Events are
+// queued in Firestore and delivered by Start on any replica; each delivery is
+// claimed in a transaction, retried with exponential backoff and dead-lettered
+// once it runs out of attempts.
+type WebhookService struct {
+	webhookRepo webhookStore
+	chatService *ChatService
+	broadcaster Broadcaster
+	options     WebhookOptions
+	httpClient  *http.Client
+	workerID    string
+	kick        chan struct{}
+}
+
+func NewWebhookService(webhookRepo *repository.WebhookRepo, chatService *ChatService, broadcaster Broadcaster, options WebhookOptions) *WebhookService {
+	return &WebhookService{
+		webhookRepo: webhookRepo,
+		chatService: chatService,
+		broadcaster: broadcaster,
+		options:     options,
+		httpClient:  newOutboundHTTPClient(options.Timeout, options.AllowPrivate),
+		workerID:    newWorkerID(),
+		kick:        make(chan struct{}, 1),
+	}
+}
+
+func (s *WebhookService) CreateWebhook(ctx context.Context, chatID, userID string, req models.CreateWebhookRequest) (*models.Webhook, error) {
+	if err := s.requireAdmin(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	if err := validateCallbackURL(req.URL, s.options.AllowPrivate); err != nil {
+		return nil, err
+	}
+	for _, eventType := range req.Events {
+		if !isWebhookEventType(eventType) {
+			return nil, fmt.Errorf("unknown event type: %s", eventType)
+		}
+	}
+
+	existing, err := s.webhookRepo.GetChatWebhooks(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= s.options.MaxPerChat {
+		return nil, fmt.Errorf("a chat can have at most %d webhooks", s.options.MaxPerChat)
+	}
+
+	secret, err := randomToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	return s.webhookRepo.CreateWebhook(ctx, models.Webhook{
+		ChatID:    chatID,
+		URL:       req.URL,
+		Events:    req.Events,
+		Secret:    secret,
+		CreatedBy: userID,
+	})
+}
+
+func (s *WebhookService) GetWebhooks(ctx context.Context, chatID, userID string) ([]models.Webhook, error) {
+	if err := s.requireAdmin(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	webhooks, err := s.webhookRepo.GetChatWebhooks(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+// UpdateWebhook turns a webhook off or back on.
+func (s *WebhookService) UpdateWebhook(ctx context.Context, chatID, webhookID, userID string, req models.UpdateWebhookRequest) (*models.Webhook, error) {
+	if _, err := s.getChatWebhook(ctx, chatID, webhookID, userID); err != nil {
+		return nil, err
+	}
+	if req.Disabled == nil {
+		return nil, fmt.Errorf("no valid fields to update")
+	}
+
+	reason := ""
+	if *req.Disabled {
+		reason = "disabled by an admin"
+	}
+	if err := s.webhookRepo.SetWebhookDisabled(ctx, webhookID, *req.Disabled, reason); err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	webhook, err := s.webhookRepo.GetWebhook(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, chatID, webhookID, userID string) error {
+	if _, err := s.getChatWebhook(ctx, chatID, webhookID, userID); err != nil {
+		return err
+	}
+	return s.webhookRepo.DeleteWebhook(ctx, webhookID)
+}
+
+// GetDeliveries returns the delivery log of a webhook, newest first. Passing
+// status "dead" lists the dead-letter queue.
+func (s *WebhookService) GetDeliveries(ctx context.Context, chatID, webhookID, userID string, status models.WebhookDeliveryStatus) ([]models.WebhookDelivery, error) {
+	if _, err := s.getChatWebhook(ctx, chatID, webhookID, userID); err != nil {
+		return nil, err
+	}
+
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliverySending, models.WebhookDeliveryDelivered, models.WebhookDeliveryDead:
+	default:
+		return nil, fmt.Errorf("unknown delivery status: %s", status)
+	}
+
+	return s.webhookRepo.GetDeliveries(ctx, webhookID, status, maxWebhookDeliveryLog)
+}
+
+// RetryDelivery puts a dead-lettered delivery back into the queue.
+func (s *WebhookService) RetryDelivery(ctx context.Context, chatID, webhookID, deliveryID, userID string) error {
+	if _, err := s.getChatWebhook(ctx, chatID, webhookID, userID); err != nil {
+		return err
+	}
+
+	delivery, err := s.webhookRepo.GetDelivery(ctx, deliveryID)
+	if err != nil {
+		return err
+	}
+	if delivery.WebhookID != webhookID {
+		return fmt.Errorf("delivery not found")
+	}
+
+	if err := s.webhookRepo.RequeueDelivery(ctx, deliveryID); err != nil {
+		return err
+	}
+	s.wake()
+	return nil
+}
+
+// HandleChatEvent queues the event for every enabled webhook of the chat that
+// subscribed to it.
+func (s *WebhookService) HandleChatEvent(ctx context.Context, event models.ChatEvent) {
+	webhooks, err := s.webhookRepo.GetChatWebhooks(ctx, event.ChatID)
+	if err != nil {
+		log.Printf("Failed to get webhooks of chat %s: %v", event.ChatID, err)
+		return
+	}
+
+	var payload []byte
+	queued := false
+	for _, webhook := range webhooks {
+		if webhook.Disabled || !webhook.Subscribed(event.Type) {
+			continue
+		}
+
+		if payload == nil {
+			if payload, err = json.Marshal(event); err != nil {
+				log.Printf("Failed to encode %s event for webhooks: %v", event.Type, err)
+				return
+			}
+		}
+
+		err := s.webhookRepo.EnqueueDelivery(ctx, models.WebhookDelivery{
+			WebhookID: webhook.ID,
+			ChatID:    event.ChatID,
+			EventType: event.Type,
+			Payload:   string(payload),
+		})
+		if err != nil {
+			log.Printf("Failed to queue %s event for webhook %s: %v", event.Type, webhook.ID, err)
+			continue
+		}
+		queued = true
+	}
+
+	if queued {
+		s.wake()
+	}
+}
+
+// Start delivers queued events until ctx is cancelled. It runs every interval,
+// right after events are queued on this replica, and purges old delivery logs
+// once an hour.
+func (s *WebhookService) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	log.Printf("Webhook delivery worker %s started", s.workerID)
+	lastPurge := time.Time{}
+	for {
+		if time.Since(lastPurge) >= time.Hour {
+			s.purgeDeliveries(ctx)
+			lastPurge = time.Now()
+		}
+
+		s.deliverDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.kick:
+		}
+	}
+}
+
+func (s *WebhookService) deliverDue(ctx context.Context) {
+	due, err := s.webhookRepo.GetDueDeliveries(ctx, time.Now(), webhookDeliveryBatch)
+	if err != nil {
+		log.Printf("Failed to load due webhook deliveries: %v", err)
+		return
+	}
+
+	webhooks := make(map[string]*models.Webhook)
+	for _, candidate := range due {
+		delivery, err := s.webhookRepo.ClaimDelivery(ctx, candidate.ID, s.workerID, webhookClaimLease)
+		if err != nil {
+			log.Printf("Failed to claim webhook delivery %s: %v", candidate.ID, err)
+			continue
+		}
+		if delivery == nil {
+			continue
+		}
+
+		webhook, ok := webhooks[delivery.WebhookID]
+		if !ok {
+			webhook, err = s.webhookRepo.GetWebhook(ctx, delivery.WebhookID)
+			if err != nil && !errors.Is(err, repository.ErrWebhookNotFound) {
+				// The webhook may well exist; try again later instead of
+				// dead-lettering the delivery.
+				log.Printf("Failed to get webhook %s: %v", delivery.WebhookID, err)
+				if err := s.webhookRepo.ReleaseDelivery(ctx, delivery.ID, time.Now().Add(s.options.BackoffBase)); err != nil {
+					log.Printf("Failed to release webhook delivery %s: %v", delivery.ID, err)
+				}
+				continue
+			}
+			webhooks[delivery.WebhookID] = webhook
+		}
+
+		s.deliver(ctx, webhook, delivery)
+	}
+}
+
+// deliver makes one attempt at a claimed delivery and records the outcome.
+// Deliveries of deleted or disabled webhooks are dead-lettered without a request
+// so they can be retried once the webhook is enabled again.
+func (s *WebhookService) deliver(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) {
+	attempt := models.WebhookAttempt{At: time.Now()}
+	switch {
+	case webhook == nil:
+		attempt.Error = "webhook was deleted"
+	case webhook.Disabled:
+		attempt.Error = "webhook is disabled"
+	default:
+		attempt.StatusCode, attempt.Error = s.post(ctx, webhook, delivery)
+		attempt.DurationMs = time.Since(attempt.At).Milliseconds()
+	}
+
+	status := models.WebhookDeliveryDelivered
+	nextAttemptAt := delivery.NextAttemptAt
+	if attempt.Error != "" {
+		if webhook == nil || webhook.Disabled || delivery.AttemptCount >= s.options.MaxAttempts {
+			status = models.WebhookDeliveryDead
+		} else {
+			status = models.WebhookDeliveryPending
+			nextAttemptAt = time.Now().Add(s.backoff(delivery.AttemptCount))
+		}
+	}
+
+	if err := s.webhookRepo.FinishDeliveryAttempt(ctx, delivery.ID, attempt, status, nextAttemptAt); err != nil {
+		log.Printf("Failed to record attempt of webhook delivery %s: %v", delivery.ID, err)
+	}
+
+	if webhook == nil || webhook.Disabled {
+		return
+	}
+	disabled, err := s.webhookRepo.RecordWebhookResult(ctx, webhook.ID, attempt.Error == "", s.options.DisableAfter)
+	if err != nil {
+		log.Printf("Failed to record result for webhook %s: %v", webhook.ID, err)
+		return
+	}
+	if disabled {
+		webhook.Disabled = true
+		log.Printf("Webhook %s of chat %s disabled after repeated failures", webhook.ID, webhook.ChatID)
+		webhook.Secret = ""
+		s.broadcaster.SendToUser(webhook.CreatedBy, "webhook_disabled", webhook)
+	}
+}
+
+// post sends the payload signed with the webhook secret. The signature is an
+// HMAC-SHA256 over "<timestamp>.<body>" so receivers can reject replays.
+func (s *WebhookService) post(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, string) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader([]byte(delivery.Payload)))
+	if err != nil {
+		return 0, err.Error()
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Flare-Event", string(delivery.EventType))
+	req.Header.Set("X-Flare-Delivery", delivery.ID)
+	req.Header.Set("X-Flare-Timestamp", timestamp)
+	req.Header.Set("X-Flare-Signature", "sha256="+signWebhookPayload(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Sprintf("webhook responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, ""
+}
+
+// backoff returns the wait before the next attempt: BackoffBase doubled for each
+// failed attempt, capped at BackoffMax, with up to 20% jitter.
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	delay := s.options.BackoffBase
+	for i := 1; i < attempts && delay < s.options.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > s.options.BackoffMax {
+		delay = s.options.BackoffMax
+	}
+	if delay > 0 {
+		delay += time.Duration(mathrand.Int63n(int64(delay)/5 + 1))
+	}
+	return delay
+}
+
+func (s *WebhookService) purgeDeliveries(ctx context.Context) {
+	n, err := s.webhookRepo.DeleteOldDeliveries(ctx, time.Now().Add(-s.options.LogRetention))
+	if err != nil {
+		log.Printf("Failed to delete old webhook deliveries: %v", err)
+	} else if n > 0 {
+		log.Printf("🪝 Deleted %d old webhook deliveries", n)
+	}
+}
+
+func (s *WebhookService) wake() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+func (s *WebhookService) requireAdmin(ctx context.Context, chatID, userID string) error {
+	isAdmin, err := s.chatService.isUserAdmin(ctx, chatID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to check admin rights: %w", err)
+	}
+	if !isAdmin {
+		return fmt.Errorf("access denied: only chat admins can manage webhooks")
+	}
+	return nil
+}
+
+func (s *WebhookService) getChatWebhook(ctx context.Context, chatID, webhookID, userID string) (*models.Webhook, error) {
+	if err := s.requireAdmin(ctx, chatID, userID); err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.webhookRepo.GetWebhook(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if webhook.ChatID != chatID {
+		return nil, fmt.Errorf("webhook not found")
+	}
+	return webhook, nil
+}
+
+func isWebhookEventType(eventType models.ChatEventType) bool {
+	switch eventType {
+	case models.ChatEventMessageCreated, models.ChatEventMemberJoined, models.ChatEventMemberLeft, models.ChatEventChatUpdated:
+		return true
+	}
+	return false
+}
+
+func signWebhookPayload(secret, timestamp, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,521 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"Flare-server/internal/models"
+	"Flare-server/internal/repository"
+)
+
+// memoryWebhookStore keeps webhooks and deliveries in memory and follows the
+// claim and failure counting rules of repository.WebhookRepo.
+type memoryWebhookStore struct {
+	mu         sync.Mutex
+	webhooks   map[string]*models.Webhook
+	deliveries map[string]*models.WebhookDelivery
+	getErr     error
+	nextID     int
+}
+
+func newMemoryWebhookStore() *memoryWebhookStore {
+	return &memoryWebhookStore{
+		webhooks:   make(map[string]*models.Webhook),
+		deliveries: make(map[string]*models.WebhookDelivery),
+	}
+}
+
+func (m *memoryWebhookStore) CreateWebhook(ctx context.Context, webhook models.Webhook) (*models.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	webhook.ID = fmt.Sprintf("webhook-%d", m.nextID)
+	m.webhooks[webhook.ID] = &webhook
+	copied := webhook
+	return &copied, nil
+}
+
+func (m *memoryWebhookStore) GetWebhook(ctx context.Context, id string) (*models.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	webhook, ok := m.webhooks[id]
+	if !ok {
+		return nil, repository.ErrWebhookNotFound
+	}
+	copied := *webhook
+	return &copied, nil
+}
+
+func (m *memoryWebhookStore) GetChatWebhooks(ctx context.Context, chatID string) ([]models.Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var webhooks []models.Webhook
+	for _, webhook := range m.webhooks {
+		if webhook.ChatID == chatID {
+			webhooks = append(webhooks, *webhook)
+		}
+	}
+	return webhooks, nil
+}
+
+func (m *memoryWebhookStore) DeleteWebhook(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.webhooks, id)
+	return nil
+}
+
+func (m *memoryWebhookStore) SetWebhookDisabled(ctx context.Context, id string, disabled bool, reason string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	webhook := m.webhooks[id]
+	webhook.Disabled = disabled
+	webhook.DisabledReason = reason
+	if !disabled {
+		webhook.ConsecutiveFailures = 0
+	}
+	return nil
+}
+
+func (m *memoryWebhookStore) RecordWebhookResult(ctx context.Context, id string, success bool, disableAfter int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	webhook := m.webhooks[id]
+	if success {
+		webhook.ConsecutiveFailures = 0
+		return false, nil
+	}
+	webhook.ConsecutiveFailures++
+	if webhook.ConsecutiveFailures >= disableAfter && !webhook.Disabled {
+		webhook.Disabled = true
+		return true, nil
+	}
+	return false, nil
+}
+
+func (m *memoryWebhookStore) EnqueueDelivery(ctx context.Context, delivery models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	delivery.ID = fmt.Sprintf("delivery-%d", m.nextID)
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.NextAttemptAt = time.Now()
+	m.deliveries[delivery.ID] = &delivery
+	return nil
+}
+
+func (m *memoryWebhookStore) GetDueDeliveries(ctx context.Context, now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due []models.WebhookDelivery
+	for _, delivery := range m.deliveries {
+		if delivery.Status == models.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, *delivery)
+		}
+	}
+	return due, nil
+}
+
+func (m *memoryWebhookStore) ClaimDelivery(ctx context.Context, id, workerID string, lease time.Duration) (*models.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delivery := m.deliveries[id]
+	if delivery.Status != models.WebhookDeliveryPending || delivery.NextAttemptAt.After(time.Now()) {
+		return nil, nil
+	}
+	delivery.Status = models.WebhookDeliverySending
+	delivery.LockedBy = workerID
+	delivery.LockedUntil = time.Now().Add(lease)
+	delivery.AttemptCount++
+	copied := *delivery
+	return &copied, nil
+}
+
+func (m *memoryWebhookStore) FinishDeliveryAttempt(ctx context.Context, id string, attempt models.WebhookAttempt, status models.WebhookDeliveryStatus, nextAttemptAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delivery := m.deliveries[id]
+	delivery.Status = status
+	delivery.Attempts = append(delivery.Attempts, attempt)
+	delivery.NextAttemptAt = nextAttemptAt
+	delivery.LockedBy = ""
+	return nil
+}
+
+func (m *memoryWebhookStore) ReleaseDelivery(ctx context.Context, id string, nextAttemptAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delivery := m.deliveries[id]
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.AttemptCount--
+	delivery.NextAttemptAt = nextAttemptAt
+	delivery.LockedBy = ""
+	return nil
+}
+
+func (m *memoryWebhookStore) GetDeliveries(ctx context.Context, webhookID string, status models.WebhookDeliveryStatus, limit int) ([]models.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (m *memoryWebhookStore) GetDelivery(ctx context.Context, id string) (*models.WebhookDelivery, error) {
+	return m.delivery(id), nil
+}
+
+func (m *memoryWebhookStore) RequeueDelivery(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delivery := m.deliveries[id]
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.AttemptCount = 0
+	delivery.NextAttemptAt = time.Now()
+	return nil
+}
+
+func (m *memoryWebhookStore) DeleteOldDeliveries(ctx context.Context, before time.Time) (int, error) {
+	return 0, nil
+}
+
+func (m *memoryWebhookStore) delivery(id string) *models.WebhookDelivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := *m.deliveries[id]
+	return &copied
+}
+
+func (m *memoryWebhookStore) webhook(id string) *models.Webhook {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	copied := *m.webhooks[id]
+	return &copied
+}
+
+// makeDue lets pending deliveries be retried right away instead of after their
+// backoff.
+func (m *memoryWebhookStore) makeDue() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, delivery := range m.deliveries {
+		delivery.NextAttemptAt = time.Now().Add(-time.Second)
+	}
+}
+
+type recordingBroadcaster struct {
+	mu   sync.Mutex
+	sent []recordedUserEvent
+}
+
+type recordedUserEvent struct {
+	userID      string
+	messageType string
+	data        interface{}
+}
+
+func (b *recordingBroadcaster) BroadcastMessage(chatID string, messageType string, data interface{}) {
+}
+
+func (b *recordingBroadcaster) BroadcastMessageExcept(chatID string, messageType string, data interface{}, skipUserIDs map[string]bool) {
+}
+
+func (b *recordingBroadcaster) SendToUser(userID string, messageType string, data interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sent = append(b.sent, recordedUserEvent{userID, messageType, data})
+}
+
+func (b *recordingBroadcaster) DisconnectUser(userID string) {}
+
+// webhookReceiver is an httptest endpoint answering with the queued status codes,
+// then 200.
+type webhookReceiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedWebhook
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   string
+}
+
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	t.Helper()
+	receiver := &webhookReceiver{statuses: statuses}
+	receiver.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		receiver.mu.Lock()
+		defer receiver.mu.Unlock()
+		receiver.requests = append(receiver.requests, receivedWebhook{header: r.Header.Clone(), body: string(body)})
+		status := http.StatusOK
+		if len(receiver.statuses) > 0 {
+			status, receiver.statuses = receiver.statuses[0], receiver.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+func (r *webhookReceiver) received() []receivedWebhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedWebhook(nil), r.requests...)
+}
+
+var testWebhookOptions = WebhookOptions{
+	MaxAttempts:  3,
+	BackoffBase:  time.Minute,
+	BackoffMax:   10 * time.Minute,
+	DisableAfter: 5,
+	Timeout:      5 * time.Second,
+	AllowPrivate: true,
+}
+
+func newTestWebhookService(store *memoryWebhookStore, broadcaster Broadcaster, options WebhookOptions) *WebhookService {
+	return &WebhookService{
+		webhookRepo: store,
+		broadcaster: broadcaster,
+		options:     options,
+		httpClient:  newOutboundHTTPClient(options.Timeout, options.AllowPrivate),
+		workerID:    "test-worker",
+		kick:        make(chan struct{}, 1),
+	}
+}
+
+// queueTestDelivery creates a webhook for url and queues one event for it.
+func queueTestDelivery(t *testing.T, store *memoryWebhookStore, url string) (*models.Webhook, string) {
+	t.Helper()
+	webhook, _ := store.CreateWebhook(context.Background(), models.Webhook{
+		ChatID:    "chat-1",
+		URL:       url,
+		Secret:    "webhook-secret",
+		CreatedBy: "admin-1",
+	})
+	store.EnqueueDelivery(context.Background(), models.WebhookDelivery{
+		WebhookID: webhook.ID,
+		ChatID:    "chat-1",
+		EventType: models.ChatEventMessageCreated,
+		Payload:   `{"type":"message_created","chatId":"chat-1"}`,
+	})
+	for id := range store.deliveries {
+		return webhook, id
+	}
+	return webhook, ""
+}
+
+func TestWebhookDeliverySignsPayload(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	store := newMemoryWebhookStore()
+	_, deliveryID := queueTestDelivery(t, store, receiver.URL)
+
+	newTestWebhookService(store, &recordingBroadcaster{}, testWebhookOptions).deliverDue(context.Background())
+
+	requests := receiver.received()
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(requests))
+	}
+	req := requests[0]
+	if req.body != `{"type":"message_created","chatId":"chat-1"}` {
+		t.Errorf("unexpected body: %s", req.body)
+	}
+	if req.header.Get("X-Flare-Event") != string(models.ChatEventMessageCreated) || req.header.Get("X-Flare-Delivery") != deliveryID {
+		t.Errorf("unexpected headers: %v", req.header)
+	}
+
+	timestamp := req.header.Get("X-Flare-Timestamp")
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(sent, 0)) > time.Minute {
+		t.Errorf("invalid timestamp %q", timestamp)
+	}
+	mac := hmac.New(sha256.New, []byte("webhook-secret"))
+	mac.Write([]byte(timestamp + "." + req.body))
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); req.header.Get("X-Flare-Signature") != want {
+		t.Errorf("signature = %q, want %q", req.header.Get("X-Flare-Signature"), want)
+	}
+
+	delivery := store.delivery(deliveryID)
+	if delivery.Status != models.WebhookDeliveryDelivered || len(delivery.Attempts) != 1 || delivery.Attempts[0].StatusCode != http.StatusOK {
+		t.Errorf("unexpected delivery after success: %+v", delivery)
+	}
+}
+
+func TestWebhookDeliveryRetriesWithBackoff(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusInternalServerError)
+	store := newMemoryWebhookStore()
+	_, deliveryID := queueTestDelivery(t, store, receiver.URL)
+	s := newTestWebhookService(store, &recordingBroadcaster{}, testWebhookOptions)
+
+	before := time.Now()
+	s.deliverDue(context.Background())
+
+	delivery := store.delivery(deliveryID)
+	if delivery.Status != models.WebhookDeliveryPending {
+		t.Fatalf("status = %s, want pending", delivery.Status)
+	}
+	if len(delivery.Attempts) != 1 || delivery.Attempts[0].StatusCode != http.StatusInternalServerError || delivery.Attempts[0].Error == "" {
+		t.Errorf("unexpected attempt log: %+v", delivery.Attempts)
+	}
+	wait := delivery.NextAttemptAt.Sub(before)
+	if wait < testWebhookOptions.BackoffBase || wait > testWebhookOptions.BackoffBase*6/5+time.Second {
+		t.Errorf("next attempt in %v, want about %v", wait, testWebhookOptions.BackoffBase)
+	}
+
+	// Not due yet, so nothing is sent.
+	s.deliverDue(context.Background())
+	if len(receiver.received()) != 1 {
+		t.Fatalf("delivery was retried before its backoff")
+	}
+
+	store.makeDue()
+	s.deliverDue(context.Background())
+	delivery = store.delivery(deliveryID)
+	if delivery.Status != models.WebhookDeliveryDelivered || len(delivery.Attempts) != 2 {
+		t.Errorf("unexpected delivery after retry: %+v", delivery)
+	}
+}
+
+func TestWebhookBackoffDoublesUpToMax(t *testing.T) {
+	s := newTestWebhookService(newMemoryWebhookStore(), &recordingBroadcaster{}, testWebhookOptions)
+
+	for _, tt := range []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 10 * time.Minute},
+		{50, 10 * time.Minute},
+	} {
+		got := s.backoff(tt.attempts)
+		if got < tt.want || got > tt.want+tt.want/5 {
+			t.Errorf("backoff(%d) = %v, want %v plus at most 20%% jitter", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookDeliveryDeadLettersAfterMaxAttempts(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	store := newMemoryWebhookStore()
+	_, deliveryID := queueTestDelivery(t, store, receiver.URL)
+	s := newTestWebhookService(store, &recordingBroadcaster{}, testWebhookOptions)
+
+	for i := 0; i < testWebhookOptions.MaxAttempts+1; i++ {
+		store.makeDue()
+		s.deliverDue(context.Background())
+	}
+
+	if got := len(receiver.received()); got != testWebhookOptions.MaxAttempts {
+		t.Errorf("webhook received %d requests, want %d", got, testWebhookOptions.MaxAttempts)
+	}
+	delivery := store.delivery(deliveryID)
+	if delivery.Status != models.WebhookDeliveryDead || len(delivery.Attempts) != testWebhookOptions.MaxAttempts {
+		t.Fatalf("unexpected delivery: status %s, %d attempts", delivery.Status, len(delivery.Attempts))
+	}
+
+	// A dead delivery can be retried with a fresh attempt budget.
+	store.RequeueDelivery(context.Background(), deliveryID)
+	s.deliverDue(context.Background())
+	if delivery := store.delivery(deliveryID); delivery.Status != models.WebhookDeliveryPending || delivery.AttemptCount != 1 {
+		t.Errorf("unexpected delivery after requeue: status %s, attempt %d", delivery.Status, delivery.AttemptCount)
+	}
+}
+
+func TestWebhookDisabledAfterConsecutiveFailures(t *testing.T) {
+	receiver := newWebhookReceiver(t, http.StatusInternalServerError, http.StatusInternalServerError)
+	store := newMemoryWebhookStore()
+	webhook, firstID := queueTestDelivery(t, store, receiver.URL)
+	broadcaster := &recordingBroadcaster{}
+	options := testWebhookOptions
+	options.DisableAfter = 2
+	s := newTestWebhookService(store, broadcaster, options)
+
+	s.deliverDue(context.Background())
+	store.makeDue()
+	s.deliverDue(context.Background())
+
+	if got := store.webhook(webhook.ID); !got.Disabled {
+		t.Fatalf("webhook was not disabled after %d failures", got.ConsecutiveFailures)
+	}
+	if len(broadcaster.sent) != 1 || broadcaster.sent[0].userID != "admin-1" || broadcaster.sent[0].messageType != "webhook_disabled" {
+		t.Fatalf("expected webhook_disabled to be sent to the creator, got %+v", broadcaster.sent)
+	}
+	if notified := broadcaster.sent[0].data.(*models.Webhook); notified.Secret != "" {
+		t.Error("webhook_disabled event leaked the secret")
+	}
+
+	// Deliveries of a disabled webhook are dead-lettered without a request.
+	store.EnqueueDelivery(context.Background(), models.WebhookDelivery{WebhookID: webhook.ID, ChatID: "chat-1", Payload: "{}"})
+	store.makeDue()
+	s.deliverDue(context.Background())
+	if got := len(receiver.received()); got != 2 {
+		t.Errorf("disabled webhook received %d requests, want 2", got)
+	}
+	for id := range store.deliveries {
+		if id == firstID {
+			continue
+		}
+		if delivery := store.delivery(id); delivery.Status != models.WebhookDeliveryDead {
+			t.Errorf("delivery of disabled webhook has status %s, want dead", delivery.Status)
+		}
+	}
+}
+
+func TestWebhookLookupFailureReleasesDelivery(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	store := newMemoryWebhookStore()
+	_, deliveryID := queueTestDelivery(t, store, receiver.URL)
+	s := newTestWebhookService(store, &recordingBroadcaster{}, testWebhookOptions)
+
+	store.getErr = errors.New("deadline exceeded")
+	s.deliverDue(context.Background())
+
+	delivery := store.delivery(deliveryID)
+	if delivery.Status != models.WebhookDeliveryPending || delivery.AttemptCount != 0 || len(delivery.Attempts) != 0 {
+		t.Fatalf("delivery was not released: status %s, attempt %d", delivery.Status, delivery.AttemptCount)
+	}
+	if !delivery.NextAttemptAt.After(time.Now()) {
+		t.Error("released delivery should wait before the next attempt")
+	}
+
+	store.getErr = nil
+	store.makeDue()
+	s.deliverDue(context.Background())
+	if delivery := store.delivery(deliveryID); delivery.Status != models.WebhookDeliveryDelivered {
+		t.Errorf("status = %s after the lookup recovered, want delivered", delivery.Status)
+	}
+}
+
+func TestWebhookDeliveryOfDeletedWebhookIsDeadLettered(t *testing.T) {
+	receiver := newWebhookReceiver(t)
+	store := newMemoryWebhookStore()
+	webhook, deliveryID := queueTestDelivery(t, store, receiver.URL)
+	store.DeleteWebhook(context.Background(), webhook.ID)
+
+	newTestWebhookService(store, &recordingBroadcaster{}, testWebhookOptions).deliverDue(context.Background())
+
+	delivery := store.delivery(deliveryID)
+	if delivery.Status != models.WebhookDeliveryDead || len(delivery.Attempts) != 1 || delivery.Attempts[0].Error != "webhook was deleted" {
+		t.Errorf("unexpected delivery: %+v", delivery)
+	}
+	if len(receiver.received()) != 0 {
+		t.Error("deleted webhook received a request")
+	}
+}
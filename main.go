@@ -2,13 +2,18 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"Flare-server/internal/config"
 	"Flare-server/internal/handler"
 	"Flare-server/internal/middleware"
+	"Flare-server/internal/notify"
+	"Flare-server/internal/push"
+	"Flare-server/internal/ratelimit"
 	"Flare-server/internal/repository"
 	"Flare-server/internal/service"
 
@@ -18,6 +23,18 @@ import (
 
 func main() {
 	cfg := config.Load()
+	if cfg.Environment == "production" && cfg.JWTSecret == config.DefaultJWTSecret {
+		log.Fatal("❌ JWT_SECRET must be changed from the default in production")
+	}
+	if cfg.Environment == "production" && cfg.Notifier != "smtp" {
+		log.Fatal("❌ NOTIFIER=smtp is required in production: the log notifier writes password reset links to the server log")
+	}
+	if cfg.Environment == "production" && cfg.PushProvider == "fake" {
+		log.Fatal("❌ PUSH_PROVIDER=fake is for development only; use fcm or none in production")
+	}
+	if cfg.Environment == "production" && cfg.DigestSigningKey == "" {
+		log.Fatal("❌ DIGEST_SIGNING_KEY must be set in production")
+	}
 
 	app, err := firebase.NewApp(context.Background(), nil, option.WithCredentialsFile(cfg.FirebaseKey))
 	if err != nil {
@@ -33,22 +50,262 @@ func main() {
 	userRepo := repository.NewUserRepo(firestoreClient)
 	messageRepo := repository.NewFirestoreRepo(firestoreClient, cfg.Collection)
 	chatRepo := repository.NewChatRepo(firestoreClient)
+	scheduledRepo := repository.NewScheduledMessageRepo(firestoreClient)
+	pollRepo := repository.NewPollRepo(firestoreClient)
+	blockRepo := repository.NewBlockRepo(firestoreClient)
+	contactRepo := repository.NewContactRepo(firestoreClient)
+	moderationRepo := repository.NewModerationRepo(firestoreClient)
+
+	var rateStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimitBackend == "firestore" {
+		rateStore = ratelimit.NewFirestoreStore(firestoreClient)
+	}
+	newLimiter := func(name string, policy config.RateLimitPolicy) *ratelimit.Limiter {
+		return ratelimit.NewLimiter(rateStore, name, ratelimit.Policy{Requests: policy.Requests, Per: policy.Per})
+	}
+	loginLimiter := newLimiter("login", cfg.LoginRateLimit)
+	registerLimiter := newLimiter("register", cfg.RegisterRateLimit)
+	messageLimiter := newLimiter("messages", cfg.MessageRateLimit)
+	searchLimiter := newLimiter("user_search", config.RateLimitPolicy{Requests: cfg.UserSearchRateLimit, Per: time.Minute})
+
+	denylist := service.NewTokenDenylist(repository.NewRevokedTokenRepo(firestoreClient), cfg.TokenDenylistMaxStaleness)
+	if err := denylist.Sync(context.Background()); err != nil {
+		log.Fatalf("❌ Failed to load token denylist: %v", err)
+	}
+
+	usernamePolicy := service.NewUsernamePolicy(cfg.ReservedUsernames)
+	authService := service.NewAuthService(userRepo, denylist, usernamePolicy, service.PasswordPolicy{
+		MinLength:        cfg.PasswordMinLength,
+		RequireMixedCase: cfg.PasswordRequireMixedCase,
+		RequireDigit:     cfg.PasswordRequireDigit,
+		RequireSymbol:    cfg.PasswordRequireSymbol,
+	}, service.LoginProtection{
+		MaxFailures:     cfg.LoginMaxFailures,
+		IPMaxFailures:   cfg.LoginIPMaxFailures,
+		LockoutDuration: cfg.LoginLockoutDuration,
+	}, service.TokenOptions{
+		Issuer:   cfg.JWTIssuer,
+		Audience: cfg.JWTAudience,
+		TTL:      cfg.JWTTTL,
+	}, []byte(cfg.JWTSecret))
+	var keyRing *service.KeyRing
+	if cfg.JWTSigningAlgorithm != "HS256" {
+		encryptionKey, err := base64.StdEncoding.DecodeString(cfg.JWTKeyEncryptionKey)
+		if err != nil || len(encryptionKey) != 32 {
+			log.Fatalf("❌ JWT_KEY_ENCRYPTION_KEY must be a base64 encoded 32-byte key when JWT_SIGNING_ALG=%s", cfg.JWTSigningAlgorithm)
+		}
+		keyRing, err = service.NewKeyRing(repository.NewSigningKeyRepo(firestoreClient), service.KeyRingOptions{
+			Algorithm:        cfg.JWTSigningAlgorithm,
+			RotationInterval: cfg.JWTKeyRotation,
+			GracePeriod:      cfg.JWTKeyGracePeriod,
+			EncryptionKey:    encryptionKey,
+		})
+		if err != nil {
+			log.Fatalf("❌ Invalid JWT signing configuration: %v", err)
+		}
+		if err := keyRing.Refresh(context.Background()); err != nil {
+			log.Fatalf("❌ Failed to load JWT signing keys: %v", err)
+		}
+		authService.SetKeyRing(keyRing)
+	}
 
-	authService := service.NewAuthService(userRepo, []byte(cfg.JWTSecret))
-	chatService := service.NewChatService(chatRepo, userRepo)
+	if cfg.FirebaseAuthEnabled {
+		if cfg.FirebaseAuthJWKSURL != "" {
+			verifier, err := service.NewFirebaseJWKSVerifier(cfg.FirebaseAuthJWKSURL, cfg.FirebaseProjectID)
+			if err != nil {
+				log.Fatalf("❌ Failed to set up Firebase token verification: %v", err)
+			}
+			authService.SetFirebaseVerifier(verifier)
+		} else {
+			authClient, err := app.Auth(context.Background())
+			if err != nil {
+				log.Fatalf("❌ Failed to create Firebase Auth client: %v", err)
+			}
+			authService.SetFirebaseVerifier(service.NewFirebaseAdminVerifier(authClient, cfg.FirebaseAuthCheckRevoked))
+		}
+	}
+	chatService := service.NewChatService(chatRepo, userRepo, contactRepo, blockRepo, service.ServerFilterOptions{
+		BlockedWords:       cfg.BlockedWords,
+		BlockLinks:         cfg.BlockLinks,
+		AllowedLinkDomains: cfg.AllowedLinkDomains,
+		MaxLength:          cfg.MaxMessageLength,
+		MaxRepeatedChars:   cfg.MaxRepeatedChars,
+	}, rateStore)
 	messageHandler := handler.NewMessageHandler(messageRepo)
 
+	userService := service.NewUserService(userRepo, chatRepo, blockRepo, usernamePolicy, cfg.UsernameChangeCooldown)
 	authHandler := handler.NewAuthHandler(authService)
+
+	var notifier notify.Notifier = notify.NewLogNotifier()
+	if cfg.Notifier == "smtp" {
+		notifier = notify.NewSMTPNotifier(notify.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		})
+	}
+	passwordService := service.NewPasswordService(userRepo, authService, notifier, service.PasswordResetOptions{
+		TokenTTL: cfg.PasswordResetTTL,
+		URL:      cfg.PasswordResetURL,
+	})
+	passwordHandler := handler.NewPasswordHandler(passwordService)
+
+	oidcProviders := make([]service.OIDCProviderConfig, 0, len(cfg.OIDCProviders))
+	for _, p := range cfg.OIDCProviders {
+		oidcProviders = append(oidcProviders, service.OIDCProviderConfig{
+			Name:         p.Name,
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       p.Scopes,
+		})
+	}
+	oidcService := service.NewOIDCService(userRepo, authService, oidcProviders)
+	oidcHandler := handler.NewOIDCHandler(oidcService)
+	userHandler := handler.NewUserHandler(userService, authService)
 	chatHandler := handler.NewChatHandler(chatService)
-	wsHandler := handler.NewWebSocketHandler(chatService, authService)
+	pollService := service.NewPollService(chatRepo, pollRepo, chatService)
+	wsHandler := handler.NewWebSocketHandler(chatService, authService, pollService, handler.WebSocketLimits{
+		Messages:        messageLimiter,
+		Typing:          newLimiter("typing", cfg.TypingRateLimit),
+		DisconnectAfter: cfg.WSRateLimitDisconnectAfter,
+	})
+	authService.SetBroadcaster(wsHandler)
+	chatService.SetBroadcaster(wsHandler)
+	pollHandler := handler.NewPollHandler(pollService, wsHandler)
+	contactService := service.NewContactService(contactRepo, userRepo, blockRepo, wsHandler)
+	contactHandler := handler.NewContactHandler(contactService)
+	blockService := service.NewBlockService(blockRepo, userRepo, contactRepo)
+	blockHandler := handler.NewBlockHandler(blockService)
+	moderationService := service.NewModerationService(moderationRepo, userRepo, chatRepo, wsHandler)
+	moderationHandler := handler.NewModerationHandler(moderationService)
+
+	botService := service.NewBotService(userRepo, repository.NewBotRepo(firestoreClient), chatRepo, chatService, usernamePolicy, wsHandler, service.BotOptions{
+		MaxPerOwner:          cfg.BotMaxPerOwner,
+		UpdateRetention:      cfg.BotUpdateRetention,
+		WebhookTimeout:       cfg.WebhookTimeout,
+		AllowPrivateWebhooks: cfg.WebhookAllowPrivateNetworks,
+	})
+	chatService.AddEventHook(botService)
+	chatService.AddCommandSource(botService)
+	botHandler := handler.NewBotHandler(botService)
+
+	webhookService := service.NewWebhookService(repository.NewWebhookRepo(firestoreClient), chatService, wsHandler, service.WebhookOptions{
+		MaxPerChat:   cfg.WebhookMaxPerChat,
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+		BackoffBase:  cfg.WebhookRetryBase,
+		BackoffMax:   cfg.WebhookRetryMax,
+		DisableAfter: cfg.WebhookDisableAfter,
+		LogRetention: cfg.WebhookLogRetention,
+		Timeout:      cfg.WebhookTimeout,
+		AllowPrivate: cfg.WebhookAllowPrivateNetworks,
+	})
+	chatService.AddEventHook(webhookService)
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+
+	incomingWebhookService := service.NewIncomingWebhookService(repository.NewIncomingWebhookRepo(firestoreClient), chatService, wsHandler,
+		newLimiter("incoming_webhooks", cfg.IncomingWebhookRateLimit), cfg.PublicURL)
+	incomingWebhookHandler := handler.NewIncomingWebhookHandler(incomingWebhookService)
+
+	var pushProvider push.Provider = push.NoopProvider{}
+	switch cfg.PushProvider {
+	case "fcm":
+		messagingClient, err := app.Messaging(context.Background())
+		if err != nil {
+			log.Fatalf("❌ Failed to create Firebase Messaging client: %v", err)
+		}
+		pushProvider = push.NewFCMProvider(messagingClient)
+	case "fake":
+		pushProvider = push.NewFakeProvider()
+	}
+	pushService := service.NewPushService(repository.NewDeviceRepo(firestoreClient), chatRepo, blockRepo, pushProvider, wsHandler, service.PushOptions{
+		CollapseWindow: cfg.PushCollapseWindow,
+		Timeout:        cfg.PushTimeout,
+	})
+	chatService.AddEventHook(pushService)
+	deviceHandler := handler.NewDeviceHandler(pushService)
+
+	digestSigningKey := cfg.DigestSigningKey
+	if digestSigningKey == "" {
+		digestSigningKey = cfg.JWTSecret
+	}
+	digestService := service.NewDigestService(userRepo, chatRepo, chatService, notifier, wsHandler, service.DigestOptions{
+		Hour:       cfg.DigestHour,
+		Weekday:    cfg.DigestWeekday,
+		MaxChats:   cfg.DigestMaxChats,
+		BaseURL:    cfg.PublicURL,
+		SigningKey: []byte(digestSigningKey),
+	})
+	digestHandler := handler.NewDigestHandler(digestService)
+
+	scheduleService := service.NewScheduleService(scheduledRepo, chatService, wsHandler)
+	scheduleHandler := handler.NewScheduleHandler(scheduleService)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go scheduleService.Start(ctx, cfg.SchedulerInterval)
+
+	messageReaper := service.NewMessageReaper(chatRepo, wsHandler)
+	go messageReaper.Start(ctx, cfg.ReaperInterval)
+
+	if keyRing != nil {
+		go keyRing.Start(ctx, cfg.JWTKeyRefreshInterval)
+	}
+	go denylist.Start(ctx, cfg.TokenDenylistSyncInterval, cfg.TokenDenylistPurgeInterval)
+	go botService.Start(ctx, cfg.BotDeliveryInterval)
+	go webhookService.Start(ctx, cfg.WebhookDeliveryInterval)
+	go digestService.Start(ctx, cfg.DigestInterval)
+	go func() {
+		updated, err := userRepo.BackfillSearchIndex(ctx)
+		if err != nil {
+			log.Printf("❌ Failed to backfill user search index: %v", err)
+			return
+		}
+		if updated > 0 {
+			log.Printf("✅ Backfilled search index of %d users", updated)
+		}
+	}()
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/api/register", authHandler.Register)
-	mux.HandleFunc("/api/login", authHandler.Login)
-	mux.HandleFunc("/api/logout", authHandler.Logout)
+	mux.Handle("/api/register", middleware.RateLimit(registerLimiter, middleware.ByIP)(http.HandlerFunc(authHandler.Register)))
+	mux.Handle("/api/login", middleware.RateLimit(loginLimiter, middleware.ByIP)(http.HandlerFunc(authHandler.Login)))
+	mux.Handle("/api/login/2fa", middleware.RateLimit(loginLimiter, middleware.ByIP)(http.HandlerFunc(authHandler.LoginTwoFactor)))
+	mux.HandleFunc("/.well-known/jwks.json", authHandler.JWKS)
 
 	protected := middleware.AuthMiddleware(authService)
 
+	mux.Handle("/api/auth/firebase", middleware.RateLimit(loginLimiter, middleware.ByIP)(http.HandlerFunc(authHandler.FirebaseLogin)))
+	mux.Handle("/api/auth/firebase/link", protected(http.HandlerFunc(authHandler.FirebaseLink)))
+
+	mux.HandleFunc("/api/auth/oidc", oidcHandler.GetProviders)
+	mux.Handle("/api/auth/oidc/", middleware.RateLimit(loginLimiter, middleware.ByIP)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/login"):
+			oidcHandler.Login(w, r)
+		case strings.HasSuffix(r.URL.Path, "/callback"):
+			oidcHandler.Callback(w, r)
+		case strings.HasSuffix(r.URL.Path, "/link"):
+			protected(http.HandlerFunc(oidcHandler.Link)).ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})))
+
+	passwordResetLimiter := middleware.RateLimit(newLimiter("password_reset", cfg.PasswordResetRateLimit), middleware.ByIP)
+	mux.Handle("/api/password/reset/request", passwordResetLimiter(http.HandlerFunc(passwordHandler.RequestReset)))
+	mux.Handle("/api/password/reset/confirm", passwordResetLimiter(http.HandlerFunc(passwordHandler.ConfirmReset)))
+
+	mux.Handle("/api/logout", protected(http.HandlerFunc(authHandler.Logout)))
+	mux.Handle("/api/logout/all", protected(http.HandlerFunc(authHandler.LogoutAll)))
+
+	mux.Handle("/api/password/change", protected(http.HandlerFunc(passwordHandler.ChangePassword)))
+	mux.Handle("/api/2fa/enroll", protected(http.HandlerFunc(authHandler.EnrollTwoFactor)))
+	mux.Handle("/api/2fa/activate", protected(http.HandlerFunc(authHandler.ActivateTwoFactor)))
+	mux.Handle("/api/2fa/disable", protected(http.HandlerFunc(authHandler.DisableTwoFactor)))
+
 	mux.Handle("/api/messages", protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
@@ -62,6 +319,168 @@ func main() {
 
 	mux.Handle("/api/profile", protected(http.HandlerFunc(authHandler.Profile)))
 
+	mux.Handle("/api/users/me", protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			userHandler.GetMyProfile(w, r)
+		case http.MethodPatch:
+			userHandler.UpdateMyProfile(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+
+	mux.Handle("/api/users/me/digest", protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			digestHandler.GetSettings(w, r)
+		case http.MethodPut:
+			digestHandler.UpdateSettings(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+
+	mux.HandleFunc("/api/digest/unsubscribe", digestHandler.Unsubscribe)
+
+	mux.Handle("/api/users/search", protected(middleware.RateLimit(searchLimiter, middleware.ByUser)(http.HandlerFunc(userHandler.SearchUsers))))
+
+	mux.Handle("/api/users/me/username", protected(http.HandlerFunc(userHandler.ChangeUsername)))
+
+	mux.Handle("/api/users/me/avatar", protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPut:
+			userHandler.UploadAvatar(w, r)
+		case http.MethodDelete:
+			userHandler.DeleteAvatar(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+
+	mux.HandleFunc("/api/users/", func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+
+		// Avatars are served without authentication so they can be used in <img> tags.
+		if strings.HasSuffix(path, "/avatar") {
+			userHandler.GetAvatar(w, r)
+			return
+		}
+
+		if path != "/api/users/" && !strings.Contains(path[len("/api/users/"):], "/") {
+			protected(http.HandlerFunc(userHandler.GetUserProfile)).ServeHTTP(w, r)
+			return
+		}
+
+		http.Error(w, "Not found", http.StatusNotFound)
+	})
+
+	mux.Handle("/api/contacts", protected(http.HandlerFunc(contactHandler.GetContacts)))
+
+	mux.Handle("/api/contacts/requests", protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			contactHandler.GetFriendRequests(w, r)
+		case http.MethodPost:
+			contactHandler.SendFriendRequest(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+
+	mux.Handle("/api/contacts/", protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+
+		if strings.HasPrefix(path, "/api/contacts/requests/") {
+			switch {
+			case strings.HasSuffix(path, "/accept"):
+				contactHandler.AcceptFriendRequest(w, r)
+			case strings.HasSuffix(path, "/decline"):
+				contactHandler.DeclineFriendRequest(w, r)
+			default:
+				contactHandler.CancelFriendRequest(w, r)
+			}
+			return
+		}
+
+		if path != "/api/contacts/" && !strings.Contains(path[len("/api/contacts/"):], "/") {
+			switch r.Method {
+			case http.MethodPut:
+				contactHandler.UpdateContact(w, r)
+			case http.MethodDelete:
+				contactHandler.RemoveContact(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		http.Error(w, "Not found", http.StatusNotFound)
+	})))
+
+	mux.Handle("/api/blocks", protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			blockHandler.GetBlockedUsers(w, r)
+		case http.MethodPost:
+			blockHandler.BlockUser(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+
+	mux.Handle("/api/blocks/", protected(http.HandlerFunc(blockHandler.UnblockUser)))
+
+	mux.Handle("/api/devices", protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			deviceHandler.GetDevices(w, r)
+		case http.MethodPost:
+			deviceHandler.RegisterDevice(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+
+	mux.Handle("/api/devices/", protected(http.HandlerFunc(deviceHandler.DeleteDevice)))
+
+	mux.Handle("/api/bots", protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			botHandler.GetBots(w, r)
+		case http.MethodPost:
+			botHandler.CreateBot(w, r)
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	})))
+
+	mux.Handle("/api/bots/", protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/token") {
+			botHandler.RegenerateToken(w, r)
+			return
+		}
+		http.Error(w, "Not found", http.StatusNotFound)
+	})))
+
+	botAPI := middleware.BotAuthMiddleware(botService)
+	mux.Handle("/api/bot/getMe", botAPI(http.HandlerFunc(botHandler.GetMe)))
+	mux.Handle("/api/bot/sendMessage", botAPI(middleware.RateLimit(messageLimiter, middleware.ByUser)(http.HandlerFunc(botHandler.SendMessage))))
+	mux.Handle("/api/bot/getUpdates", botAPI(http.HandlerFunc(botHandler.GetUpdates)))
+	mux.Handle("/api/bot/setWebhook", botAPI(http.HandlerFunc(botHandler.SetWebhook)))
+	mux.Handle("/api/bot/deleteWebhook", botAPI(http.HandlerFunc(botHandler.DeleteWebhook)))
+	mux.Handle("/api/bot/setCommands", botAPI(http.HandlerFunc(botHandler.SetCommands)))
+	mux.Handle("/api/bot/sendEphemeral", botAPI(http.HandlerFunc(botHandler.SendEphemeral)))
+	// Unknown or wrong webhook URLs are limited per client IP, so they cannot be
+	// probed at full speed; valid ones also have a limit of their own.
+	incomingWebhookIPLimiter := newLimiter("incoming_webhooks_ip", cfg.IncomingWebhookIPRateLimit)
+	mux.Handle("/api/hooks/", middleware.RateLimit(incomingWebhookIPLimiter, middleware.ByIP)(http.HandlerFunc(incomingWebhookHandler.Post)))
+
+	mux.Handle("/api/reports", protected(http.HandlerFunc(moderationHandler.CreateReport)))
+	mux.Handle("/api/moderation/reports", protected(http.HandlerFunc(moderationHandler.GetReports)))
+	mux.Handle("/api/moderation/actions", protected(http.HandlerFunc(moderationHandler.TakeAction)))
+	mux.Handle("/api/moderation/audit", protected(http.HandlerFunc(moderationHandler.GetAuditLog)))
+
 	mux.Handle("/api/chats", protected(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
@@ -95,13 +514,136 @@ func main() {
 			case http.MethodGet:
 				chatHandler.GetChatMessages(w, r)
 			case http.MethodPost:
-				chatHandler.SendMessage(w, r)
+				middleware.RateLimit(messageLimiter, middleware.ByUser)(http.HandlerFunc(chatHandler.SendMessage)).ServeHTTP(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		if strings.HasSuffix(path, "/filters") {
+			switch r.Method {
+			case http.MethodGet:
+				chatHandler.GetChatFilters(w, r)
+			case http.MethodPut:
+				chatHandler.UpdateChatFilters(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		if strings.HasSuffix(path, "/read") {
+			chatHandler.MarkChatRead(w, r)
+			return
+		}
+
+		if strings.HasSuffix(path, "/mute") {
+			chatHandler.MuteChat(w, r)
+			return
+		}
+
+		if strings.HasSuffix(path, "/commands") {
+			chatHandler.GetChatCommands(w, r)
+			return
+		}
+
+		if strings.HasSuffix(path, "/scheduled") {
+			switch r.Method {
+			case http.MethodGet:
+				scheduleHandler.GetScheduledMessages(w, r)
+			case http.MethodPost:
+				scheduleHandler.ScheduleMessage(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		if strings.Contains(path, "/scheduled/") {
+			switch r.Method {
+			case http.MethodPut:
+				scheduleHandler.UpdateScheduledMessage(w, r)
+			case http.MethodDelete:
+				scheduleHandler.CancelScheduledMessage(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		if strings.HasSuffix(path, "/polls") {
+			if r.Method == http.MethodPost {
+				pollHandler.CreatePoll(w, r)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		if strings.Contains(path, "/polls/") {
+			switch {
+			case strings.HasSuffix(path, "/vote") && r.Method == http.MethodPost:
+				pollHandler.Vote(w, r)
+			case strings.HasSuffix(path, "/vote") && r.Method == http.MethodDelete:
+				pollHandler.RetractVote(w, r)
+			case strings.HasSuffix(path, "/close") && r.Method == http.MethodPost:
+				pollHandler.ClosePoll(w, r)
+			default:
+				http.Error(w, "Not found", http.StatusNotFound)
+			}
+			return
+		}
+
+		if strings.HasSuffix(path, "/webhooks") {
+			switch r.Method {
+			case http.MethodGet:
+				webhookHandler.GetWebhooks(w, r)
+			case http.MethodPost:
+				webhookHandler.CreateWebhook(w, r)
 			default:
 				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			}
 			return
 		}
 
+		if strings.Contains(path, "/webhooks/") {
+			switch {
+			case strings.HasSuffix(path, "/deliveries") && r.Method == http.MethodGet:
+				webhookHandler.GetDeliveries(w, r)
+			case strings.HasSuffix(path, "/retry") && strings.Contains(path, "/deliveries/") && r.Method == http.MethodPost:
+				webhookHandler.RetryDelivery(w, r)
+			case strings.Count(path, "/") == 5 && r.Method == http.MethodPut:
+				webhookHandler.UpdateWebhook(w, r)
+			case strings.Count(path, "/") == 5 && r.Method == http.MethodDelete:
+				webhookHandler.DeleteWebhook(w, r)
+			default:
+				http.Error(w, "Not found", http.StatusNotFound)
+			}
+			return
+		}
+
+		if strings.HasSuffix(path, "/incoming-webhooks") {
+			switch r.Method {
+			case http.MethodGet:
+				incomingWebhookHandler.GetIncomingWebhooks(w, r)
+			case http.MethodPost:
+				incomingWebhookHandler.CreateIncomingWebhook(w, r)
+			default:
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
+		if strings.Contains(path, "/incoming-webhooks/") {
+			if r.Method == http.MethodDelete {
+				incomingWebhookHandler.DeleteIncomingWebhook(w, r)
+			} else {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
 		if strings.HasSuffix(path, "/members") {
 			switch r.Method {
 			case http.MethodPost: